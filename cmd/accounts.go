@@ -0,0 +1,112 @@
+package main
+
+import (
+	"douyin-action-example/internal/actions/storage"
+	"douyin-action-example/internal/apps"
+	"douyin-action-example/internal/conf"
+	"flag"
+	"fmt"
+	"github.com/pkg/errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// accountRecord 为管理命令输出的钉钉用户关联的抖音账号
+type accountRecord struct {
+	App      string    `json:"app"`
+	User     string    `json:"user"`
+	OpenID   string    `json:"open_id"`
+	Nickname string    `json:"nickname,omitempty"`
+	Primary  bool      `json:"primary"`
+	LinkedAt time.Time `json:"linked_at"`
+	// Token 为账号最近颁发的 Token 的状态：active、expired 或 none
+	Token string `json:"token"`
+	// SharedWith 为账号被共享给的钉钉用户及角色
+	SharedWith []string `json:"shared_with"`
+}
+
+func runAccounts(config *conf.Config, args []string) error {
+	if err := requireFileStorage(config, "accounts"); err != nil {
+		return err
+	}
+	if len(args) == 0 || args[0] != "list" {
+		return errors.New("usage: accounts list [--app ID] [--user USER] [--format table|json]")
+	}
+	fs := flag.NewFlagSet("accounts list", flag.ExitOnError)
+	format := formatFlag(fs)
+	appId := fs.String("app", "", "only list accounts of this app")
+	user := fs.String("user", "", "only list accounts linked to this DingTalk user")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if err := openStorage(config); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	found := *appId == ""
+	records := []*accountRecord{}
+	for _, app := range apps.All() {
+		if *appId != "" && app.ID != *appId {
+			continue
+		}
+		found = true
+		records = append(records, listAccounts(app, *user, now)...)
+	}
+	if !found {
+		return errors.Errorf("app %q is not configured", *appId)
+	}
+
+	rows := make([][]string, 0, len(records))
+	for _, record := range records {
+		rows = append(rows, []string{
+			record.App, record.User, record.OpenID, orDash(record.Nickname), fmt.Sprint(record.Primary),
+			formatTime(record.LinkedAt), record.Token, orDash(strings.Join(record.SharedWith, ",")),
+		})
+	}
+	return printResult(*format, []string{"APP", "USER", "OPEN_ID", "NICKNAME", "PRIMARY", "LINKED_AT", "TOKEN", "SHARED_WITH"}, rows, records)
+}
+
+// listAccounts 返回应用中钉钉用户关联的抖音账号，按钉钉用户排序，user 非空时只返回该用户的账号
+func listAccounts(app *apps.App, user string, now time.Time) []*accountRecord {
+	tokens := storage.Tokens(app.Namespace)
+	users := storage.AccountLinks(app.Namespace).Users()
+	names := make([]string, 0, len(users))
+	for name := range users {
+		if user == "" || name == user {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var records []*accountRecord
+	for _, name := range names {
+		for _, linked := range users[name] {
+			record := &accountRecord{
+				App:        app.ID,
+				User:       name,
+				OpenID:     linked.OpenID,
+				Nickname:   linked.Nickname,
+				Primary:    linked.Primary,
+				LinkedAt:   linked.LinkedAt,
+				Token:      "none",
+				SharedWith: []string{},
+			}
+			if info, err := tokens.LatestByOpenID(linked.OpenID); err == nil {
+				record.Token = "active"
+				if info.IsExpired(now) {
+					record.Token = "expired"
+				}
+			}
+			grants := storage.Workspaces(app.Namespace).Grants(func(grant *storage.AccountGrant) bool {
+				return grant.OpenID == linked.OpenID && grant.GrantedBy == name
+			})
+			for _, grant := range grants {
+				record.SharedWith = append(record.SharedWith, grant.User+":"+string(grant.Role))
+			}
+			records = append(records, record)
+		}
+	}
+	return records
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"douyin-action-example/internal/audit"
+	"douyin-action-example/internal/conf"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/pkg/errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// parseSince 解析 --since、--until，取值为 RFC 3339 时间或距今的时长（如 24h）
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errors.Errorf("invalid time %q, expect RFC 3339 or a duration such as 24h", value)
+	}
+	return t, nil
+}
+
+func runAudit(config *conf.Config, args []string) error {
+	if len(args) == 0 || args[0] != "query" {
+		return errors.New("usage: audit query [--since 24h] [--until TIME] [--app ID] [--user USER] [--account OPEN_ID] [--action PATH] [--limit N] [--format table|json]")
+	}
+	fs := flag.NewFlagSet("audit query", flag.ExitOnError)
+	format := formatFlag(fs)
+	since := fs.String("since", "", "only records at or after this RFC 3339 time or duration ago, such as 24h")
+	until := fs.String("until", "", "only records before this RFC 3339 time or duration ago")
+	appId := fs.String("app", "", "only records of this app")
+	user := fs.String("user", "", "only records of this DingTalk user")
+	account := fs.String("account", "", "only records of this douyin open_id")
+	action := fs.String("action", "", "only records of this action path, such as /videoList")
+	limit := fs.Int("limit", 100, "maximum number of records, 0 for all")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	filter := &audit.Filter{App: *appId, User: *user, OpenID: *account, Action: *action, Limit: *limit}
+	var err error
+	if filter.Since, err = parseSince(*since, now); err != nil {
+		return err
+	}
+	if filter.Until, err = parseSince(*until, now); err != nil {
+		return err
+	}
+
+	if !config.Audit.Enabled {
+		return errors.New("audit is not enabled in the config")
+	}
+	sink, err := audit.New(config.Audit)
+	if err != nil {
+		return err
+	}
+	defer sink.Close()
+	querier, ok := sink.(audit.Querier)
+	if !ok {
+		return errors.Errorf("audit sink %q does not support query", config.Audit.Sink)
+	}
+	records, err := querier.Query(filter)
+	if err != nil {
+		return err
+	}
+	if records == nil {
+		records = []*audit.Record{}
+	}
+
+	rows := make([][]string, 0, len(records))
+	for _, record := range records {
+		result := fmt.Sprint(record.Status)
+		if record.Error != "" {
+			result += " " + record.Error
+		}
+		if record.UpstreamCode != 0 {
+			result += fmt.Sprintf(" (douyin %d)", record.UpstreamCode)
+		}
+		rows = append(rows, []string{
+			formatTime(record.Time), orDash(record.App), record.Method + " " + record.Action, orDash(record.User),
+			orDash(record.OpenID), result, fmt.Sprintf("%dms", record.LatencyMs), formatParams(record.Params),
+		})
+	}
+	return printResult(*format, []string{"TIME", "APP", "ACTION", "USER", "OPEN_ID", "RESULT", "LATENCY", "PARAMS"}, rows, records)
+}
+
+// formatParams 以 name=value 的形式在表格中输出参数，请求体等复杂取值输出为 JSON
+func formatParams(params map[string]interface{}) string {
+	if len(params) == 0 {
+		return "-"
+	}
+	items := make([]string, 0, len(params))
+	for name, value := range params {
+		if s, ok := value.(string); ok {
+			items = append(items, name+"="+s)
+			continue
+		}
+		encoded, _ := json.Marshal(value)
+		items = append(items, name+"="+string(encoded))
+	}
+	sort.Strings(items)
+	return strings.Join(items, " ")
+}
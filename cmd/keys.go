@@ -0,0 +1,62 @@
+package main
+
+import (
+	"douyin-action-example/internal/actions/storage"
+	"douyin-action-example/internal/apps"
+	"douyin-action-example/internal/conf"
+	"flag"
+	"fmt"
+	"github.com/pkg/errors"
+	"sort"
+)
+
+// rotateRecord 为管理命令输出的一个命名空间的重新加密结果
+type rotateRecord struct {
+	App         string `json:"app"`
+	Namespace   string `json:"namespace"`
+	PrimaryKey  string `json:"primary_key"`
+	Reencrypted int    `json:"reencrypted"`
+}
+
+func runKeys(config *conf.Config, args []string) error {
+	if err := requireFileStorage(config, "keys"); err != nil {
+		return err
+	}
+	if len(args) == 0 || args[0] != "rotate" {
+		return errors.New("usage: keys rotate [--format table|json]")
+	}
+	fs := flag.NewFlagSet("keys rotate", flag.ExitOnError)
+	format := formatFlag(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	keys := config.Storage.Encryption.Keys
+	if len(keys) == 0 {
+		return errors.New("storage.encryption.keys is empty, add a primary key before rotating")
+	}
+	if err := openStorage(config); err != nil {
+		return err
+	}
+
+	stores := storage.Stores()
+	namespaces := make([]string, 0, len(stores))
+	for namespace := range stores {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	records := make([]*rotateRecord, 0, len(namespaces))
+	rows := make([][]string, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		count, err := stores[namespace].Reencrypt()
+		if err != nil {
+			return errors.Wrapf(err, "re-encrypt tokens of namespace %q", namespace)
+		}
+		record := &rotateRecord{Namespace: namespace, PrimaryKey: keys[0].ID, Reencrypted: count}
+		if app, ok := apps.ByNamespace(namespace); ok {
+			record.App = app.ID
+		}
+		records = append(records, record)
+		rows = append(rows, []string{orDash(record.App), orDash(record.Namespace), record.PrimaryKey, fmt.Sprint(record.Reencrypted)})
+	}
+	return printResult(*format, []string{"APP", "NAMESPACE", "PRIMARY_KEY", "REENCRYPTED"}, rows, records)
+}
@@ -1,20 +1,115 @@
 package main
 
 import (
+	"context"
 	"douyin-action-example/internal/actions"
+	"douyin-action-example/internal/actions/storage"
+	"douyin-action-example/internal/apps"
+	"douyin-action-example/internal/audit"
+	"douyin-action-example/internal/buildinfo"
 	"douyin-action-example/internal/conf"
+	"douyin-action-example/internal/logging"
+	"douyin-action-example/internal/tracing"
+	"flag"
+	"fmt"
 	"github.com/chzealot/gobase/logger"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage: %s [--config FILE] [command]
+
+Commands:
+  serve                     run the http server (default)
+  config check              validate the configuration and print the effective config with secrets redacted
+  tokens list               list tokens in the configured store
+  tokens show <ref>         show a token by id, open_id or access_token
+  tokens revoke <ref>       delete matched tokens, all tokens of the account when ref is an open_id
+  tokens refresh <ref>      refresh matched tokens with their refresh_token
+  accounts list             list douyin accounts linked to DingTalk users
+  audit query               query the audit log of actions, filter with --since, --user, --account, --action
+  keys rotate               re-encrypt stored tokens with the primary key of storage.encryption.keys;
+                            the service only loads keys at startup, restart it with the new keys before rotating
+
+  tokens, accounts and audit accept --app ID and --format table|json; tokens, accounts and keys require storage.backend file
+
+Flags:
+`, os.Args[0])
+	flag.PrintDefaults()
+}
+
 func main() {
-	if err := logger.InitWithConfig(conf.AppConfig); err != nil {
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path of the YAML or TOML config file")
+	flag.Usage = usage
+	flag.Parse()
+
+	config, err := conf.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "load config failed: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	args := flag.Args()
+	switch {
+	case len(args) == 0 || args[0] == "serve":
+		serve(config)
+	case len(args) == 2 && args[0] == "config" && args[1] == "check":
+		if err := apps.Init(config); err != nil {
+			fmt.Fprintf(os.Stderr, "load apps failed: %s\n", err.Error())
+			os.Exit(1)
+		}
+		fmt.Print(config.String())
+	case args[0] == "tokens":
+		exitOnError(runTokens(config, args[1:]))
+	case args[0] == "accounts":
+		exitOnError(runAccounts(config, args[1:]))
+	case args[0] == "audit":
+		exitOnError(runAudit(config, args[1:]))
+	case args[0] == "keys":
+		exitOnError(runKeys(config, args[1:]))
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func serve(config *conf.Config) {
+	conf.Use(config)
+	if err := conf.InitLogger(); err != nil {
+		panic(err)
+	}
+	logging.Install()
+	logger.Infof("start DouYin standardised service, build=%+v", *buildinfo.Get())
+	logger.Infof("effective config:\n%s", config.String())
+	if err := apps.Init(config); err != nil {
+		panic(err)
+	}
+	if err := storage.Init(config.Storage, apps.Namespaces()); err != nil {
+		panic(err)
+	}
+	if err := audit.Init(config.Audit); err != nil {
 		panic(err)
 	}
-	logger.Infof("start DouYin standardised service ...")
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	shutdownTracing, err := tracing.Init(ctx, config.Tracing)
+	if err != nil {
+		panic(err)
+	}
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(config.ShutdownTimeout))
+		defer cancel()
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			logger.Errorf("flush traces failed, err=%s", err.Error())
+		}
+	}()
 	server := actions.NewHttpServer()
-	if err := server.Run(":3021"); err != nil {
+	if err := server.Run(ctx, config.Listen); err != nil {
 		panic(err)
 	}
-	select {}
+	logger.Infof("DouYin standardised service stopped")
 }
@@ -0,0 +1,42 @@
+// openapi-gen 由业务动作及 models 中的结构体生成 internal/actions/assets/openapi.yaml
+//
+//	go generate ./internal/actions/assets              # 重新生成
+//	go run ./cmd/openapi-gen -check -o internal/actions/assets/openapi.yaml # 校验已提交的文档与代码一致
+package main
+
+import (
+	"bytes"
+	"douyin-action-example/internal/actions/controllers"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	output := flag.String("o", "openapi.yaml", "path of the generated spec")
+	check := flag.Bool("check", false, "exit with status 1 if the spec at -o differs from the generated one instead of writing it")
+	flag.Parse()
+
+	content, err := controllers.GenerateOpenApiSpec()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "generate openapi spec failed: %+v\n", err)
+		os.Exit(1)
+	}
+
+	if *check {
+		existing, err := os.ReadFile(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "read %s failed: %s\n", *output, err.Error())
+			os.Exit(1)
+		}
+		if !bytes.Equal(existing, content) {
+			fmt.Fprintf(os.Stderr, "%s is out of date, run go generate ./internal/actions/assets\n", *output)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := os.WriteFile(*output, content, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "write %s failed: %s\n", *output, err.Error())
+		os.Exit(1)
+	}
+}
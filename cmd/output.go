@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+// formatFlag 为管理命令注册 --format 参数
+func formatFlag(fs *flag.FlagSet) *string {
+	return fs.String("format", formatTable, "output format, table or json")
+}
+
+// printResult 以表格或 JSON 输出结果，表格由 headers 及 rows 组成，JSON 输出 v
+func printResult(format string, headers []string, rows [][]string, v interface{}) error {
+	switch format {
+	case formatJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case formatTable:
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, strings.Join(headers, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unsupported format %q, expect table or json", format)
+	}
+}
+
+// formatTime 以 RFC 3339 输出时间，零值输出 -
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
+
+// orDash 将空字符串输出为 -
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"douyin-action-example/internal/actions/controllers"
+	"douyin-action-example/internal/actions/storage"
+	"douyin-action-example/internal/apps"
+	"douyin-action-example/internal/conf"
+	"flag"
+	"fmt"
+	"github.com/pkg/errors"
+	"os"
+	"strings"
+	"time"
+)
+
+// tokenRefreshTimeout 为 tokens refresh 调用抖音的整体超时
+const tokenRefreshTimeout = 30 * time.Second
+
+// tokenRecord 为管理命令输出的 Token，不包含 access_token 及 refresh_token
+type tokenRecord struct {
+	ID              string    `json:"id"`
+	App             string    `json:"app"`
+	OpenID          string    `json:"open_id"`
+	User            string    `json:"user,omitempty"`
+	ClientID        string    `json:"client_id"`
+	Scopes          []string  `json:"scopes"`
+	IssuedAt        time.Time `json:"issued_at"`
+	ExpiresAt       time.Time `json:"expires_at,omitempty"`
+	Status          string    `json:"status"`
+	HasRefreshToken bool      `json:"has_refresh_token"`
+}
+
+// storedToken 为存储中的 Token 及其所属的应用
+type storedToken struct {
+	app  *apps.App
+	info *storage.TokenInfo
+}
+
+func (t *storedToken) record(now time.Time) *tokenRecord {
+	status := "active"
+	if t.info.IsExpired(now) {
+		status = "expired"
+	}
+	user, _ := storage.AccountLinks(t.app.Namespace).Owner(t.info.OpenID)
+	return &tokenRecord{
+		ID:              t.info.ID(),
+		App:             t.app.ID,
+		OpenID:          t.info.OpenID,
+		User:            user,
+		ClientID:        t.info.ClientID,
+		Scopes:          append([]string{}, t.info.Scopes...),
+		IssuedAt:        t.info.IssuedAt,
+		ExpiresAt:       t.info.ExpiresAt,
+		Status:          status,
+		HasRefreshToken: t.info.RefreshToken != "",
+	}
+}
+
+// requireFileStorage 校验存储为 file，管理命令在解析参数前调用，memory 存储的数据只在运行中的服务内，命令无法读取
+func requireFileStorage(config *conf.Config, command string) error {
+	if config.Storage.Backend != "file" {
+		return errors.Errorf("%s requires storage.backend file, got %q: the memory backend only lives in the running service, "+
+			"inspect it through the /admin api instead", command, config.Storage.Backend)
+	}
+	return nil
+}
+
+// openStorage 按配置打开 file 存储，需先经 requireFileStorage 校验
+func openStorage(config *conf.Config) error {
+	conf.Use(config)
+	if err := apps.Init(config); err != nil {
+		return errors.Wrap(err, "load apps")
+	}
+	return storage.Init(config.Storage, apps.Namespaces())
+}
+
+// listTokens 返回应用 appId 的全部 Token，appId 为空时返回全部应用的 Token
+func listTokens(appId string) ([]*storedToken, error) {
+	var tokens []*storedToken
+	found := appId == ""
+	for _, app := range apps.All() {
+		if appId != "" && app.ID != appId {
+			continue
+		}
+		found = true
+		for _, info := range storage.Tokens(app.Namespace).List() {
+			tokens = append(tokens, &storedToken{app: app, info: info})
+		}
+	}
+	if !found {
+		return nil, errors.Errorf("app %q is not configured", appId)
+	}
+	return tokens, nil
+}
+
+// matchTokens 按 Token ID、access_token 或 open_id 查找 Token
+func matchTokens(appId, ref string) ([]*storedToken, error) {
+	tokens, err := listTokens(appId)
+	if err != nil {
+		return nil, err
+	}
+	var matched []*storedToken
+	for _, t := range tokens {
+		if t.info.ID() == ref || t.info.AccessToken == ref || t.info.OpenID == ref {
+			matched = append(matched, t)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, errors.Errorf("no token matches %q", ref)
+	}
+	return matched, nil
+}
+
+func runTokens(config *conf.Config, args []string) error {
+	if err := requireFileStorage(config, "tokens"); err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return errors.New("usage: tokens list|show|revoke|refresh")
+	}
+	fs := flag.NewFlagSet("tokens "+args[0], flag.ExitOnError)
+	format := formatFlag(fs)
+	appId := fs.String("app", "", "only operate on tokens of this app")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if err := openStorage(config); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	switch args[0] {
+	case "list":
+		tokens, err := listTokens(*appId)
+		if err != nil {
+			return err
+		}
+		return printTokens(*format, tokens, now)
+	case "show":
+		if fs.NArg() != 1 {
+			return errors.New("usage: tokens show [--app ID] <token id|open_id|access_token>")
+		}
+		tokens, err := matchTokens(*appId, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		records := make([]*tokenRecord, 0, len(tokens))
+		for _, t := range tokens {
+			records = append(records, t.record(now))
+		}
+		if *format == formatJSON {
+			return printResult(*format, nil, nil, records)
+		}
+		for i, record := range records {
+			if i > 0 {
+				fmt.Println()
+			}
+			if err := printResult(*format, []string{"FIELD", "VALUE"}, [][]string{
+				{"id", record.ID},
+				{"app", record.App},
+				{"open_id", record.OpenID},
+				{"user", orDash(record.User)},
+				{"client_id", record.ClientID},
+				{"scopes", strings.Join(record.Scopes, " ")},
+				{"issued_at", formatTime(record.IssuedAt)},
+				{"expires_at", formatTime(record.ExpiresAt)},
+				{"status", record.Status},
+				{"has_refresh_token", fmt.Sprint(record.HasRefreshToken)},
+			}, record); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "revoke":
+		if fs.NArg() != 1 {
+			return errors.New("usage: tokens revoke [--app ID] <token id|open_id|access_token>")
+		}
+		tokens, err := matchTokens(*appId, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		for _, t := range tokens {
+			if err := storage.Tokens(t.app.Namespace).Delete(t.info.AccessToken); err != nil && !errors.Is(err, storage.ErrTokenNotFound) {
+				return err
+			}
+		}
+		return printTokens(*format, tokens, now)
+	case "refresh":
+		if fs.NArg() != 1 {
+			return errors.New("usage: tokens refresh [--app ID] <token id|open_id|access_token>")
+		}
+		tokens, err := matchTokens(*appId, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), tokenRefreshTimeout)
+		defer cancel()
+		refreshed := make([]*storedToken, 0, len(tokens))
+		for _, t := range tokens {
+			info, err := controllers.RefreshToken(ctx, t.app, t.info)
+			if err != nil {
+				return errors.Wrapf(err, "refresh token %s", t.info.ID())
+			}
+			refreshed = append(refreshed, &storedToken{app: t.app, info: info})
+		}
+		return printTokens(*format, refreshed, time.Now())
+	default:
+		return errors.Errorf("unknown command tokens %s, expect list, show, revoke or refresh", args[0])
+	}
+}
+
+func printTokens(format string, tokens []*storedToken, now time.Time) error {
+	records := make([]*tokenRecord, 0, len(tokens))
+	rows := make([][]string, 0, len(tokens))
+	for _, t := range tokens {
+		record := t.record(now)
+		records = append(records, record)
+		rows = append(rows, []string{
+			record.ID, record.App, record.OpenID, orDash(record.User), strings.Join(record.Scopes, " "),
+			formatTime(record.ExpiresAt), record.Status,
+		})
+	}
+	return printResult(format, []string{"ID", "APP", "OPEN_ID", "USER", "SCOPES", "EXPIRES_AT", "STATUS"}, rows, records)
+}
+
+// exitOnError 输出错误并以状态码 1 退出
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"douyin-action-example/internal/conf"
+	"strings"
+	"testing"
+)
+
+func TestCommandsRequireFileStorage(t *testing.T) {
+	config := conf.Default()
+	config.Storage.Backend = "memory"
+	for name, run := range map[string]func(*conf.Config, []string) error{
+		"tokens":   runTokens,
+		"accounts": runAccounts,
+		// 未配置加密密钥时也应先报告存储不支持
+		"keys": runKeys,
+	} {
+		err := run(config, nil)
+		if err == nil || !strings.Contains(err.Error(), name+" requires storage.backend file") {
+			t.Errorf("%s: expect a file backend error, got %v", name, err)
+		}
+	}
+}
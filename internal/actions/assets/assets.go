@@ -2,5 +2,20 @@ package assets
 
 import _ "embed"
 
+//go:generate go run ../../../cmd/openapi-gen -o openapi.yaml
+
+// OpenApiSpecYaml 由 cmd/openapi-gen 生成，修改业务动作或响应结构体后需执行 go generate
+//
 //go:embed openapi.yaml
 var OpenApiSpecYaml string
+
+//go:embed error.html
+var ErrorPageHtml string
+
+// AdminConsoleHtml 为 /admin 的管理控制台，数据通过 /admin/api 加载
+//
+//go:embed admin.html
+var AdminConsoleHtml string
+
+// DefaultServerUrl 为 openapi.yaml 中书写的服务地址，对外提供时替换为实际的访问地址
+const DefaultServerUrl = "https://douyin-example.dingtalkapps.com"
@@ -0,0 +1,246 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"douyin-action-example/internal/actions/models"
+	"douyin-action-example/internal/actions/storage"
+	"douyin-action-example/internal/apps"
+	"douyin-action-example/internal/conf"
+	"douyin-action-example/internal/logging"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	// roleOwner 为账号所有者在响应及访问记录中展示的角色
+	roleOwner = "owner"
+	// gatewaySecretHeader 为可信网关传入 accounts.gateway_secret 的请求头
+	gatewaySecretHeader = "X-Gateway-Secret"
+)
+
+// dingTalkUser 返回可信网关在 accounts.user_header 中传入的钉钉用户标识，未配置或请求不是来自可信网关时返回空字符串
+func dingTalkUser(c *gin.Context) string {
+	config := conf.App.Accounts
+	if config.UserHeader == "" {
+		return ""
+	}
+	user := c.GetHeader(config.UserHeader)
+	if user == "" || fromTrustedGateway(c, config) {
+		return user
+	}
+	logging.FromContext(c.Request.Context()).Warnf("ignore %s header from untrusted gateway %s", config.UserHeader, c.Request.RemoteAddr)
+	return ""
+}
+
+// fromTrustedGateway 判断请求是否来自可信网关：配置了 trusted_proxies 时直接连接的地址需在其中，
+// 配置了 gateway_secret 时需携带该密钥，两者都配置时需同时满足
+func fromTrustedGateway(c *gin.Context, config conf.AccountsConfig) bool {
+	if len(config.TrustedProxies) == 0 && config.GatewaySecret == "" {
+		return false
+	}
+	if config.GatewaySecret != "" &&
+		subtle.ConstantTimeCompare([]byte(c.GetHeader(gatewaySecretHeader)), []byte(config.GatewaySecret)) != 1 {
+		return false
+	}
+	if len(config.TrustedProxies) == 0 {
+		return true
+	}
+	// 使用直接连接的地址而不是 X-Forwarded-For，后者可以被客户端伪造
+	host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	networks, err := config.TrustedNetworks()
+	if ip == nil || err != nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// currentUser 返回当前钉钉用户：Bearer Token 的抖音账号已关联时为关联的钉钉用户，否则为可信网关传入的钉钉用户标识
+func currentUser(c *gin.Context, bearer *storage.TokenInfo) string {
+	if owner, ok := storage.AccountLinks(appNamespace(c)).Owner(bearer.OpenID); ok {
+		return owner
+	}
+	return dingTalkUser(c)
+}
+
+// accessibleAccount 为当前钉钉用户可以访问的抖音账号
+type accessibleAccount struct {
+	*storage.LinkedAccount
+	Owner string
+	// Role 为他人授予当前用户的角色，账号为当前用户自己关联的账号时为空
+	Role storage.Role
+}
+
+// roleName 返回当前用户在账号上的角色名称
+func (a *accessibleAccount) roleName() string {
+	if a.Role == "" {
+		return roleOwner
+	}
+	return string(a.Role)
+}
+
+// accessibleAccounts 返回当前钉钉用户及其可以访问的抖音账号：自己关联的账号（主账号在前）及他人授予的账号；
+// Bearer Token 的抖音账号未关联钉钉用户时，自己的账号只有该账号
+func accessibleAccounts(c *gin.Context, bearer *storage.TokenInfo) (string, []*accessibleAccount) {
+	namespace := appNamespace(c)
+	links := storage.AccountLinks(namespace)
+	user := currentUser(c, bearer)
+	var accounts []*accessibleAccount
+	if owner, ok := links.Owner(bearer.OpenID); ok {
+		for _, linked := range links.Accounts(owner) {
+			accounts = append(accounts, &accessibleAccount{LinkedAccount: linked, Owner: owner})
+		}
+	} else {
+		accounts = append(accounts, &accessibleAccount{
+			LinkedAccount: &storage.LinkedAccount{OpenID: bearer.OpenID, Primary: true, LinkedAt: bearer.IssuedAt},
+			Owner:         user,
+		})
+	}
+	if user == "" {
+		return user, accounts
+	}
+	received := storage.Workspaces(namespace).Grants(func(grant *storage.AccountGrant) bool {
+		return grant.User == user
+	})
+	for _, grant := range received {
+		linked, owner, ok := links.Find(grant.OpenID)
+		// 账号改为关联到其他钉钉用户后，原所有者的授予失效
+		if !ok || owner != grant.GrantedBy || owner == user {
+			continue
+		}
+		accounts = append(accounts, &accessibleAccount{LinkedAccount: linked, Owner: owner, Role: grant.Role})
+	}
+	return user, accounts
+}
+
+// findAccount 按 openId 或昵称查找账号，account 为空时返回第一个账号
+func findAccount(accounts []*accessibleAccount, account string) *accessibleAccount {
+	for _, a := range accounts {
+		if account == "" || a.OpenID == account || (a.Nickname != "" && a.Nickname == account) {
+			return a
+		}
+	}
+	return nil
+}
+
+// appNamespace 返回处理当前请求的应用的 Token 存储命名空间
+func appNamespace(c *gin.Context) string {
+	if app := apps.FromContext(c.Request.Context()); app != nil {
+		return app.Namespace
+	}
+	return ""
+}
+
+// SelectAccount 按 account 参数在当前钉钉用户可以访问的抖音账号中选择账号，未指定时选择主账号，
+// 访问他人共享的账号时需要被授予 role，每次访问都会记录；之后的处理使用所选账号最近颁发的 Token，需在 RequireScope 之后执行
+func SelectAccount(scope string, role storage.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bearer, ok := tokenInfoFromContext(c)
+		if !ok {
+			c.Next()
+			return
+		}
+		user, accounts := accessibleAccounts(c, bearer)
+		c.Set(auditUserKey, user)
+		account := c.Query("account")
+		selected := findAccount(accounts, account)
+		record := &storage.AccessRecord{Time: time.Now(), User: user, OpenID: account, Action: c.FullPath()}
+		if selected == nil {
+			record.Reason = "account is not accessible"
+			recordAccess(c, record)
+			respondError(c, NewActionError(KindNotFound,
+				fmt.Sprintf("没有关联或被共享抖音账号 %s，可通过 /accounts 查看可以访问的账号", account), nil))
+			c.Abort()
+			return
+		}
+		record.OpenID = selected.OpenID
+		record.Role = selected.Role
+		if selected.Role != "" && !selected.Role.Allows(role) {
+			record.Reason = fmt.Sprintf("role %s is required", role)
+			recordAccess(c, record)
+			respondError(c, NewActionError(KindForbidden,
+				fmt.Sprintf("抖音账号 %s 的所有者只授予了 %s 角色，该操作需要 %s 角色", selected.OpenID, selected.Role, role), nil))
+			c.Abort()
+			return
+		}
+		record.Allowed = true
+		recordAccess(c, record)
+		if selected.OpenID == bearer.OpenID {
+			c.Next()
+			return
+		}
+
+		info, err := storage.Tokens(appNamespace(c)).LatestByOpenID(selected.OpenID)
+		if err != nil || info.IsExpired(time.Now()) {
+			abortWithBearerError(c, KindUnauthorized,
+				fmt.Sprintf("authorization of douyin account %s is unknown or expired, please re-authorize it", selected.OpenID), scope)
+			return
+		}
+		if scope != "" && !info.HasScope(scope) {
+			abortWithBearerError(c, KindInsufficientScope,
+				fmt.Sprintf("douyin account %s has not granted scope %q, please re-authorize it", selected.OpenID, scope), scope)
+			return
+		}
+		logging.SetOpenID(c.Request.Context(), info.OpenID)
+		c.Set(tokenInfoKey, info)
+		c.Next()
+	}
+}
+
+// recordAccess 记录一次账号访问，记录失败不影响请求
+func recordAccess(c *gin.Context, record *storage.AccessRecord) {
+	if err := storage.AccessLogs(appNamespace(c)).Record(record); err != nil {
+		logging.FromContext(c.Request.Context()).Warnf("record account access failed: %+v", err)
+	}
+}
+
+// selectedToken 返回 SelectAccount 选择的抖音账号的 Token
+func selectedToken(c *gin.Context) (*storage.TokenInfo, error) {
+	info, ok := tokenInfoFromContext(c)
+	if !ok {
+		return nil, NewActionError(KindUnauthorized, "access token is unknown or expired", nil)
+	}
+	return info, nil
+}
+
+// GetAccounts 列出当前钉钉用户关联及被共享的抖音账号及其授权状态
+func (bc *BizController) GetAccounts(c *gin.Context) {
+	bearer, err := selectedToken(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	tokens := storage.Tokens(appNamespace(c))
+	now := time.Now()
+	_, accounts := accessibleAccounts(c, bearer)
+	response := &models.GetAccountsResponse{Accounts: []*models.AccountItem{}}
+	for _, account := range accounts {
+		item := &models.AccountItem{
+			OpenID:   account.OpenID,
+			Nickname: account.Nickname,
+			Primary:  account.Primary && account.Role == "",
+			Owner:    account.Owner,
+			Role:     account.roleName(),
+			Scopes:   []string{},
+			Expired:  true,
+			LinkedAt: account.LinkedAt.Format(time.RFC3339),
+		}
+		if info, err := tokens.LatestByOpenID(account.OpenID); err == nil {
+			item.Scopes = append(item.Scopes, info.Scopes...)
+			item.Expired = info.IsExpired(now)
+		}
+		response.Accounts = append(response.Accounts, item)
+	}
+	c.JSON(http.StatusOK, response)
+}
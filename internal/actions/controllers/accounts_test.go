@@ -0,0 +1,245 @@
+package controllers
+
+import (
+	"douyin-action-example/internal/actions/models"
+	"douyin-action-example/internal/actions/storage"
+	"douyin-action-example/internal/conf"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const (
+	testUserHeader    = "X-Dingtalk-User"
+	testGatewaySecret = "gateway-secret-0123456789"
+)
+
+// useAccountsConfig 使用以共享密钥识别可信网关的多账号配置：alice 关联了 open-a 并将其以 read 角色共享给 bob，
+// bob 的 Token 对应未关联的 open-b
+func useAccountsConfig(t *testing.T) {
+	t.Helper()
+	config := testAppsConfig()
+	config.Accounts = conf.AccountsConfig{UserHeader: testUserHeader, GatewaySecret: testGatewaySecret}
+	useConfig(t, config)
+	now := time.Now()
+	for _, info := range []*storage.TokenInfo{
+		{AccessToken: "alice-token", OpenID: "open-a", ClientID: "alpha-client"},
+		{AccessToken: "bob-token", OpenID: "open-b", ClientID: "alpha-client"},
+	} {
+		info.Scopes = []string{models.ScopeUserInfo}
+		info.IssuedAt, info.ExpiresAt = now, now.Add(time.Hour)
+		mustSave(t, "alpha", info)
+	}
+	if err := storage.AccountLinks("alpha").Link("alice", "open-a", now); err != nil {
+		t.Fatal(err)
+	}
+	err := storage.Workspaces("alpha").Grant(&storage.AccountGrant{
+		OpenID: "open-a", User: "bob", Role: storage.RoleRead, GrantedBy: "alice", GrantedAt: now,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// selectAccount 以 token 及网关请求头请求需要 role 角色的动作，返回响应及动作使用的 Token 的 openId
+func selectAccount(role storage.Role, token, user, secret, account string) (*httptest.ResponseRecorder, string) {
+	var selected string
+	engine := gin.New()
+	engine.GET("/action", RequireScope(models.ScopeUserInfo), SelectAccount(models.ScopeUserInfo, role), func(c *gin.Context) {
+		info, _ := tokenInfoFromContext(c)
+		selected = info.OpenID
+		c.Status(http.StatusOK)
+	})
+	request := httptest.NewRequest(http.MethodGet, "/action?account="+account, nil)
+	request.Header.Set("Authorization", "Bearer "+token)
+	if user != "" {
+		request.Header.Set(testUserHeader, user)
+	}
+	if secret != "" {
+		request.Header.Set(gatewaySecretHeader, secret)
+	}
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, request)
+	return recorder, selected
+}
+
+func TestSelectAccountRoles(t *testing.T) {
+	useAccountsConfig(t)
+
+	for _, tc := range []struct {
+		name     string
+		role     storage.Role
+		token    string
+		user     string
+		secret   string
+		account  string
+		status   int
+		selected string
+	}{
+		{"owner", storage.RoleWrite, "alice-token", "", "", "", http.StatusOK, "open-a"},
+		{"read grant", storage.RoleRead, "bob-token", "bob", testGatewaySecret, "open-a", http.StatusOK, "open-a"},
+		{"read grant without write", storage.RoleWrite, "bob-token", "bob", testGatewaySecret, "open-a", http.StatusForbidden, ""},
+		{"not granted", storage.RoleRead, "bob-token", "carol", testGatewaySecret, "open-a", http.StatusNotFound, ""},
+		{"untrusted user header", storage.RoleRead, "bob-token", "bob", "", "open-a", http.StatusNotFound, ""},
+		{"wrong gateway secret", storage.RoleRead, "bob-token", "bob", "not-the-gateway-secret", "open-a", http.StatusNotFound, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder, selected := selectAccount(tc.role, tc.token, tc.user, tc.secret, tc.account)
+			if recorder.Code != tc.status || selected != tc.selected {
+				t.Errorf("got status %d selected %q, want %d %q: %s", recorder.Code, selected, tc.status, tc.selected, recorder.Body)
+			}
+		})
+	}
+
+	records, err := storage.AccessLogs("alpha").Records(func(record *storage.AccessRecord) bool {
+		return record.User == "bob"
+	}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Allowed || records[0].Role != storage.RoleRead || !records[1].Allowed {
+		t.Errorf("unexpected access records of bob: %+v", records)
+	}
+}
+
+func TestDingTalkUserTrustedProxies(t *testing.T) {
+	config := testAppsConfig()
+	config.Accounts = conf.AccountsConfig{UserHeader: testUserHeader, TrustedProxies: []string{"10.0.0.0/8"}}
+	useConfig(t, config)
+
+	for remoteAddr, want := range map[string]string{"10.1.2.3:40000": "bob", "192.0.2.1:40000": ""} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Request.RemoteAddr = remoteAddr
+		c.Request.Header.Set(testUserHeader, "bob")
+		// X-Forwarded-For 可以被客户端伪造，不影响可信网关的判断
+		c.Request.Header.Set("X-Forwarded-For", "10.1.2.3")
+		if user := dingTalkUser(c); user != want {
+			t.Errorf("dingTalkUser from %s = %q, want %q", remoteAddr, user, want)
+		}
+	}
+}
+
+func TestTokenLinksAccountToDingTalkUser(t *testing.T) {
+	useAccountsConfig(t)
+	fakeTokenEndpoint(t, models.ScopeUserInfo)
+	storage.GrantService.Save("code-alice", &storage.AuthorizationGrant{App: "alpha", User: "alice"})
+
+	if recorder := exchangeToken("alpha-client", "alpha-secret", "code-alice", ""); recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", recorder.Code, recorder.Body)
+	}
+	// 新授权的账号关联到授权时的钉钉用户，已有主账号时不改变主账号
+	accounts := storage.AccountLinks("alpha").Accounts("alice")
+	if len(accounts) != 2 || accounts[0].OpenID != "open-a" || accounts[1].OpenID != "open-1" || accounts[1].Primary {
+		t.Fatalf("accounts of alice = %+v", accounts)
+	}
+}
+
+// linkSecondAccount 为 alice 关联昵称为 second 的 open-c，其 Token 为 alice-c-token
+func linkSecondAccount(t *testing.T) {
+	t.Helper()
+	now := time.Now()
+	mustSave(t, "alpha", &storage.TokenInfo{AccessToken: "alice-c-token", OpenID: "open-c", ClientID: "alpha-client",
+		Scopes: []string{models.ScopeUserInfo}, IssuedAt: now, ExpiresAt: now.Add(time.Hour)})
+	links := storage.AccountLinks("alpha")
+	if err := links.Link("alice", "open-c", now); err != nil {
+		t.Fatal(err)
+	}
+	if err := links.SetNickname("open-c", "second"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSelectAccountAmongLinkedAccounts(t *testing.T) {
+	useAccountsConfig(t)
+	linkSecondAccount(t)
+
+	// 任一关联账号的 Token 都可以按 open_id 或昵称选择同一钉钉用户的其他账号，未指定时选择主账号
+	for _, tc := range []struct{ token, account, selected string }{
+		{"alice-token", "", "open-a"},
+		{"alice-c-token", "", "open-a"},
+		{"alice-token", "open-c", "open-c"},
+		{"alice-token", "second", "open-c"},
+	} {
+		recorder, selected := selectAccount(storage.RoleWrite, tc.token, "", "", tc.account)
+		if recorder.Code != http.StatusOK || selected != tc.selected {
+			t.Errorf("%s selecting %q: status %d selected %q, want %q", tc.token, tc.account, recorder.Code, selected, tc.selected)
+		}
+	}
+	if recorder, _ := selectAccount(storage.RoleWrite, "alice-token", "", "", "open-b"); recorder.Code != http.StatusNotFound {
+		t.Errorf("account of another user: status = %d", recorder.Code)
+	}
+
+	if err := storage.AccountLinks("alpha").SetPrimary("open-c"); err != nil {
+		t.Fatal(err)
+	}
+	if recorder, selected := selectAccount(storage.RoleWrite, "alice-token", "", "", ""); recorder.Code != http.StatusOK || selected != "open-c" {
+		t.Errorf("default account after SetPrimary: status %d selected %q", recorder.Code, selected)
+	}
+
+	// 所选账号没有有效的 Token 时要求重新授权该账号
+	if err := storage.Tokens("alpha").Delete("alice-c-token"); err != nil {
+		t.Fatal(err)
+	}
+	if recorder, _ := selectAccount(storage.RoleWrite, "alice-token", "", "", "second"); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("account without a token: status = %d", recorder.Code)
+	}
+}
+
+// getAccounts 以 token 及网关请求头请求 /accounts
+func getAccounts(t *testing.T, token, user string) []*models.AccountItem {
+	t.Helper()
+	engine := gin.New()
+	engine.GET("/accounts", RequireScope(models.ScopeUserInfo), NewBizController().GetAccounts)
+	request := httptest.NewRequest(http.MethodGet, "/accounts", nil)
+	request.Header.Set("Authorization", "Bearer "+token)
+	if user != "" {
+		request.Header.Set(testUserHeader, user)
+		request.Header.Set(gatewaySecretHeader, testGatewaySecret)
+	}
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", recorder.Code, recorder.Body)
+	}
+	response := &models.GetAccountsResponse{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), response); err != nil {
+		t.Fatal(err)
+	}
+	return response.Accounts
+}
+
+func TestGetAccounts(t *testing.T) {
+	useAccountsConfig(t)
+	linkSecondAccount(t)
+	if err := storage.Tokens("alpha").Delete("alice-c-token"); err != nil {
+		t.Fatal(err)
+	}
+
+	accounts := getAccounts(t, "alice-token", "")
+	if len(accounts) != 2 {
+		t.Fatalf("accounts of alice = %+v", accounts)
+	}
+	if a := accounts[0]; a.OpenID != "open-a" || !a.Primary || a.Owner != "alice" || a.Role != roleOwner || a.Expired ||
+		len(a.Scopes) != 1 || a.Scopes[0] != models.ScopeUserInfo {
+		t.Errorf("primary account = %+v", a)
+	}
+	if a := accounts[1]; a.OpenID != "open-c" || a.Nickname != "second" || a.Primary || !a.Expired || len(a.Scopes) != 0 {
+		t.Errorf("second account = %+v", a)
+	}
+
+	// 未关联的账号只列出自身，以及他人共享给当前钉钉用户的账号
+	accounts = getAccounts(t, "bob-token", "bob")
+	if len(accounts) != 2 {
+		t.Fatalf("accounts of bob = %+v", accounts)
+	}
+	if a := accounts[0]; a.OpenID != "open-b" || !a.Primary || a.Owner != "bob" || a.Role != roleOwner {
+		t.Errorf("own account of bob = %+v", a)
+	}
+	if a := accounts[1]; a.OpenID != "open-a" || a.Primary || a.Owner != "alice" || a.Role != string(storage.RoleRead) {
+		t.Errorf("shared account = %+v", a)
+	}
+}
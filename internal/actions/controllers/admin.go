@@ -0,0 +1,211 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"douyin-action-example/internal/actions/assets"
+	"douyin-action-example/internal/actions/models"
+	"douyin-action-example/internal/actions/storage"
+	"douyin-action-example/internal/apps"
+	"douyin-action-example/internal/conf"
+	"douyin-action-example/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// RequireAdmin 校验管理接口的 Bearer 凭证，凭证为 admin.token，与用户 Token 相互独立
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, err := GetBearerToken(c.Request)
+		if err != nil || subtle.ConstantTimeCompare([]byte(token), []byte(conf.App.Admin.Token)) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+			respondError(c, NewActionError(KindUnauthorized, "admin credential is missing or invalid", nil))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+type AdminController struct {
+}
+
+func NewAdminController() *AdminController {
+	return &AdminController{}
+}
+
+// Console 返回内嵌的管理控制台页面，页面通过管理接口加载数据，本身不包含任何状态
+func (ac *AdminController) Console(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(assets.AdminConsoleHtml))
+}
+
+// adminApps 返回请求参数 app 指定的应用，未指定时返回全部应用
+func adminApps(c *gin.Context) ([]*apps.App, bool) {
+	id := c.Query("app")
+	if id == "" {
+		return apps.All(), true
+	}
+	app, ok := apps.ByID(id)
+	if !ok {
+		respondError(c, NewActionError(KindNotFound, "app is not registered", nil))
+		return nil, false
+	}
+	return []*apps.App{app}, true
+}
+
+// Accounts 列出各应用中已关联或持有 Token 的抖音账号及其 Token 过期时间、最近错误数
+func (ac *AdminController) Accounts(c *gin.Context) {
+	all, ok := adminApps(c)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	response := &models.AdminAccountsResponse{Accounts: []*models.AdminAccountItem{}}
+	for _, app := range all {
+		tokens := storage.Tokens(app.Namespace)
+		seen := make(map[string]bool)
+		item := func(openId string) *models.AdminAccountItem {
+			seen[openId] = true
+			item := &models.AdminAccountItem{
+				App:          app.ID,
+				OpenID:       openId,
+				Expired:      true,
+				RecentErrors: RecentErrorCount(app.Namespace, openId),
+			}
+			if info, err := tokens.LatestByOpenID(openId); err == nil {
+				item.ExpiresAt = info.ExpiresAt.Format(time.RFC3339)
+				item.Expired = info.IsExpired(now)
+			}
+			return item
+		}
+
+		users := storage.AccountLinks(app.Namespace).Users()
+		names := make([]string, 0, len(users))
+		for name := range users {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for _, linked := range users[name] {
+				account := item(linked.OpenID)
+				account.User = name
+				account.Nickname = linked.Nickname
+				account.Primary = linked.Primary
+				account.LinkedAt = linked.LinkedAt.Format(time.RFC3339)
+				response.Accounts = append(response.Accounts, account)
+			}
+		}
+		// 未配置 accounts.user_header 时 Token 不关联钉钉用户，同样列出
+		for _, info := range tokens.List() {
+			if !seen[info.OpenID] {
+				response.Accounts = append(response.Accounts, item(info.OpenID))
+			}
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Tokens 列出各应用的 Token，不返回 Token 值
+func (ac *AdminController) Tokens(c *gin.Context) {
+	all, ok := adminApps(c)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	response := &models.AdminTokensResponse{Tokens: []*models.AdminTokenItem{}}
+	for _, app := range all {
+		links := storage.AccountLinks(app.Namespace)
+		for _, info := range storage.Tokens(app.Namespace).List() {
+			user, _ := links.Owner(info.OpenID)
+			response.Tokens = append(response.Tokens, &models.AdminTokenItem{
+				ID:              info.ID(),
+				App:             app.ID,
+				OpenID:          info.OpenID,
+				User:            user,
+				ClientID:        info.ClientID,
+				Scopes:          append([]string{}, info.Scopes...),
+				IssuedAt:        info.IssuedAt.Format(time.RFC3339),
+				ExpiresAt:       info.ExpiresAt.Format(time.RFC3339),
+				Expired:         info.IsExpired(now),
+				HasRefreshToken: info.RefreshToken != "",
+			})
+		}
+	}
+	sort.SliceStable(response.Tokens, func(i, j int) bool {
+		return response.Tokens[i].ExpiresAt < response.Tokens[j].ExpiresAt
+	})
+	c.JSON(http.StatusOK, response)
+}
+
+// Errors 列出抖音账号最近的错误
+func (ac *AdminController) Errors(c *gin.Context) {
+	query := &models.AdminErrorsQuery{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		respondError(c, NewActionError(KindBadRequest, "invalid query parameter: "+err.Error(), err))
+		return
+	}
+	all, ok := adminApps(c)
+	if !ok {
+		return
+	}
+	var items []*models.AdminErrorItem
+	for _, app := range all {
+		for _, e := range RecentErrors(app.Namespace, query.Account) {
+			items = append(items, &models.AdminErrorItem{
+				Time:        e.Time.Format(time.RFC3339),
+				App:         app.ID,
+				OpenID:      e.OpenID,
+				Action:      e.Action,
+				Status:      e.Status,
+				Error:       string(e.Kind),
+				Description: e.Description,
+				ErrorCode:   float64(e.DouYinCode),
+			})
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Time > items[j].Time
+	})
+	response := &models.AdminErrorsResponse{Errors: []*models.AdminErrorItem{}}
+	response.Errors = append(response.Errors, items...)
+	c.JSON(http.StatusOK, response)
+}
+
+// RateLimits 列出各应用按抖音账号及按抖音 API 路径的限流状态
+func (ac *AdminController) RateLimits(c *gin.Context) {
+	all, ok := adminApps(c)
+	if !ok {
+		return
+	}
+	response := &models.AdminRateLimitsResponse{Enabled: conf.App.RateLimit.Enabled, Limits: []*models.AdminRateLimitItem{}}
+	appendStatuses := func(app *apps.App, kind string, limiter *ratelimit.Limiter) {
+		if limiter == nil {
+			return
+		}
+		statuses := limiter.Statuses()
+		sort.Slice(statuses, func(i, j int) bool {
+			return statuses[i].Key < statuses[j].Key
+		})
+		for _, status := range statuses {
+			item := &models.AdminRateLimitItem{
+				App:       app.ID,
+				Kind:      kind,
+				Key:       status.Key,
+				Tokens:    status.Tokens,
+				DailyUsed: status.DailyUsed,
+			}
+			if !status.BlockedUntil.IsZero() {
+				item.BlockedUntil = status.BlockedUntil.Format(time.RFC3339)
+			}
+			response.Limits = append(response.Limits, item)
+		}
+	}
+	for _, app := range all {
+		user, endpoint := Limiters(app)
+		appendStatuses(app, "user", user)
+		appendStatuses(app, "endpoint", endpoint)
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,258 @@
+package controllers
+
+import (
+	"douyin-action-example/internal/actions/assets"
+	"douyin-action-example/internal/actions/models"
+	"douyin-action-example/internal/actions/storage"
+	"douyin-action-example/internal/apps"
+	"douyin-action-example/internal/conf"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testAdminToken = "admin-token-0123456789"
+
+// useAdminToken 为当前配置设置管理凭证，并丢弃之前记录的最近错误
+func useAdminToken(t *testing.T) {
+	t.Helper()
+	conf.App.Admin.Token = testAdminToken
+	resetRecentErrors := func() {
+		recentErrorsMu.Lock()
+		recentErrors = make(map[string]*accountErrors)
+		recentErrorsMu.Unlock()
+	}
+	resetRecentErrors()
+	t.Cleanup(resetRecentErrors)
+}
+
+// adminGet 以 token 按 server.go 的路由请求管理接口或控制台
+func adminGet(path, token string) *httptest.ResponseRecorder {
+	engine := gin.New()
+	adc := NewAdminController()
+	engine.GET("/admin", adc.Console)
+	admin := engine.Group("/admin/api", RequireAdmin())
+	admin.GET("/accounts", adc.Accounts)
+	admin.GET("/tokens", adc.Tokens)
+	admin.GET("/errors", adc.Errors)
+	admin.GET("/ratelimits", adc.RateLimits)
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// adminJSON 以管理凭证请求管理接口并解析响应
+func adminJSON(t *testing.T, path string, response interface{}) {
+	t.Helper()
+	recorder := adminGet(path, testAdminToken)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("%s: status = %d, body %s", path, recorder.Code, recorder.Body)
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), response); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// failAction 模拟应用 appId 中抖音账号 openId 的一次失败请求
+func failAction(t *testing.T, appId, openId string, err error) {
+	t.Helper()
+	app, ok := apps.ByID(appId)
+	if !ok {
+		t.Fatalf("app %s is not registered", appId)
+	}
+	serve(http.MethodGet, "/video/list", func(c *gin.Context) {
+		c.Request = c.Request.WithContext(apps.WithApp(c.Request.Context(), app))
+		c.Set(tokenInfoKey, &storage.TokenInfo{OpenID: openId})
+		respondError(c, err)
+	}, httptest.NewRequest(http.MethodGet, "/video/list", nil))
+}
+
+func TestRequireAdmin(t *testing.T) {
+	useAccountsConfig(t)
+	useAdminToken(t)
+
+	// 用户 Token 不能访问管理接口
+	for _, token := range []string{"", "not-the-admin-token", "alice-token"} {
+		recorder := adminGet("/admin/api/tokens", token)
+		if recorder.Code != http.StatusUnauthorized {
+			t.Fatalf("token %q: status = %d", token, recorder.Code)
+		}
+		if got := recorder.Header().Get("WWW-Authenticate"); got != `Bearer realm="admin"` {
+			t.Fatalf("token %q: WWW-Authenticate = %q", token, got)
+		}
+		if body := serviceError(t, recorder); body.Error != string(KindUnauthorized) {
+			t.Fatalf("token %q: body = %+v", token, body)
+		}
+	}
+	if recorder := adminGet("/admin/api/tokens", testAdminToken); recorder.Code != http.StatusOK {
+		t.Fatalf("admin token: status = %d, body %s", recorder.Code, recorder.Body)
+	}
+	// 管理凭证也不能用作用户 Token
+	if recorder, _ := selectAccount(storage.RoleRead, testAdminToken, "", "", ""); recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("admin token used as user token: status = %d", recorder.Code)
+	}
+}
+
+func TestAdminConsole(t *testing.T) {
+	useConfig(t, testAppsConfig())
+	useAdminToken(t)
+
+	// 控制台页面不需要凭证，数据通过管理接口加载
+	recorder := adminGet("/admin", "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d", recorder.Code)
+	}
+	if got := recorder.Header().Get("Content-Type"); got != "text/html; charset=utf-8" {
+		t.Fatalf("Content-Type = %q", got)
+	}
+	if got := recorder.Header().Get("Cache-Control"); got != "no-store" {
+		t.Fatalf("Cache-Control = %q", got)
+	}
+	if body := recorder.Body.String(); body != assets.AdminConsoleHtml || !strings.Contains(body, "/admin/api/") {
+		t.Fatalf("console is not the embedded page: %.200s", body)
+	}
+}
+
+func TestAdminAccounts(t *testing.T) {
+	useAccountsConfig(t)
+	useAdminToken(t)
+	failAction(t, "alpha", "open-a", BadRequest("cursor must be a number"))
+	failAction(t, "alpha", "open-a", BadRequest("cursor must be a number"))
+
+	response := &models.AdminAccountsResponse{}
+	adminJSON(t, "/admin/api/accounts", response)
+	if len(response.Accounts) != 2 {
+		t.Fatalf("accounts = %+v", response.Accounts)
+	}
+	// 已关联的账号在前，未关联钉钉用户但持有 Token 的账号同样列出
+	if a := response.Accounts[0]; a.App != "alpha" || a.User != "alice" || a.OpenID != "open-a" || !a.Primary ||
+		a.LinkedAt == "" || a.ExpiresAt == "" || a.Expired || a.RecentErrors != 2 {
+		t.Errorf("linked account = %+v", a)
+	}
+	if a := response.Accounts[1]; a.App != "alpha" || a.User != "" || a.OpenID != "open-b" || a.Primary || a.Expired || a.RecentErrors != 0 {
+		t.Errorf("unlinked account = %+v", a)
+	}
+
+	adminJSON(t, "/admin/api/accounts?app=beta", response)
+	if len(response.Accounts) != 0 {
+		t.Errorf("accounts of beta = %+v", response.Accounts)
+	}
+	if recorder := adminGet("/admin/api/accounts?app=unknown", testAdminToken); recorder.Code != http.StatusNotFound {
+		t.Errorf("unknown app: status = %d", recorder.Code)
+	}
+}
+
+func TestAdminTokens(t *testing.T) {
+	useAccountsConfig(t)
+	useAdminToken(t)
+	now := time.Now()
+	mustSave(t, "beta", &storage.TokenInfo{AccessToken: "beta-token", RefreshToken: "beta-refresh", OpenID: "open-z", ClientID: "beta-client",
+		IssuedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour)})
+
+	recorder := adminGet("/admin/api/tokens", testAdminToken)
+	for _, secret := range []string{"alice-token", "bob-token", "beta-token", "beta-refresh"} {
+		if strings.Contains(recorder.Body.String(), secret) {
+			t.Fatalf("response leaks %s: %s", secret, recorder.Body)
+		}
+	}
+	response := &models.AdminTokensResponse{}
+	adminJSON(t, "/admin/api/tokens", response)
+	if len(response.Tokens) != 3 {
+		t.Fatalf("tokens = %+v", response.Tokens)
+	}
+	// 按过期时间排序，最先过期的在前
+	expired := response.Tokens[0]
+	if expired.App != "beta" || expired.OpenID != "open-z" || expired.ID != (&storage.TokenInfo{AccessToken: "beta-token"}).ID() ||
+		!expired.Expired || !expired.HasRefreshToken {
+		t.Errorf("expired token = %+v", expired)
+	}
+	owners := make(map[string]string)
+	for _, token := range response.Tokens[1:] {
+		owners[token.OpenID] = token.User
+	}
+	if owners["open-a"] != "alice" || owners["open-b"] != "" || len(owners) != 2 {
+		t.Errorf("owners of alpha tokens = %v", owners)
+	}
+
+	adminJSON(t, "/admin/api/tokens?app=alpha", response)
+	if len(response.Tokens) != 2 {
+		t.Errorf("tokens of alpha = %+v", response.Tokens)
+	}
+}
+
+func TestAdminErrors(t *testing.T) {
+	useConfig(t, testAppsConfig())
+	useAdminToken(t)
+	for i := 0; i < maxRecentErrors+5; i++ {
+		failAction(t, "alpha", "open-a", BadRequest(fmt.Sprintf("error %d", i)))
+	}
+	failAction(t, "alpha", "open-b", UpstreamError("/video/list/", 2190008, "access_token expired"))
+	failAction(t, "beta", "open-z", BadRequest("beta error"))
+
+	response := &models.AdminErrorsResponse{}
+	adminJSON(t, "/admin/api/errors?app=alpha&account=open-a", response)
+	// 每个账号只保留最近的错误
+	if len(response.Errors) != maxRecentErrors {
+		t.Fatalf("got %d errors of open-a, want %d", len(response.Errors), maxRecentErrors)
+	}
+	descriptions := make(map[string]bool)
+	for _, e := range response.Errors {
+		if e.App != "alpha" || e.OpenID != "open-a" || e.Action != "/video/list" || e.Status != http.StatusBadRequest || e.Error != string(KindBadRequest) {
+			t.Fatalf("error = %+v", e)
+		}
+		descriptions[e.Description] = true
+	}
+	if descriptions["error 4"] || !descriptions["error 5"] || !descriptions[fmt.Sprintf("error %d", maxRecentErrors+4)] {
+		t.Fatalf("retained errors = %v", descriptions)
+	}
+
+	adminJSON(t, "/admin/api/errors?account=open-b", response)
+	if len(response.Errors) != 1 || response.Errors[0].ErrorCode != 2190008 || response.Errors[0].Status != http.StatusUnauthorized {
+		t.Fatalf("errors of open-b = %+v", response.Errors)
+	}
+	adminJSON(t, "/admin/api/errors", response)
+	if len(response.Errors) != maxRecentErrors+2 {
+		t.Fatalf("got %d errors of all apps", len(response.Errors))
+	}
+	adminJSON(t, "/admin/api/errors?app=beta", response)
+	if len(response.Errors) != 1 || response.Errors[0].OpenID != "open-z" {
+		t.Fatalf("errors of beta = %+v", response.Errors)
+	}
+}
+
+func TestAdminRateLimits(t *testing.T) {
+	useRateLimit(t, conf.QuotaConfig{Rate: 0.01, Burst: 2})
+	useAdminToken(t)
+	alpha, _ := apps.ByID("alpha")
+	user, _ := Limiters(alpha)
+	user.Allow("open-a")
+	backOffEndpoint(alpha, "/video/list/")
+
+	response := &models.AdminRateLimitsResponse{}
+	adminJSON(t, "/admin/api/ratelimits", response)
+	if !response.Enabled || len(response.Limits) != 2 {
+		t.Fatalf("response = %+v", response)
+	}
+	if l := response.Limits[0]; l.App != "alpha" || l.Kind != "user" || l.Key != "open-a" || l.Tokens >= 2 || l.BlockedUntil != "" {
+		t.Errorf("user limit = %+v", l)
+	}
+	if l := response.Limits[1]; l.App != "alpha" || l.Kind != "endpoint" || l.Key != "/video/list/" || l.BlockedUntil == "" {
+		t.Errorf("endpoint limit = %+v", l)
+	}
+
+	// 未启用限流时只返回未启用
+	conf.App.RateLimit.Enabled = false
+	adminJSON(t, "/admin/api/ratelimits", response)
+	if response.Enabled || len(response.Limits) != 0 {
+		t.Fatalf("response without rate limiting = %+v", response)
+	}
+}
@@ -2,10 +2,30 @@ package controllers
 
 import (
 	"douyin-action-example/internal/actions/assets"
+	"douyin-action-example/internal/apps"
+	"douyin-action-example/internal/openapi"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"strings"
 )
 
+// openApiSpecHeader 为生成的 openapi.yaml 的文件头
+const openApiSpecHeader = "# Code generated by openapi-gen. DO NOT EDIT.\n"
+
+// GenerateOpenApiSpec 由全部业务动作生成 openapi.yaml，cmd/openapi-gen 将其写入 assets/openapi.yaml
+func GenerateOpenApiSpec() ([]byte, error) {
+	var endpoints []*openapi.Endpoint
+	for _, action := range NewBizController().Actions() {
+		endpoints = append(endpoints, action.Endpoint())
+	}
+	doc := openapi.Generate(openapi.Info{
+		Title:       "抖音运营助理",
+		Description: "抖音平台运营，获取抖音账号信息等数据",
+		Version:     "1.0.0",
+	}, assets.DefaultServerUrl, endpoints)
+	return openapi.Marshal(doc, openApiSpecHeader)
+}
+
 type AssetHandler struct {
 }
 
@@ -16,5 +36,29 @@ func NewAssetHandler() *AssetHandler {
 func (h *AssetHandler) OpenApiSpecYaml(c *gin.Context) {
 	c.Header("Content-Type", "text/yaml; charset=utf-8")
 	c.Header("Access-Control-Allow-Origin", "*")
-	c.String(http.StatusOK, assets.OpenApiSpecYaml)
+	c.String(http.StatusOK, strings.ReplaceAll(assets.OpenApiSpecYaml, assets.DefaultServerUrl, publicBaseUrl(c)))
+}
+
+// AppOpenApiSpecYaml 返回应用的 openapi.yaml，只包含应用开通的授权范围及可调用的业务动作
+func (h *AssetHandler) AppOpenApiSpecYaml(c *gin.Context) {
+	app, ok := apps.ByID(c.Param("app"))
+	if !ok {
+		respondError(c, NewActionError(KindNotFound, "app is not registered", nil))
+		return
+	}
+	// 每次重新解析内嵌的文档，避免修改 ApiSpec 共享的解析结果
+	doc, err := openapi.Parse([]byte(assets.OpenApiSpecYaml))
+	if err != nil {
+		respondError(c, InternalError(err))
+		return
+	}
+	doc.RestrictScopes(app.AllowsScope)
+	content, err := openapi.Marshal(doc, "")
+	if err != nil {
+		respondError(c, InternalError(err))
+		return
+	}
+	c.Header("Content-Type", "text/yaml; charset=utf-8")
+	c.Header("Access-Control-Allow-Origin", "*")
+	c.String(http.StatusOK, strings.ReplaceAll(string(content), assets.DefaultServerUrl, publicBaseUrl(c)))
 }
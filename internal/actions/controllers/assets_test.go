@@ -0,0 +1,17 @@
+package controllers
+
+import (
+	"douyin-action-example/internal/actions/assets"
+	"testing"
+)
+
+// TestOpenApiSpecUpToDate 保证提交的 openapi.yaml 与业务动作及 models 生成的文档逐字节一致
+func TestOpenApiSpecUpToDate(t *testing.T) {
+	generated, err := GenerateOpenApiSpec()
+	if err != nil {
+		t.Fatalf("generate openapi spec: %+v", err)
+	}
+	if string(generated) != assets.OpenApiSpecYaml {
+		t.Fatal("internal/actions/assets/openapi.yaml is out of date, run go generate ./internal/actions/assets")
+	}
+}
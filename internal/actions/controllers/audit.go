@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"bytes"
+	"douyin-action-example/internal/apps"
+	"douyin-action-example/internal/audit"
+	"douyin-action-example/internal/logging"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	// auditUserKey 为 SelectAccount 识别的当前钉钉用户，选择他人共享的账号后 Token 不再属于当前用户
+	auditUserKey = "auditUser"
+	// auditResultKey 为错误响应的错误分类及抖音错误码
+	auditResultKey = "auditResult"
+)
+
+type auditResult struct {
+	kind         ErrorKind
+	upstreamCode int64
+}
+
+// setAuditResult 记录错误响应的结果，供审计记录使用
+func setAuditResult(c *gin.Context, kind ErrorKind, upstreamCode int64) {
+	c.Set(auditResultKey, &auditResult{kind: kind, upstreamCode: upstreamCode})
+}
+
+// Audit 在业务动作处理完成后追加审计记录，需在 RequireScope 之前执行，以便未通过授权的调用同样被记录
+func Audit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !audit.Enabled() {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		params := requestParams(c)
+
+		c.Next()
+
+		ctx := c.Request.Context()
+		record := &audit.Record{
+			Time:      start,
+			RequestID: logging.RequestID(ctx),
+			Method:    c.Request.Method,
+			Action:    c.FullPath(),
+			Params:    logging.RedactParams(params),
+			Status:    c.Writer.Status(),
+			LatencyMs: time.Since(start).Milliseconds(),
+		}
+		if app := apps.FromContext(ctx); app != nil {
+			record.App = app.ID
+		}
+		if info, ok := tokenInfoFromContext(c); ok {
+			record.OpenID = info.OpenID
+			record.User = c.GetString(auditUserKey)
+			if record.User == "" {
+				record.User = currentUser(c, info)
+			}
+		} else {
+			record.User = dingTalkUser(c)
+		}
+		if value, ok := c.Get(auditResultKey); ok {
+			result := value.(*auditResult)
+			record.Error = string(result.kind)
+			record.UpstreamCode = result.upstreamCode
+		}
+		if err := audit.Write(record); err != nil {
+			logging.FromContext(ctx).Errorf("write audit record failed, action=%s, err=%+v", record.Action, err)
+		}
+	}
+}
+
+// requestParams 返回查询参数及 JSON 请求体，请求体读取后会被还原，供之后的处理使用
+func requestParams(c *gin.Context) map[string]interface{} {
+	params := make(map[string]interface{})
+	for name, values := range c.Request.URL.Query() {
+		if len(values) == 1 {
+			params[name] = values[0]
+			continue
+		}
+		items := make([]interface{}, len(values))
+		for i, value := range values {
+			items[i] = value
+		}
+		params[name] = items
+	}
+	if c.Request.Body == nil || !strings.HasPrefix(c.ContentType(), "application/json") {
+		return params
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil || len(body) == 0 {
+		return params
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err == nil {
+		params["body"] = decoded
+	}
+	return params
+}
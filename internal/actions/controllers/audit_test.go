@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"douyin-action-example/internal/audit"
+	"douyin-action-example/internal/conf"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuditRedactsParams(t *testing.T) {
+	useConfig(t, testAppsConfig())
+	path := filepath.Join(t.TempDir(), "audit.log")
+	if err := audit.Init(conf.AuditConfig{Enabled: true, Sink: "file", Path: path, MaxSizeMB: 1}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = audit.Close() })
+
+	engine := gin.New()
+	engine.POST("/action", Audit(), RequireScope(""), func(c *gin.Context) { c.Status(http.StatusOK) })
+	request := httptest.NewRequest(http.MethodPost, "/action?keyword=douyin&access_token=query-token",
+		strings.NewReader(`{"code":"auth-code","note":"retry with refresh_token=body-refresh","nested":{"client_secret":"body-secret"}}`))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "Bearer header-token")
+	engine.ServeHTTP(httptest.NewRecorder(), request)
+	if err := audit.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := string(content)
+	for _, secret := range []string{"query-token", "auth-code", "body-refresh", "body-secret", "header-token"} {
+		if strings.Contains(record, secret) {
+			t.Errorf("audit record leaks %q: %s", secret, record)
+		}
+	}
+	// 非敏感参数及状态码照常记录
+	for _, expected := range []string{`"keyword":"douyin"`, `"status":401`, `"error":"invalid_token"`} {
+		if !strings.Contains(record, expected) {
+			t.Errorf("audit record misses %s: %s", expected, record)
+		}
+	}
+}
@@ -2,21 +2,28 @@ package controllers
 
 import (
 	"bytes"
+	"context"
 	"douyin-action-example/internal/actions/models"
 	"douyin-action-example/internal/actions/storage"
+	"douyin-action-example/internal/apps"
 	"douyin-action-example/internal/conf"
+	"douyin-action-example/internal/logging"
+	"douyin-action-example/internal/metrics"
 	"encoding/json"
 	"fmt"
-	"github.com/chzealot/gobase/logger"
-	"github.com/chzealot/gobase/utils"
 	"github.com/gin-gonic/gin"
-	"io"
+	"github.com/gin-gonic/gin/binding"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 )
 
-const getTokenUrl string = "https://open.douyin.com/oauth/access_token/"
+const getTokenPath string = "/oauth/access_token/"
+const authorizePath string = "/platform/oauth/connect/"
+
+// grantTypeAuthorizationCode 为 Token 接口唯一支持的 grant_type
+const grantTypeAuthorizationCode = "authorization_code"
 
 type AuthController struct {
 }
@@ -27,113 +34,410 @@ func NewAuthController() *AuthController {
 
 func (ac *AuthController) Authorize(c *gin.Context) {
 	if conf.IsDebugMode {
-		utils.DumpHttpRequest(c.Request)
+		logging.DumpRequest(c.Request)
 	}
 	clientId := c.Query("client_id")
 	redirectUri := c.Query("redirect_uri")
-	scope := c.Query("scope")
-	scope = strings.ReplaceAll(scope, ",", " ")
-	scope = strings.ReplaceAll(scope, "|", " ")
-	scopes := strings.Split(scope, " ")
-	douYinScopes := strings.Join(scopes, ",")
 	state := c.Query("state")
+	// 按 RFC 6749 4.1.2.1，无法确认 client_id 及 redirect_uri 时不能重定向回客户端，只展示错误页
+	app, ok := apps.ByClientID(clientId)
+	if !ok {
+		renderUnregisteredClient(c, "client_id is not registered")
+		return
+	}
+	if !app.AllowsRedirectUri(redirectUri) {
+		renderUnregisteredClient(c, "redirect_uri is not registered for this client")
+		return
+	}
+	scopes := models.ParseScopes(c.Query("scope"))
+	if len(scopes) == 0 {
+		scopes = app.DefaultScopes()
+	}
+	for _, scope := range scopes {
+		if _, ok := models.LookupScope(scope); ok && !app.AllowsScope(scope) {
+			redirectWithError(c, redirectUri, state, "invalid_scope", fmt.Sprintf("scope %q is not enabled for this client", scope))
+			return
+		}
+	}
+	douYinScopes, err := models.ToDouYinScopes(scopes)
+	if err != nil {
+		redirectWithError(c, redirectUri, state, "invalid_scope", err.Error())
+		return
+	}
+	codeChallenge := c.Query("code_challenge")
+	codeChallengeMethod := c.Query("code_challenge_method")
+	if codeChallenge != "" && codeChallengeMethod == "" {
+		codeChallengeMethod = codeChallengeMethodPlain
+	}
+	if codeChallenge != "" && !isSupportedCodeChallengeMethod(codeChallengeMethod) {
+		redirectWithError(c, redirectUri, state, "invalid_request", "unsupported code_challenge_method")
+		return
+	}
 
 	oac := &models.OAuthCallback{
-		State:       state,
-		ClientID:    clientId,
-		RedirectUri: redirectUri,
+		State:               state,
+		ClientID:            clientId,
+		RedirectUri:         redirectUri,
+		CodeChallenge:       codeChallenge,
+		CodeChallengeMethod: codeChallengeMethod,
+		User:                dingTalkUser(c),
+		Scopes:              scopes,
 	}
-	stateStr, err := oac.ToString()
+	stateStr, err := sealState(oac)
 	if err != nil {
 		c.Error(err)
 		return
 	}
-	thisRedirectUri := fmt.Sprintf("https://%s/auth/callback", c.Request.Host)
-	douYinAuthUrl := fmt.Sprintf("https://open.douyin.com/platform/oauth/connect/?redirect_uri=%s&response_type=code&client_key=%s&scope=%s&state=%s&prompt=%s",
-		thisRedirectUri, url.QueryEscape(oac.ClientID), url.QueryEscape(douYinScopes), url.QueryEscape(stateStr), "consent")
-	logger.Infof("redirect to %s", douYinAuthUrl)
+	thisRedirectUri := publicBaseUrl(c) + "/auth/callback"
+	douYinAuthUrl := fmt.Sprintf("%s?redirect_uri=%s&response_type=code&client_key=%s&scope=%s&state=%s&prompt=%s",
+		douYinUrl(authorizePath), thisRedirectUri, url.QueryEscape(app.ClientKey(oac.ClientID)), url.QueryEscape(strings.Join(douYinScopes, ",")), url.QueryEscape(stateStr), "consent")
+	logging.FromContext(c.Request.Context()).Infof("redirect to %s", douYinAuthUrl)
+	metrics.ObserveOAuthFlow(metrics.StageAuthorize)
 	c.Redirect(http.StatusFound, douYinAuthUrl)
 }
 
 func (ac *AuthController) Callback(c *gin.Context) {
 	if conf.IsDebugMode {
-		utils.DumpHttpRequest(c.Request)
+		logging.DumpRequest(c.Request)
 	}
 	code := c.Query("code")
 	state := c.Query("state")
-	oac, err := models.NewOAuthCallbackFromJson(state)
-	if err != nil {
-		c.Error(err)
+	oac, err := openState(state)
+	if err != nil || oac.RedirectUri == "" {
+		logging.FromContext(c.Request.Context()).Warnf("invalid oauth callback state, state=%q", state)
+		renderErrorPage(c, http.StatusBadRequest, &ErrorPage{
+			Title:   "授权失败",
+			Message: "授权请求已失效或不完整，请回到钉钉重新发起授权。",
+			Detail:  "invalid or missing state parameter",
+		})
 		return
 	}
+	// 未加密的 state 可被伪造，重定向前再次校验回调地址
+	app, ok := apps.ByClientID(oac.ClientID)
+	if !ok || !app.AllowsRedirectUri(oac.RedirectUri) {
+		renderUnregisteredClient(c, "client_id or redirect_uri of the state is not registered")
+		return
+	}
+	if douYinError := douYinCallbackError(c); douYinError != nil || code == "" {
+		if douYinError == nil {
+			douYinError = &models.ServiceError{
+				Error:            "server_error",
+				ErrorDescription: "authorization server returned neither code nor error",
+			}
+		}
+		logging.FromContext(c.Request.Context()).Infof("douyin authorization failed, error=%s, description=%s", douYinError.Error, douYinError.ErrorDescription)
+		metrics.ObserveOAuthFlow(metrics.StageCallbackError)
+		redirectWithError(c, oac.RedirectUri, oac.State, douYinError.Error, douYinError.ErrorDescription)
+		return
+	}
+	// 抖音的授权回调未返回用户同意的授权范围时，以授权时申请的授权范围为准
+	scopes := models.FromDouYinScopes(models.ParseScopes(c.Query("scopes")))
+	if len(scopes) == 0 {
+		scopes = oac.Scopes
+	}
+	storage.GrantService.Save(code, &storage.AuthorizationGrant{
+		App:                 app.ID,
+		User:                oac.User,
+		CodeChallenge:       oac.CodeChallenge,
+		CodeChallengeMethod: oac.CodeChallengeMethod,
+		Scopes:              scopes,
+	})
 
-	backUrl := fmt.Sprintf("%s?code=%s&state=%s",
-		oac.RedirectUri,
-		url.QueryEscape(code),
-		url.QueryEscape(oac.State))
-	logger.Infof("redirect to %s", backUrl)
-	c.Redirect(http.StatusFound, backUrl)
+	metrics.ObserveOAuthFlow(metrics.StageCallback)
+	redirectWithParams(c, oac.RedirectUri, url.Values{"code": {code}, "state": {oac.State}})
 }
 
+// Token 以授权码换取 Token，请求体可以是 RFC 6749 4.1.3 的表单格式或相同字段的 JSON，只支持 authorization_code
 func (ac *AuthController) Token(c *gin.Context) {
-	getTokenRequest, err := ac.decodeGetTokenRequest(c.Request)
+	getTokenRequest, err := ac.decodeGetTokenRequest(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, err)
+		respondError(c, NewActionError(KindBadRequest, "request body must be a form-encoded or JSON token request", err))
 		return
 	}
-	douYinGetTokenRequest := ac.convertOAuth2DouYinGetTokenRequest(getTokenRequest)
+	succeeded := false
+	defer func() {
+		if succeeded {
+			metrics.ObserveTokenExchange(getTokenRequest.GrantType, "success")
+			metrics.ObserveOAuthFlow(metrics.StageTokenSuccess)
+		} else {
+			metrics.ObserveTokenExchange(getTokenRequest.GrantType, "failure")
+			metrics.ObserveOAuthFlow(metrics.StageTokenFailure)
+		}
+	}()
+	if getTokenRequest.GrantType != grantTypeAuthorizationCode {
+		c.JSON(http.StatusBadRequest, &models.ServiceError{
+			Error:            "unsupported_grant_type",
+			ErrorDescription: fmt.Sprintf("grant_type %q is not supported, expect %s", getTokenRequest.GrantType, grantTypeAuthorizationCode),
+		})
+		return
+	}
+	app, ok := apps.ByClientID(getTokenRequest.ClientID)
+	if !ok || !app.Authenticate(getTokenRequest.ClientID, getTokenRequest.ClientSecret) {
+		c.JSON(http.StatusUnauthorized, &models.ServiceError{
+			Error:            "invalid_client",
+			ErrorDescription: "client authentication failed",
+		})
+		return
+	}
+	grant, ok := storage.GrantService.Get(getTokenRequest.Code)
+	if !ok {
+		c.JSON(http.StatusBadRequest, &models.ServiceError{
+			Error:            "invalid_grant",
+			ErrorDescription: "authorization code is invalid, expired or already used",
+		})
+		return
+	}
+	if grant.App != app.ID {
+		c.JSON(http.StatusBadRequest, &models.ServiceError{
+			Error:            "invalid_grant",
+			ErrorDescription: "authorization code was issued to another client",
+		})
+		return
+	}
+	c.Request = c.Request.WithContext(apps.WithApp(c.Request.Context(), app))
+	if grant.CodeChallenge != "" {
+		if !verifyCodeVerifier(getTokenRequest.CodeVerifier, grant.CodeChallenge, grant.CodeChallengeMethod) {
+			c.JSON(http.StatusBadRequest, &models.ServiceError{
+				Error:            "invalid_grant",
+				ErrorDescription: "code_verifier does not match code_challenge",
+			})
+			return
+		}
+	}
+	// 校验通过后才消耗授权码，校验失败的请求不会使合法客户端的授权码失效；并发的请求中只有一个能取出授权码
+	if _, ok := storage.GrantService.Take(getTokenRequest.Code); !ok {
+		c.JSON(http.StatusBadRequest, &models.ServiceError{
+			Error:            "invalid_grant",
+			ErrorDescription: "authorization code is invalid, expired or already used",
+		})
+		return
+	}
+	douYinGetTokenRequest := ac.convertOAuth2DouYinGetTokenRequest(app, getTokenRequest)
 
-	response, err := ac.sendGetTokenRequest(douYinGetTokenRequest, getTokenUrl)
+	response, err := ac.sendGetTokenRequest(c.Request.Context(), douYinGetTokenRequest, douYinUrl(getTokenPath))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, err)
+		respondError(c, err)
 		return
 	}
 	defer response.Body.Close()
 
-	responseBody, err := io.ReadAll(response.Body)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, err)
+	douYinResponse := &models.DouYinGetTokenResponse{}
+	if err := decodeDouYinResponse(response, getTokenPath, douYinResponse); err != nil {
+		respondError(c, err)
+		return
+	}
+	if errorCode := douYinResponse.Data.ErrorCode; errorCode != 0 {
+		// Token 接口的错误遵循 RFC 6749 5.2，抖音的错误码及友好说明放在 error_code 及 error_description 中
+		upstreamError := UpstreamError(getTokenPath, errorCode, douYinResponse.Data.Description)
+		observeDouYinError(c, getTokenPath, float64(errorCode))
+		logging.FromContext(c.Request.Context()).Infof("get token returns error: %s", upstreamError.Error())
+		c.JSON(http.StatusBadRequest, &models.ServiceError{
+			Error:            "invalid_grant",
+			ErrorCode:        float64(errorCode),
+			ErrorDescription: upstreamError.Description,
+		})
 		return
 	}
 
-	if response.StatusCode == http.StatusOK {
-		douYinTokenResponse := make(map[string]interface{})
-		if err := json.Unmarshal(responseBody, &douYinTokenResponse); err != nil {
-			c.JSON(http.StatusInternalServerError, err)
+	getTokenResponse := &models.GetTokenResponse{
+		TokenType:    "bearer",
+		AccessToken:  douYinResponse.Data.AccessToken,
+		RefreshToken: douYinResponse.Data.RefreshToken,
+		ExpireIn:     douYinResponse.Data.ExpiresIn,
+		OpenID:       douYinResponse.Data.OpenID,
+	}
+	// 抖音的 Token 响应未返回授权范围时，以授权回调中用户同意的范围为准；两者均为空时拒绝，避免保存无法调用任何动作的 Token
+	scopes := models.FromDouYinScopes(models.ParseScopes(douYinResponse.Data.Scope))
+	if len(scopes) == 0 {
+		scopes = grant.Scopes
+	}
+	if len(scopes) == 0 {
+		c.JSON(http.StatusBadRequest, &models.ServiceError{
+			Error:            "invalid_scope",
+			ErrorDescription: "neither the authorization nor douyin reported any granted scope",
+		})
+		return
+	}
+	getTokenResponse.Scope = strings.Join(scopes, " ")
+	issuedAt := time.Now()
+	info := &storage.TokenInfo{
+		AccessToken:  getTokenResponse.AccessToken,
+		RefreshToken: getTokenResponse.RefreshToken,
+		OpenID:       getTokenResponse.OpenID,
+		ClientID:     getTokenRequest.ClientID,
+		Scopes:       scopes,
+		IssuedAt:     issuedAt,
+		ExpiresAt:    issuedAt.Add(time.Duration(getTokenResponse.ExpireIn) * time.Second),
+	}
+	if refreshExpiresIn := douYinResponse.Data.RefreshExpiresIn; refreshExpiresIn > 0 {
+		info.RefreshExpiresAt = issuedAt.Add(time.Duration(refreshExpiresIn) * time.Second)
+	}
+	if err := storage.Tokens(app.Namespace).Save(info); err != nil {
+		respondError(c, InternalError(err))
+		return
+	}
+	if grant.User != "" {
+		if err := storage.AccountLinks(app.Namespace).Link(grant.User, getTokenResponse.OpenID, issuedAt); err != nil {
+			respondError(c, InternalError(err))
 			return
 		}
+	}
+	logging.FromContext(c.Request.Context()).Infow("get token succeed",
+		"open_id_hash", logging.HashOpenID(getTokenResponse.OpenID),
+		"scope", getTokenResponse.Scope,
+		"expires_in", getTokenResponse.ExpireIn)
+	succeeded = true
+	c.JSON(http.StatusOK, getTokenResponse)
+}
 
-		respData := douYinTokenResponse["data"].(map[string]interface{})
-		errorCode := respData["error_code"].(float64)
-		if errorCode == 0 {
-			getTokenResponse := &models.GetTokenResponse{}
-			getTokenResponse.TokenType = "bearer"
-			getTokenResponse.AccessToken = respData["access_token"].(string)
-			getTokenResponse.RefreshToken = respData["refresh_token"].(string)
-			getTokenResponse.ExpireIn = int(respData["expires_in"].(float64))
-			getTokenResponse.OpenID = respData["open_id"].(string)
-			storage.OpenIdService.Save(getTokenResponse.AccessToken, getTokenResponse.OpenID)
-			logger.Infof("get token succeed, response: %+v", getTokenResponse)
-			c.JSON(http.StatusOK, getTokenResponse)
-		} else {
-			getTokenError := &models.ServiceError{}
-			getTokenError.ErrorCode = errorCode
-			getTokenError.ErrorDescription = respData["description"].(string)
-			logger.Infof("get token returns error, response: %+v", getTokenError)
-			c.JSON(http.StatusBadRequest, getTokenError)
+// Introspect 实现了 RFC 7662 定义的 Token 内省接口，调用方按应用的客户端凭证认证，
+// 透传凭证由抖音校验的应用未配置 client_secret，无法在本地认证，因此不提供内省；只能内省本应用颁发给自身 client_id 的 Token
+func (ac *AuthController) Introspect(c *gin.Context) {
+	clientId, clientSecret, ok := c.Request.BasicAuth()
+	if !ok {
+		clientId = c.PostForm("client_id")
+		clientSecret = c.PostForm("client_secret")
+	}
+	app, ok := apps.ByClientID(clientId)
+	if clientId == "" || !ok || !app.AuthenticateClient(clientId, clientSecret) {
+		c.Header("WWW-Authenticate", `Basic realm="introspect"`)
+		c.JSON(http.StatusUnauthorized, &models.ServiceError{
+			Error:            "invalid_client",
+			ErrorDescription: "client authentication failed",
+		})
+		return
+	}
+
+	token := c.PostForm("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, &models.ServiceError{
+			Error:            "invalid_request",
+			ErrorDescription: "missing token parameter",
+		})
+		return
+	}
+
+	info, namespace, err := storage.FindToken(token)
+	// 不向其他应用及客户端暴露 Token 的存在性，统一返回 inactive
+	if err != nil || namespace != app.Namespace || info.ClientID != clientId || info.IsExpired(time.Now()) {
+		c.JSON(http.StatusOK, &models.IntrospectResponse{Active: false})
+		return
+	}
+
+	response := &models.IntrospectResponse{
+		Active:    true,
+		Scope:     strings.Join(info.Scopes, " "),
+		ClientID:  info.ClientID,
+		TokenType: "bearer",
+		Iat:       info.IssuedAt.Unix(),
+		Sub:       info.OpenID,
+		OpenID:    info.OpenID,
+	}
+	if !info.ExpiresAt.IsZero() {
+		response.Exp = info.ExpiresAt.Unix()
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// rfc6749AuthorizationErrors 为 RFC 6749 4.1.2.1 定义的授权错误码
+var rfc6749AuthorizationErrors = []string{
+	"invalid_request", "unauthorized_client", "access_denied", "unsupported_response_type",
+	"invalid_scope", "server_error", "temporarily_unavailable",
+}
+
+// douYinCallbackError 将抖音授权回调中的错误参数转换为 RFC 6749 的错误，没有错误时返回 nil
+// 抖音在用户拒绝授权或授权失败时不返回 code，而是返回错误码及描述
+func douYinCallbackError(c *gin.Context) *models.ServiceError {
+	errorCode := firstQuery(c, "error", "error_code", "errCode", "err_code")
+	description := firstQuery(c, "error_description", "description", "errMsg", "err_msg")
+	if errorCode == "" && description == "" {
+		return nil
+	}
+
+	serviceError := &models.ServiceError{
+		Error:            "access_denied",
+		ErrorDescription: description,
+	}
+	for _, e := range rfc6749AuthorizationErrors {
+		if e == errorCode {
+			serviceError.Error = errorCode
+			return serviceError
 		}
+	}
+	if errorCode != "" {
+		serviceError.ErrorDescription = fmt.Sprintf("douyin error %s: %s", errorCode, description)
+	}
+	return serviceError
+}
+
+func firstQuery(c *gin.Context, keys ...string) string {
+	for _, key := range keys {
+		if value := c.Query(key); value != "" {
+			return value
+		}
+	}
+	return ""
+}
 
-	} else {
-		err = fmt.Errorf("httpResponse.StatusCode not ok, statusCode=%d", response.StatusCode)
-		logger.Errorf("get token response not ok: %+v", err)
-		c.JSON(http.StatusInternalServerError, err)
+// renderUnregisteredClient 在 client_id 未登记或 redirect_uri 与登记的地址不一致时展示错误页
+func renderUnregisteredClient(c *gin.Context, detail string) {
+	logging.FromContext(c.Request.Context()).Warnf("reject authorization request, %s", detail)
+	renderErrorPage(c, http.StatusBadRequest, &ErrorPage{
+		Title:   "授权失败",
+		Message: "发起授权的应用未登记或回调地址与登记的不一致，请联系应用管理员。",
+		Detail:  detail,
+	})
+}
+
+// redirectWithError 按 RFC 6749 4.1.2.1 将错误重定向回客户端
+func redirectWithError(c *gin.Context, redirectUri, state, errorCode, description string) {
+	parameters := url.Values{}
+	parameters.Add("error", errorCode)
+	parameters.Add("error_description", description)
+	if state != "" {
+		parameters.Add("state", state)
+	}
+	redirectWithParams(c, redirectUri, parameters)
+}
+
+// redirectWithParams 将 parameters 合并到 redirectUri 已有的查询参数中并重定向，同名参数以 parameters 为准
+func redirectWithParams(c *gin.Context, redirectUri string, parameters url.Values) {
+	backUrl, err := url.Parse(redirectUri)
+	if err != nil {
+		renderErrorPage(c, http.StatusBadRequest, &ErrorPage{
+			Title:   "授权失败",
+			Message: "客户端的回调地址无效，请联系应用管理员。",
+			Detail:  "invalid redirect_uri",
+		})
 		return
 	}
+	query := backUrl.Query()
+	for name, values := range parameters {
+		query[name] = values
+	}
+	backUrl.RawQuery = query.Encode()
+	logging.FromContext(c.Request.Context()).Infof("redirect to %s", backUrl.String())
+	c.Redirect(http.StatusFound, backUrl.String())
 }
 
-func (ac *AuthController) decodeGetTokenRequest(r *http.Request) (*models.GetTokenRequest, error) {
+// decodeGetTokenRequest 解析获取 Token 的请求，表单格式按 RFC 6749 解析，其他格式按 JSON 解析
+func (ac *AuthController) decodeGetTokenRequest(c *gin.Context) (*models.GetTokenRequest, error) {
+	r := c.Request
 	defer r.Body.Close()
 
+	if c.ContentType() == binding.MIMEPOSTForm {
+		if err := r.ParseForm(); err != nil {
+			return nil, err
+		}
+		return &models.GetTokenRequest{
+			ClientID:     r.PostForm.Get("client_id"),
+			ClientSecret: r.PostForm.Get("client_secret"),
+			Code:         r.PostForm.Get("code"),
+			GrantType:    r.PostForm.Get("grant_type"),
+			CodeVerifier: r.PostForm.Get("code_verifier"),
+		}, nil
+	}
+
 	var getTokenRequest models.GetTokenRequest
 	err := json.NewDecoder(r.Body).Decode(&getTokenRequest)
 	if err != nil {
@@ -143,23 +447,27 @@ func (ac *AuthController) decodeGetTokenRequest(r *http.Request) (*models.GetTok
 	return &getTokenRequest, nil
 }
 
-// 把符合OAuth标准的获取Token请求，桥接为抖音的获取Token的请求
-func (ac *AuthController) convertOAuth2DouYinGetTokenRequest(oauthTokenRequest *models.GetTokenRequest) *models.DouYinGetTokenRequest {
+// 把符合OAuth标准的获取Token请求，桥接为抖音的获取Token的请求，应用未配置抖音应用凭证时透传钉钉侧的客户端凭证
+func (ac *AuthController) convertOAuth2DouYinGetTokenRequest(app *apps.App, oauthTokenRequest *models.GetTokenRequest) *models.DouYinGetTokenRequest {
+	clientSecret := app.DouYinClientSecret
+	if clientSecret == "" {
+		clientSecret = oauthTokenRequest.ClientSecret
+	}
 	return &models.DouYinGetTokenRequest{
-		ClientKey:    oauthTokenRequest.ClientID,
-		ClientSecret: oauthTokenRequest.ClientSecret,
+		ClientKey:    app.ClientKey(oauthTokenRequest.ClientID),
+		ClientSecret: clientSecret,
 		Code:         oauthTokenRequest.Code,
 		GrantType:    oauthTokenRequest.GrantType,
 	}
 }
 
-func (ac *AuthController) sendGetTokenRequest(request *models.DouYinGetTokenRequest, url string) (*http.Response, error) {
+func (ac *AuthController) sendGetTokenRequest(ctx context.Context, request *models.DouYinGetTokenRequest, url string) (*http.Response, error) {
 	requestBody, err := json.Marshal(request)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpRequest, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBody))
+	httpRequest, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -170,7 +478,7 @@ func (ac *AuthController) sendGetTokenRequest(request *models.DouYinGetTokenRequ
 		return nil, fmt.Errorf("failed to create douyin client: %w", err)
 	}
 
-	resp, err := dyClient.httpClient.Do(httpRequest)
+	resp, err := dyClient.Do(httpRequest)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -0,0 +1,404 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"douyin-action-example/internal/actions/models"
+	"douyin-action-example/internal/actions/storage"
+	"douyin-action-example/internal/conf"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func introspect(clientId, clientSecret, token string) *httptest.ResponseRecorder {
+	form := url.Values{"token": {token}}
+	request := httptest.NewRequest(http.MethodPost, "/auth/introspect", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.SetBasicAuth(clientId, clientSecret)
+	return serve(http.MethodPost, "/auth/introspect", NewAuthController().Introspect, request)
+}
+
+func TestIntrospect(t *testing.T) {
+	useConfig(t, testAppsConfig())
+	now := time.Now()
+	mustSave(t, "alpha", &storage.TokenInfo{
+		AccessToken: "alpha-token", OpenID: "open-1", ClientID: "alpha-client",
+		Scopes: []string{models.ScopeUserInfo}, IssuedAt: now, ExpiresAt: now.Add(time.Hour),
+	})
+	mustSave(t, "alpha", &storage.TokenInfo{
+		AccessToken: "expired-token", OpenID: "open-1", ClientID: "alpha-client",
+		IssuedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour),
+	})
+
+	cases := []struct {
+		name         string
+		clientId     string
+		clientSecret string
+		token        string
+		status       int
+		active       bool
+	}{
+		{"active", "alpha-client", "alpha-secret", "alpha-token", http.StatusOK, true},
+		{"wrong secret", "alpha-client", "beta-secret", "alpha-token", http.StatusUnauthorized, false},
+		{"unknown client", "gamma-client", "alpha-secret", "alpha-token", http.StatusUnauthorized, false},
+		{"token of another app", "beta-client", "beta-secret", "alpha-token", http.StatusOK, false},
+		{"expired", "alpha-client", "alpha-secret", "expired-token", http.StatusOK, false},
+		{"unknown token", "alpha-client", "alpha-secret", "missing-token", http.StatusOK, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := introspect(tc.clientId, tc.clientSecret, tc.token)
+			if recorder.Code != tc.status {
+				t.Fatalf("status = %d, want %d, body %s", recorder.Code, tc.status, recorder.Body)
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+			response := &models.IntrospectResponse{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), response); err != nil {
+				t.Fatal(err)
+			}
+			if response.Active != tc.active {
+				t.Fatalf("active = %v, want %v", response.Active, tc.active)
+			}
+			if tc.active && (response.OpenID != "open-1" || response.Scope != models.ScopeUserInfo || response.ClientID != "alpha-client") {
+				t.Fatalf("unexpected response %+v", response)
+			}
+		})
+	}
+}
+
+func TestIntrospectRequiresClientSecret(t *testing.T) {
+	// 未配置 apps 且未配置 douyin.client_secret 时客户端凭证透传给抖音校验，本地无法认证
+	useConfig(t, conf.Default())
+	now := time.Now()
+	mustSave(t, "", &storage.TokenInfo{
+		AccessToken: "passthrough-token", OpenID: "open-1", ClientID: "any-client",
+		Scopes: []string{models.ScopeUserInfo}, IssuedAt: now, ExpiresAt: now.Add(time.Hour),
+	})
+	for _, secret := range []string{"wrong-secret", ""} {
+		if recorder := introspect("any-client", secret, "passthrough-token"); recorder.Code != http.StatusUnauthorized {
+			t.Errorf("secret %q: status = %d, want 401, body %s", secret, recorder.Code, recorder.Body)
+		}
+	}
+}
+
+func mustSave(t *testing.T, namespace string, info *storage.TokenInfo) {
+	t.Helper()
+	if err := storage.Tokens(namespace).Save(info); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeTokenEndpoint 模拟抖音的获取 Token 接口，每次调用颁发新的 Token
+func fakeTokenEndpoint(t *testing.T, scope string) *int {
+	calls := 0
+	fakeDouYin(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"access_token":       "douyin-access-" + strconv.Itoa(calls),
+				"refresh_token":      "douyin-refresh-" + strconv.Itoa(calls),
+				"expires_in":         3600,
+				"refresh_expires_in": 86400,
+				"open_id":            "open-1",
+				"scope":              scope,
+			},
+			"message": "success",
+		})
+	}))
+	return &calls
+}
+
+func exchangeToken(clientId, clientSecret, code, verifier string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(&models.GetTokenRequest{
+		ClientID: clientId, ClientSecret: clientSecret, Code: code, GrantType: "authorization_code", CodeVerifier: verifier,
+	})
+	request := httptest.NewRequest(http.MethodPost, "/auth/token", bytes.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	return serve(http.MethodPost, "/auth/token", NewAuthController().Token, request)
+}
+
+func serviceError(t *testing.T, recorder *httptest.ResponseRecorder) *models.ServiceError {
+	t.Helper()
+	e := &models.ServiceError{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), e); err != nil {
+		t.Fatalf("decode %s: %v", recorder.Body, err)
+	}
+	return e
+}
+
+func TestTokenPKCE(t *testing.T) {
+	useConfig(t, testAppsConfig())
+	calls := fakeTokenEndpoint(t, "")
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	sum := sha256.Sum256([]byte(verifier))
+	storage.GrantService.Save("code-1", &storage.AuthorizationGrant{
+		App:                 "alpha",
+		CodeChallenge:       base64.RawURLEncoding.EncodeToString(sum[:]),
+		CodeChallengeMethod: codeChallengeMethodS256,
+		Scopes:              []string{models.ScopeUserInfo},
+	})
+
+	for _, wrong := range []string{"", "not-the-verifier"} {
+		recorder := exchangeToken("alpha-client", "alpha-secret", "code-1", wrong)
+		if recorder.Code != http.StatusBadRequest || serviceError(t, recorder).Error != "invalid_grant" {
+			t.Fatalf("verifier %q: status = %d, body %s", wrong, recorder.Code, recorder.Body)
+		}
+	}
+	if *calls != 0 {
+		t.Fatalf("douyin called %d times before the verifier matched", *calls)
+	}
+
+	// 校验失败不消耗授权码，持有正确 code_verifier 的客户端仍可换取 Token
+	recorder := exchangeToken("alpha-client", "alpha-secret", "code-1", verifier)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", recorder.Code, recorder.Body)
+	}
+	response := &models.GetTokenResponse{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), response); err != nil {
+		t.Fatal(err)
+	}
+	if response.AccessToken != "douyin-access-1" || response.Scope != models.ScopeUserInfo {
+		t.Fatalf("unexpected response %+v", response)
+	}
+
+	// 授权码只能使用一次
+	recorder = exchangeToken("alpha-client", "alpha-secret", "code-1", verifier)
+	if recorder.Code != http.StatusBadRequest || serviceError(t, recorder).Error != "invalid_grant" {
+		t.Fatalf("reused code: status = %d, body %s", recorder.Code, recorder.Body)
+	}
+}
+
+func TestTokenRejectsUnknownOrForeignCode(t *testing.T) {
+	useConfig(t, testAppsConfig())
+	calls := fakeTokenEndpoint(t, "user_info")
+	storage.GrantService.Save("code-alpha", &storage.AuthorizationGrant{App: "alpha"})
+
+	recorder := exchangeToken("alpha-client", "alpha-secret", "code-unknown", "")
+	if recorder.Code != http.StatusBadRequest || serviceError(t, recorder).Error != "invalid_grant" {
+		t.Fatalf("unknown code: status = %d, body %s", recorder.Code, recorder.Body)
+	}
+	recorder = exchangeToken("beta-client", "beta-secret", "code-alpha", "")
+	if recorder.Code != http.StatusBadRequest || serviceError(t, recorder).Error != "invalid_grant" {
+		t.Fatalf("foreign code: status = %d, body %s", recorder.Code, recorder.Body)
+	}
+	recorder = exchangeToken("alpha-client", "beta-secret", "code-alpha", "")
+	if recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong secret: status = %d, body %s", recorder.Code, recorder.Body)
+	}
+	if *calls != 0 {
+		t.Fatalf("douyin called %d times for rejected requests", *calls)
+	}
+	// 被其他客户端尝试过的授权码仍属于原客户端
+	if recorder := exchangeToken("alpha-client", "alpha-secret", "code-alpha", ""); recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", recorder.Code, recorder.Body)
+	}
+}
+
+func TestRedirectWithErrorMergesQuery(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	recorder := serve(http.MethodGet, "/", func(c *gin.Context) {
+		redirectWithError(c, "https://client.example.com/cb?tenant=t1&state=stale#frag", "s1", "access_denied", "user denied")
+	}, request)
+	if recorder.Code != http.StatusFound {
+		t.Fatalf("status = %d", recorder.Code)
+	}
+	location, err := url.Parse(recorder.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location.Host != "client.example.com" || location.Path != "/cb" {
+		t.Fatalf("unexpected location %s", location)
+	}
+	query := location.Query()
+	want := url.Values{"tenant": {"t1"}, "state": {"s1"}, "error": {"access_denied"}, "error_description": {"user denied"}}
+	for name, values := range want {
+		if got := query[name]; len(got) != 1 || got[0] != values[0] {
+			t.Fatalf("%s = %v, want %v in %s", name, got, values, location)
+		}
+	}
+}
+
+func callback(t *testing.T, oac *models.OAuthCallback, query url.Values) *httptest.ResponseRecorder {
+	t.Helper()
+	state, err := sealState(oac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query.Set("state", state)
+	request := httptest.NewRequest(http.MethodGet, "/auth/callback?"+query.Encode(), nil)
+	return serve(http.MethodGet, "/auth/callback", NewAuthController().Callback, request)
+}
+
+func TestCallbackScopes(t *testing.T) {
+	useConfig(t, testAppsConfig())
+	oac := &models.OAuthCallback{
+		ClientID: "alpha-client", RedirectUri: "https://client.example.com/cb", State: "s1",
+		Scopes: []string{models.ScopeUserInfo, models.ScopeVideoList},
+	}
+
+	// 抖音返回了用户同意的授权范围时以其为准
+	recorder := callback(t, oac, url.Values{"code": {"code-granted"}, "scopes": {"user_info"}})
+	if recorder.Code != http.StatusFound {
+		t.Fatalf("status = %d, body %s", recorder.Code, recorder.Body)
+	}
+	grant, ok := storage.GrantService.Get("code-granted")
+	if !ok || strings.Join(grant.Scopes, " ") != models.ScopeUserInfo {
+		t.Fatalf("grant = %+v, want scopes user.info", grant)
+	}
+
+	// 抖音未返回时以授权时申请的授权范围为准
+	recorder = callback(t, oac, url.Values{"code": {"code-requested"}})
+	if recorder.Code != http.StatusFound {
+		t.Fatalf("status = %d, body %s", recorder.Code, recorder.Body)
+	}
+	grant, ok = storage.GrantService.Get("code-requested")
+	if !ok || strings.Join(grant.Scopes, " ") != "user.info video.list" {
+		t.Fatalf("grant = %+v, want the requested scopes", grant)
+	}
+}
+
+func TestTokenScopes(t *testing.T) {
+	cases := []struct {
+		name        string
+		douYinScope string
+		grantScopes []string
+		want        string
+	}{
+		{"douyin scope wins", "user_info,video.list.bytoken", []string{models.ScopeUserInfo}, "user.info video.list"},
+		{"grant scope when douyin omits it", "", []string{models.ScopeFansData}, models.ScopeFansData},
+		{"rejected without any scope", "", nil, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			useConfig(t, testAppsConfig())
+			fakeTokenEndpoint(t, tc.douYinScope)
+			storage.GrantService.Save("code-scope", &storage.AuthorizationGrant{App: "alpha", Scopes: tc.grantScopes})
+
+			recorder := exchangeToken("alpha-client", "alpha-secret", "code-scope", "")
+			if tc.want == "" {
+				if recorder.Code != http.StatusBadRequest || serviceError(t, recorder).Error != "invalid_scope" {
+					t.Fatalf("status = %d, body %s", recorder.Code, recorder.Body)
+				}
+				if storage.Tokens("alpha").Len() != 0 {
+					t.Fatal("token without scope was stored")
+				}
+				return
+			}
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", recorder.Code, recorder.Body)
+			}
+			info, err := storage.Tokens("alpha").GetTokenInfo("douyin-access-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(info.Scopes, " "); got != tc.want {
+				t.Fatalf("stored scopes = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizeRejectsUnregisteredClients(t *testing.T) {
+	useConfig(t, testAppsConfig())
+	cases := []struct {
+		name        string
+		clientId    string
+		redirectUri string
+		status      int
+	}{
+		{"registered", "alpha-client", "https://client.example.com/cb", http.StatusFound},
+		{"unknown client", "gamma-client", "https://client.example.com/cb", http.StatusBadRequest},
+		{"redirect of another app", "alpha-client", "https://beta.example.com/cb", http.StatusBadRequest},
+		{"unregistered redirect", "alpha-client", "https://attacker.example.com/cb", http.StatusBadRequest},
+		{"prefix of a registered redirect", "alpha-client", "https://client.example.com/cb/../evil", http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			query := url.Values{"client_id": {tc.clientId}, "redirect_uri": {tc.redirectUri}, "state": {"s1"}, "response_type": {"code"}}
+			request := httptest.NewRequest(http.MethodGet, "/auth/authorize?"+query.Encode(), nil)
+			recorder := serve(http.MethodGet, "/auth/authorize", NewAuthController().Authorize, request)
+			if recorder.Code != tc.status {
+				t.Fatalf("status = %d, want %d", recorder.Code, tc.status)
+			}
+			location := recorder.Header().Get("Location")
+			if tc.status == http.StatusFound {
+				if !strings.HasPrefix(location, conf.App.DouYin.OpenApiBaseUrl+authorizePath) {
+					t.Fatalf("redirect to %s, want douyin", location)
+				}
+				return
+			}
+			if location != "" || !strings.Contains(recorder.Header().Get("Content-Type"), "text/html") {
+				t.Fatalf("want an error page, got location %q", location)
+			}
+		})
+	}
+}
+
+func TestCallbackRejectsForgedRedirect(t *testing.T) {
+	useConfig(t, testAppsConfig())
+	// 未配置 state 密钥时 state 为明文，可被伪造
+	oac := &models.OAuthCallback{ClientID: "alpha-client", RedirectUri: "https://attacker.example.com/cb", State: "s1"}
+	for _, query := range []url.Values{{"code": {"code-forged"}}, {"error": {"access_denied"}}} {
+		recorder := callback(t, oac, query)
+		if recorder.Code != http.StatusBadRequest || recorder.Header().Get("Location") != "" {
+			t.Fatalf("%v: status = %d, location %q", query, recorder.Code, recorder.Header().Get("Location"))
+		}
+	}
+	if _, ok := storage.GrantService.Get("code-forged"); ok {
+		t.Fatal("grant was saved for a forged redirect")
+	}
+}
+
+func TestTokenFormEncoded(t *testing.T) {
+	useConfig(t, testAppsConfig())
+	calls := fakeTokenEndpoint(t, "")
+	storage.GrantService.Save("code-form", &storage.AuthorizationGrant{App: "alpha", Scopes: []string{models.ScopeUserInfo}})
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/auth/token", strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return serve(http.MethodPost, "/auth/token", NewAuthController().Token, request)
+	}
+	form := url.Values{
+		"grant_type": {"authorization_code"}, "code": {"code-form"},
+		"client_id": {"alpha-client"}, "client_secret": {"alpha-secret"},
+	}
+
+	for _, grantType := range []string{"refresh_token", ""} {
+		unsupported := url.Values{}
+		for name, values := range form {
+			unsupported[name] = values
+		}
+		unsupported.Set("grant_type", grantType)
+		recorder := post(unsupported)
+		if recorder.Code != http.StatusBadRequest || serviceError(t, recorder).Error != "unsupported_grant_type" {
+			t.Fatalf("grant_type %q: status = %d, body %s", grantType, recorder.Code, recorder.Body)
+		}
+	}
+	if *calls != 0 {
+		t.Fatalf("douyin called %d times for unsupported grant types", *calls)
+	}
+
+	recorder := post(form)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", recorder.Code, recorder.Body)
+	}
+	response := &models.GetTokenResponse{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), response); err != nil {
+		t.Fatal(err)
+	}
+	if response.AccessToken != "douyin-access-1" || response.Scope != models.ScopeUserInfo {
+		t.Fatalf("unexpected response %+v", response)
+	}
+}
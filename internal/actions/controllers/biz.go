@@ -2,23 +2,30 @@ package controllers
 
 import (
 	"bytes"
+	"context"
 	"douyin-action-example/internal/actions/models"
 	"douyin-action-example/internal/actions/storage"
 	"douyin-action-example/internal/conf"
+	"douyin-action-example/internal/logging"
 	"encoding/json"
 	"fmt"
-	"github.com/chzealot/gobase/logger"
-	"github.com/chzealot/gobase/utils"
 	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
 	"io"
 	"net/http"
 	"net/url"
 	"strconv"
 )
 
-const getUserInfoUrl string = "https://open.douyin.com/oauth/userinfo/"
-const getVideoListUrl string = "https://open.douyin.com/api/douyin/v1/video/video_list/"
-const getFansUrl string = "https://open.douyin.com/api/douyin/v1/user/fans_data/"
+const getUserInfoPath string = "/oauth/userinfo/"
+const getVideoListPath string = "/api/douyin/v1/video/video_list/"
+const getFansPath string = "/api/douyin/v1/user/fans_data/"
+
+// 抖音视频列表接口每页最多返回 20 条
+const (
+	defaultVideoListCount = 5
+	maxVideoListCount     = 20
+)
 
 type BizController struct {
 }
@@ -27,166 +34,176 @@ func NewBizController() *BizController {
 	return &BizController{}
 }
 
+// Actions 返回业务动作及其所需的授权范围
+func (bc *BizController) Actions() []*Action {
+	return []*Action{
+		{
+			Method: http.MethodGet, Path: "/userInfo", Scope: models.ScopeUserInfo, Handler: bc.UserInfo,
+			OperationID: "GetUserInfo", Summary: "查询用户信息", Description: "查询授权的抖音账号的昵称、头像等公开信息",
+			Query: models.AccountQuery{}, Response: models.GetUserInfoResponse{},
+		},
+		{
+			Method: http.MethodGet, Path: "/videoList", Scope: models.ScopeVideoList, Handler: bc.GetVideoList,
+			OperationID: "GetVideoList", Summary: "查看视频列表", Description: "查看授权的抖音账号最近发布的视频及其点赞、播放、分享、评论数",
+			Query: models.GetVideoListQuery{}, Response: models.GetVideoListResponse{},
+		},
+		{
+			Method: http.MethodGet, Path: "/fansData", Scope: models.ScopeFansData, Handler: bc.GetFansData,
+			OperationID: "GetFansData", Summary: "查看粉丝画像", Description: "查看授权的抖音账号的粉丝总数及性别、年龄、设备、兴趣、活跃天数分布",
+			Query: models.AccountQuery{}, Response: models.GetFansDataResponse{},
+		},
+		{
+			Method: http.MethodGet, Path: "/accounts", Handler: bc.GetAccounts, AllAccounts: true,
+			OperationID: "GetAccounts", Summary: "查看关联的抖音账号",
+			Description: "列出当前用户关联的全部抖音账号，需要对比多个账号时先调用本动作，再以 account 参数分别查询各账号",
+			Response:    models.GetAccountsResponse{},
+		},
+		{
+			Method: http.MethodGet, Path: "/workspace/grants", Handler: bc.GetGrants, AllAccounts: true,
+			OperationID: "GetGrants", Summary: "查看账号共享", Description: "列出当前用户共享给他人的抖音账号，以及他人共享给当前用户的抖音账号",
+			Response: models.GetGrantsResponse{},
+		},
+		{
+			Method: http.MethodPost, Path: "/workspace/grants", Handler: bc.Grant, AllAccounts: true,
+			OperationID: "GrantAccount", Summary: "共享抖音账号",
+			Description: "将当前用户关联的抖音账号共享给其他钉钉用户，read 角色只能查询，write 角色还可以执行修改类操作；已共享时更新角色",
+			Body:        models.GrantRequest{}, Response: models.GrantItem{},
+		},
+		{
+			Method: http.MethodPost, Path: "/workspace/grants/revoke", Handler: bc.Revoke, AllAccounts: true,
+			OperationID: "RevokeAccountGrant", Summary: "取消共享抖音账号", Description: "收回共享给其他钉钉用户的抖音账号",
+			Body: models.RevokeRequest{}, Response: models.RevokeResponse{},
+		},
+		{
+			Method: http.MethodGet, Path: "/workspace/access", Handler: bc.GetAccessLog, AllAccounts: true,
+			OperationID: "GetAccessLog", Summary: "查看账号访问记录", Description: "查看谁在何时访问了当前用户关联的抖音账号，包括被拒绝的访问",
+			Query: models.GetAccessLogQuery{}, Response: models.GetAccessLogResponse{},
+		},
+	}
+}
+
 func (bc *BizController) UserInfo(c *gin.Context) {
 	if conf.IsDebugMode {
-		utils.DumpHttpRequest(c.Request)
+		logging.DumpRequest(c.Request)
 	}
 
-	getUserInfoRequest, err := bc.buildGetUserInfoRequest(c.Request)
+	getUserInfoRequest, err := bc.buildGetUserInfoRequest(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, err)
+		respondError(c, err)
 		return
 	}
 
-	response, err := bc.sendGetUserInfoRequest(getUserInfoRequest, getUserInfoUrl)
+	response, err := bc.sendGetUserInfoRequest(c.Request.Context(), getUserInfoRequest, douYinUrl(getUserInfoPath))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, err)
+		respondError(c, err)
 		return
 	}
 	defer response.Body.Close()
 
-	body, err := io.ReadAll(response.Body)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, err)
+	douYinResponse := &models.DouYinUserInfoResponse{}
+	if err := decodeDouYinResponse(response, getUserInfoPath, douYinResponse); err != nil {
+		respondError(c, err)
 		return
 	}
-
-	douYinTokenResponse := make(map[string]interface{})
-	if response.StatusCode == http.StatusOK {
-		if err := json.Unmarshal(body, &douYinTokenResponse); err != nil {
-			c.JSON(http.StatusInternalServerError, err)
-			return
-		}
-
-		respData := douYinTokenResponse["data"].(map[string]interface{})
-		errorCode := respData["error_code"].(float64)
-		if errorCode == 0 {
-			getUserInfoResponse := &models.GetUserInfoResponse{}
-			getUserInfoResponse.AvatarUrl = respData["avatar"].(string)
-			getUserInfoResponse.Nick = respData["nickname"].(string)
-			getUserInfoResponse.OpenID = respData["open_id"].(string)
-			getUserInfoResponse.UnionID = respData["union_id"].(string)
-			logger.Infof("get user info succeed, response: %+v", getUserInfoResponse)
-			c.JSON(http.StatusOK, getUserInfoResponse)
-			return
-		} else {
-			getUserInfoError := &models.ServiceError{}
-			getUserInfoError.ErrorCode = errorCode
-			getUserInfoError.ErrorDescription = respData["description"].(string)
-			logger.Infof("get user info returns error, response: %+v", getUserInfoError)
-			c.JSON(http.StatusBadRequest, getUserInfoError)
-			return
-		}
-	} else {
-		err = fmt.Errorf("httpResponse.StatusCode not ok, statusCode=%d", response.StatusCode)
-		logger.Errorf("get user info response not ok: %+v", err)
-		c.JSON(http.StatusInternalServerError, err)
+	if errorCode, description := douYinFailure(douYinResponse.Data.DouYinError, douYinResponse.Extra); errorCode != 0 {
+		respondError(c, UpstreamError(getUserInfoPath, errorCode, description))
 		return
 	}
+
+	getUserInfoResponse := &models.GetUserInfoResponse{
+		AvatarUrl: douYinResponse.Data.Avatar,
+		Nick:      douYinResponse.Data.Nickname,
+		OpenID:    douYinResponse.Data.OpenID,
+		UnionID:   douYinResponse.Data.UnionID,
+	}
+	err = storage.AccountLinks(appNamespace(c)).SetNickname(getUserInfoRequest.OpenID, getUserInfoResponse.Nick)
+	if err != nil && !errors.Is(err, storage.ErrAccountNotLinked) {
+		logging.FromContext(c.Request.Context()).Warnf("save nickname of linked account failed: %+v", err)
+	}
+	logging.FromContext(c.Request.Context()).Infow("get user info succeed",
+		"open_id_hash", logging.HashOpenID(getUserInfoResponse.OpenID))
+	c.JSON(http.StatusOK, getUserInfoResponse)
 }
 
 func (bc *BizController) GetVideoList(c *gin.Context) {
-	getVideoListRequest, err := bc.buildGetVideoListRequest(c.Request, 0, 5)
+	query := &models.GetVideoListQuery{Count: defaultVideoListCount}
+	if err := c.ShouldBindQuery(query); err != nil {
+		respondError(c, NewActionError(KindBadRequest, "invalid query parameter: "+err.Error(), err))
+		return
+	}
+	if query.Count <= 0 || query.Count > maxVideoListCount {
+		query.Count = defaultVideoListCount
+	}
+	getVideoListRequest, err := bc.buildGetVideoListRequest(c, query.Cursor, query.Count)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, err)
+		respondError(c, err)
 		return
 	}
 
-	getVideoListUrlWithParam, err := bc.generateGetVideoListUrl(getVideoListRequest, getVideoListUrl)
+	getVideoListUrlWithParam, err := bc.generateGetVideoListUrl(getVideoListRequest, douYinUrl(getVideoListPath))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, err)
+		respondError(c, err)
 		return
 	}
 
-	response, err := bc.sendGetVideoListRequest(getVideoListRequest, getVideoListUrlWithParam)
+	response, err := bc.sendGetVideoListRequest(c.Request.Context(), getVideoListRequest, getVideoListUrlWithParam)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, err)
+		respondError(c, err)
 		return
 	}
 	defer response.Body.Close()
 
-	body, err := io.ReadAll(response.Body)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, err)
+	douYinResponse := &models.DouYinVideoListResponse{}
+	if err := decodeDouYinResponse(response, getVideoListPath, douYinResponse); err != nil {
+		respondError(c, err)
+		return
+	}
+	if errorCode, description := douYinFailure(douYinResponse.Data.DouYinError, douYinResponse.Extra); errorCode != 0 {
+		respondError(c, UpstreamError(getVideoListPath, errorCode, description))
 		return
 	}
 
-	douYinTokenResponse := make(map[string]interface{})
-	if response.StatusCode == http.StatusOK {
-		if err := json.Unmarshal(body, &douYinTokenResponse); err != nil {
-			c.JSON(http.StatusInternalServerError, err)
-			return
+	getVideoListResponse := &models.GetVideoListResponse{
+		Videos:  []*models.VideoItem{},
+		Cursor:  douYinResponse.Data.Cursor,
+		HasMore: douYinResponse.Data.HasMore,
+	}
+	for _, video := range douYinResponse.Data.List {
+		if video == nil || video.Title == "" {
+			continue
 		}
-
-		respData := douYinTokenResponse["data"].(map[string]interface{})
-		respExtra := douYinTokenResponse["extra"].(map[string]interface{})
-		errorCode := respExtra["error_code"].(float64)
-		if errorCode == 0 {
-			getVideoListResponse := &models.GetVideoListResponse{}
-			videoList := respData["list"].([]interface{})
-			for _, rawVideo := range videoList {
-				video, ok := rawVideo.(map[string]interface{})
-				if !ok {
-					c.JSON(http.StatusInternalServerError, err)
-					return
-				}
-
-				title := video["title"].(string)
-				if title != "" {
-					videoItem := &models.VideoItem{}
-					videoItem.Title = title
-					statistics := video["statistics"].(map[string]interface{})
-					videoItem.DiggCount = int64(statistics["digg_count"].(float64))
-					videoItem.ShareCount = int64(statistics["share_count"].(float64))
-					videoItem.PlayCount = int64(statistics["play_count"].(float64))
-					videoItem.CommentCount = int64(statistics["comment_count"].(float64))
-					logger.Infof("videoItem=%+v", videoItem)
-					getVideoListResponse.Videos = append(getVideoListResponse.Videos, videoItem)
-				}
-			}
-			c.JSON(http.StatusOK, getVideoListResponse)
-			return
-		} else {
-			getTokenError := &models.ServiceError{}
-			getTokenError.ErrorCode = errorCode
-			getTokenError.ErrorDescription = respExtra["description"].(string)
-			c.JSON(http.StatusBadRequest, getTokenError)
-			return
+		videoItem := &models.VideoItem{
+			Title:        video.Title,
+			DiggCount:    video.Statistics.DiggCount,
+			PlayCount:    video.Statistics.PlayCount,
+			ShareCount:   video.Statistics.ShareCount,
+			CommentCount: video.Statistics.CommentCount,
 		}
-	} else {
-		c.JSON(http.StatusInternalServerError, err)
-		return
+		logging.FromContext(c.Request.Context()).Debugf("videoItem=%+v", videoItem)
+		getVideoListResponse.Videos = append(getVideoListResponse.Videos, videoItem)
 	}
+	c.JSON(http.StatusOK, getVideoListResponse)
 }
 
-func (bc *BizController) getOpenID(accessToken string) (string, error) {
-	return storage.OpenIdService.GetOpenIdByAccessToken(accessToken)
-}
-
-func (bc *BizController) buildGetUserInfoRequest(r *http.Request) (*models.GetUserInfoRequest, error) {
-	accessToken, err := GetBearerToken(r)
-	if err != nil {
-		return nil, err
-	}
-
-	openId, err := bc.getOpenID(accessToken)
+func (bc *BizController) buildGetUserInfoRequest(c *gin.Context) (*models.GetUserInfoRequest, error) {
+	info, err := selectedToken(c)
 	if err != nil {
 		return nil, err
 	}
 
 	return &models.GetUserInfoRequest{
-		AccessToken: accessToken,
-		OpenID:      openId,
+		AccessToken: info.AccessToken,
+		OpenID:      info.OpenID,
 	}, nil
 }
 
-func (bc *BizController) sendGetUserInfoRequest(request *models.GetUserInfoRequest, url string) (*http.Response, error) {
+func (bc *BizController) sendGetUserInfoRequest(ctx context.Context, request *models.GetUserInfoRequest, url string) (*http.Response, error) {
 	requestBody, err := json.Marshal(request)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	httpRequest, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBody))
+	httpRequest, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -197,7 +214,7 @@ func (bc *BizController) sendGetUserInfoRequest(request *models.GetUserInfoReque
 		return nil, fmt.Errorf("failed to create douyin client: %w", err)
 	}
 
-	resp, err := dyClient.httpClient.Do(httpRequest)
+	resp, err := dyClient.DoCached(httpRequest, request.OpenID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -205,20 +222,15 @@ func (bc *BizController) sendGetUserInfoRequest(request *models.GetUserInfoReque
 	return resp, nil
 }
 
-func (bc *BizController) buildGetVideoListRequest(r *http.Request, cursor int, count int) (*models.GetVideoListRequest, error) {
-	accessToken, err := GetBearerToken(r)
-	if err != nil {
-		return nil, err
-	}
-
-	openId, err := bc.getOpenID(accessToken)
+func (bc *BizController) buildGetVideoListRequest(c *gin.Context, cursor int, count int) (*models.GetVideoListRequest, error) {
+	info, err := selectedToken(c)
 	if err != nil {
 		return nil, err
 	}
 
 	return &models.GetVideoListRequest{
-		AccessToken: accessToken,
-		OpenID:      openId,
+		AccessToken: info.AccessToken,
+		OpenID:      info.OpenID,
 		Cursor:      cursor,
 		Count:       count,
 	}, nil
@@ -242,8 +254,8 @@ func (bc *BizController) generateGetVideoListUrl(request *models.GetVideoListReq
 	return parsedURL.String(), nil
 }
 
-func (bc *BizController) sendGetVideoListRequest(request *models.GetVideoListRequest, url string) (*http.Response, error) {
-	httpRequest, err := http.NewRequest("GET", url, nil)
+func (bc *BizController) sendGetVideoListRequest(ctx context.Context, request *models.GetVideoListRequest, url string) (*http.Response, error) {
+	httpRequest, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -255,7 +267,7 @@ func (bc *BizController) sendGetVideoListRequest(request *models.GetVideoListReq
 		return nil, fmt.Errorf("failed to create douyin client: %w", err)
 	}
 
-	resp, err := dyClient.httpClient.Do(httpRequest)
+	resp, err := dyClient.DoCached(httpRequest, request.OpenID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -276,39 +288,34 @@ func (bc *BizController) generateGetFansUrl(request *models.GetFansDataRequest,
 	return parsedURL.String(), nil
 }
 
-func (bc *BizController) buildGetFansDataRequest(r *http.Request) (*models.GetFansDataRequest, error) {
-	accessToken, err := GetBearerToken(r)
-	if err != nil {
-		return nil, err
-	}
-
-	openId, err := bc.getOpenID(accessToken)
+func (bc *BizController) buildGetFansDataRequest(c *gin.Context) (*models.GetFansDataRequest, error) {
+	info, err := selectedToken(c)
 	if err != nil {
 		return nil, err
 	}
 
 	return &models.GetFansDataRequest{
-		AccessToken: accessToken,
-		OpenID:      openId,
+		AccessToken: info.AccessToken,
+		OpenID:      info.OpenID,
 	}, nil
 }
 
 func (bc *BizController) GetFansData(c *gin.Context) {
-	getFansDataRequest, err := bc.buildGetFansDataRequest(c.Request)
+	getFansDataRequest, err := bc.buildGetFansDataRequest(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, err)
+		respondError(c, err)
 		return
 	}
 
-	getFansUrlWithParam, err := bc.generateGetFansUrl(getFansDataRequest, getFansUrl)
+	getFansUrlWithParam, err := bc.generateGetFansUrl(getFansDataRequest, douYinUrl(getFansPath))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, err)
+		respondError(c, err)
 		return
 	}
 
-	httpRequest, err := http.NewRequest("GET", getFansUrlWithParam, nil)
+	httpRequest, err := http.NewRequestWithContext(c.Request.Context(), "GET", getFansUrlWithParam, nil)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, err)
+		respondError(c, err)
 		return
 	}
 	//httpRequest.Header.Set("Content-Type", "application/json")
@@ -316,38 +323,66 @@ func (bc *BizController) GetFansData(c *gin.Context) {
 
 	dyClient, err := NewDouYinClient()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, err)
+		respondError(c, err)
 		return
 	}
 
-	httpResponse, err := dyClient.httpClient.Do(httpRequest)
+	httpResponse, err := dyClient.DoCached(httpRequest, getFansDataRequest.OpenID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, err)
+		respondError(c, err)
 		return
 	}
 	defer httpResponse.Body.Close()
-	body, err := io.ReadAll(httpResponse.Body)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, err)
+
+	fansResponse := &models.DouYinFansDataResponse{}
+	if err := decodeDouYinResponse(httpResponse, getFansPath, fansResponse); err != nil {
+		respondError(c, err)
 		return
 	}
-
-	fansResponse := make(map[string]interface{})
-	if httpResponse.StatusCode == http.StatusOK {
-		if err := json.Unmarshal(body, &fansResponse); err != nil {
-			logger.Errorf("json.Unmarshal failed, err=%s", err.Error())
-			c.JSON(http.StatusInternalServerError, err)
-			return
-		}
-		logger.Infof("fansResponse=%+v", fansResponse)
-
-		response := &models.GetFansDataResponse{}
-		response.Item = "加班"
-		response.Value = 100
-		c.JSON(http.StatusOK, response)
-	} else {
-		logger.Errorf("httpResponse.StatusCode not ok")
-		c.JSON(http.StatusInternalServerError, err)
+	if errorCode, description := douYinFailure(fansResponse.Data.DouYinError, fansResponse.Extra); errorCode != 0 {
+		respondError(c, UpstreamError(getFansPath, errorCode, description))
 		return
 	}
+	logging.FromContext(c.Request.Context()).Debugf("fansResponse=%+v", fansResponse)
+
+	fansData := fansResponse.Data.FansData
+	response := &models.GetFansDataResponse{AllFansNum: fansData.AllFansNum, Items: []*models.FansDataItem{}}
+	for _, group := range []struct {
+		category      string
+		distributions []*models.DouYinDistribution
+	}{
+		{"gender", fansData.GenderDistributions},
+		{"age", fansData.AgeDistributions},
+		{"device", fansData.DeviceDistributions},
+		{"interest", fansData.InterestDistributions},
+		{"active_days", fansData.ActiveDaysDistributions},
+	} {
+		for _, distribution := range group.distributions {
+			if distribution == nil {
+				continue
+			}
+			response.Items = append(response.Items, &models.FansDataItem{
+				Category: group.category,
+				Item:     distribution.Item,
+				Value:    distribution.Value,
+			})
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// decodeDouYinResponse 校验抖音响应的 HTTP 状态码并将响应体解析到 v
+func decodeDouYinResponse(response *http.Response, endpoint string, v interface{}) error {
+	if response.StatusCode != http.StatusOK {
+		return UpstreamStatusError(endpoint, response.StatusCode)
+	}
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return InternalError(errors.Wrapf(err, "read douyin %s response", endpoint))
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return NewActionError(KindUpstream, "抖音开放平台返回了无法解析的响应",
+			errors.Wrapf(err, "decode douyin %s response", endpoint))
+	}
+	return nil
 }
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"douyin-action-example/internal/apps"
+	"douyin-action-example/internal/cache"
+	"douyin-action-example/internal/conf"
+	"douyin-action-example/internal/logging"
+	"douyin-action-example/internal/metrics"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheStatusHeader 标记业务动作的响应是否来自缓存，取值为 HIT、MISS 或 BYPASS
+const CacheStatusHeader = "X-Cache"
+
+type cacheControlKey struct{}
+
+// cacheControl 为业务动作请求的缓存控制，由 CacheControl 写入请求的 ctx
+type cacheControl struct {
+	bypass bool
+	c      *gin.Context
+}
+
+var (
+	responseCache     cache.Backend
+	responseCacheOnce sync.Once
+)
+
+// ResponseCache 返回抖音读接口的响应缓存，未启用或创建失败时返回 nil
+func ResponseCache() cache.Backend {
+	responseCacheOnce.Do(func() {
+		if !conf.App.Cache.Enabled {
+			return
+		}
+		backend, err := cache.New(conf.App.Cache)
+		if err != nil {
+			logging.FromContext(context.Background()).Errorf("failed to create response cache, caching disabled: %+v", err)
+			return
+		}
+		responseCache = backend
+	})
+	return responseCache
+}
+
+// CacheControl 在请求头带有 Cache-Control: no-cache 时跳过响应缓存，直接请求抖音开放平台
+func CacheControl() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		control := &cacheControl{bypass: hasNoCache(c.GetHeader("Cache-Control")), c: c}
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), cacheControlKey{}, control))
+		c.Next()
+	}
+}
+
+func hasNoCache(cacheControl string) bool {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		if strings.EqualFold(strings.TrimSpace(directive), "no-cache") {
+			return true
+		}
+	}
+	return false
+}
+
+// DoCached 按 (open_id, 抖音 API 路径, 参数) 缓存抖音读接口的成功响应，未命中时调用 Do；
+// 访问令牌不参与缓存键，同一抖音用户的不同令牌共享缓存
+func (dc *DouYinClient) DoCached(request *http.Request, openId string) (*http.Response, error) {
+	backend := ResponseCache()
+	if backend == nil {
+		return dc.Do(request)
+	}
+	ctx := request.Context()
+	endpoint := request.URL.Path
+	key := cacheKey(openId, request)
+	control, _ := ctx.Value(cacheControlKey{}).(*cacheControl)
+	if control != nil && control.bypass {
+		metrics.ObserveCacheLookup(endpoint, "bypass")
+		control.setStatus("BYPASS")
+	} else if body, ok := backend.Get(key); ok {
+		metrics.ObserveCacheLookup(endpoint, "hit")
+		control.setStatus("HIT")
+		return &http.Response{
+			Status:     http.StatusText(http.StatusOK),
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(bytes.NewReader(body)),
+			Request:    request,
+		}, nil
+	} else {
+		metrics.ObserveCacheLookup(endpoint, "miss")
+		control.setStatus("MISS")
+	}
+
+	response, err := dc.Do(request)
+	if err != nil || response.StatusCode != http.StatusOK {
+		return response, err
+	}
+	// Do 返回的响应体可被再次读取，仅缓存没有业务错误码的响应
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	response.Body = io.NopCloser(bytes.NewReader(body))
+	if errorCode, ok := douYinErrorCode(body); !ok || errorCode == 0 {
+		backend.Set(key, body, cacheTTL(endpoint))
+	}
+	return response, nil
+}
+
+// cacheKey 由应用的命名空间、open_id、请求方法、抖音 API 路径及排序后的查询参数组成
+func cacheKey(openId string, request *http.Request) string {
+	namespace := ""
+	if app := apps.FromContext(request.Context()); app != nil {
+		namespace = app.Namespace
+	}
+	return namespace + " " + openId + " " + request.Method + " " + request.URL.Path + "?" + request.URL.Query().Encode()
+}
+
+// cacheTTL 返回抖音 API 的缓存时间，未单独配置时使用 cache.default_ttl
+func cacheTTL(endpoint string) time.Duration {
+	if ttl, ok := conf.App.Cache.TTLs[endpoint]; ok {
+		return time.Duration(ttl)
+	}
+	return time.Duration(conf.App.Cache.DefaultTTL)
+}
+
+// setStatus 在响应头中标记缓存结果，需在写入响应体之前调用
+func (cc *cacheControl) setStatus(status string) {
+	if cc != nil {
+		cc.c.Header(CacheStatusHeader, status)
+	}
+}
@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"douyin-action-example/internal/cache"
+	"douyin-action-example/internal/conf"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recordingCache 在 LRU 的基础上记录每个缓存键写入时的 TTL
+type recordingCache struct {
+	*cache.LRU
+	mu   sync.Mutex
+	ttls map[string]time.Duration
+}
+
+func (c *recordingCache) Set(key string, value []byte, ttl time.Duration) {
+	c.mu.Lock()
+	c.ttls[key] = ttl
+	c.mu.Unlock()
+	c.LRU.Set(key, value, ttl)
+}
+
+// TTLs 返回写入过的各缓存键的 TTL
+func (c *recordingCache) TTLs() map[string]time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	copied := make(map[string]time.Duration, len(c.ttls))
+	for key, ttl := range c.ttls {
+		copied[key] = ttl
+	}
+	return copied
+}
+
+// useResponseCache 启用默认缓存时间为一分钟、ttls 中的接口单独配置缓存时间的响应缓存，
+// 并以 recordingCache 替换按配置创建的缓存后端
+func useResponseCache(t *testing.T, ttls map[string]conf.Duration) *recordingCache {
+	t.Helper()
+	config := testAppsConfig()
+	config.Cache = conf.CacheConfig{Enabled: true, Backend: "memory", Capacity: 100, DefaultTTL: conf.Duration(time.Minute), TTLs: ttls}
+	config.DouYin.Retry.MaxAttempts = 1
+	useConfig(t, config)
+	backend := &recordingCache{LRU: cache.NewLRU(100), ttls: make(map[string]time.Duration)}
+	responseCacheOnce.Do(func() {})
+	responseCache = backend
+	t.Cleanup(func() { responseCache = nil })
+	return backend
+}
+
+// countingDouYin 模拟抖音接口，以 body 应答并返回调用次数
+func countingDouYin(t *testing.T, status int, body string) *int32 {
+	var calls int32
+	fakeDouYin(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		respondWith(status, body)(w)
+	}))
+	return &calls
+}
+
+// doCached 经 CacheControl 中间件以 cacheControl 请求头调用 DoCached，返回响应体及 X-Cache 响应头
+func doCached(t *testing.T, client *DouYinClient, openId, pathAndQuery, cacheControl string) (string, string) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/action", nil)
+	if cacheControl != "" {
+		c.Request.Header.Set("Cache-Control", cacheControl)
+	}
+	CacheControl()(c)
+	request, err := http.NewRequestWithContext(c.Request.Context(), http.MethodGet, conf.App.DouYin.OpenApiBaseUrl+pathAndQuery, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := client.DoCached(request, openId)
+	if err != nil {
+		t.Fatalf("DoCached %s: %v", pathAndQuery, err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body), recorder.Header().Get(CacheStatusHeader)
+}
+
+func TestDoCachedKey(t *testing.T) {
+	useResponseCache(t, nil)
+	client := newDouYinClient()
+	calls := countingDouYin(t, http.StatusOK, `{"data":{"error_code":0}}`)
+
+	steps := []struct {
+		openId       string
+		pathAndQuery string
+		status       string
+		calls        int32
+	}{
+		{"open-a", "/cache/key/?a=1&b=2", "MISS", 1},
+		{"open-a", "/cache/key/?a=1&b=2", "HIT", 1},
+		// 参数排序后参与缓存键
+		{"open-a", "/cache/key/?b=2&a=1", "HIT", 1},
+		{"open-b", "/cache/key/?a=1&b=2", "MISS", 2},
+		{"open-a", "/cache/key/?a=1&b=3", "MISS", 3},
+		{"open-a", "/cache/other/?a=1&b=2", "MISS", 4},
+		{"open-b", "/cache/key/?a=1&b=2", "HIT", 4},
+	}
+	for i, step := range steps {
+		body, status := doCached(t, client, step.openId, step.pathAndQuery, "")
+		if status != step.status || body != `{"data":{"error_code":0}}` {
+			t.Fatalf("step %d: X-Cache = %q, body %s, want %s", i, status, body, step.status)
+		}
+		if n := atomic.LoadInt32(calls); n != step.calls {
+			t.Fatalf("step %d: upstream called %d times, want %d", i, n, step.calls)
+		}
+	}
+}
+
+func TestDoCachedTTLPerEndpoint(t *testing.T) {
+	backend := useResponseCache(t, map[string]conf.Duration{"/cache/ttl/slow/": conf.Duration(6 * time.Hour)})
+	client := newDouYinClient()
+	countingDouYin(t, http.StatusOK, `{"data":{"error_code":0}}`)
+
+	doCached(t, client, "open-id", "/cache/ttl/slow/", "")
+	doCached(t, client, "open-id", "/cache/ttl/fast/", "")
+	ttls := backend.TTLs()
+	if len(ttls) != 2 {
+		t.Fatalf("cached %d responses, want 2: %v", len(ttls), ttls)
+	}
+	for key, ttl := range ttls {
+		want := time.Minute
+		if key == cacheKeyFor("open-id", "/cache/ttl/slow/") {
+			want = 6 * time.Hour
+		}
+		if ttl != want {
+			t.Errorf("ttl of %q = %s, want %s", key, ttl, want)
+		}
+	}
+}
+
+// cacheKeyFor 返回未指定应用的 GET 请求的缓存键
+func cacheKeyFor(openId, path string) string {
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	return cacheKey(openId, request)
+}
+
+func TestDoCachedNoCacheBypass(t *testing.T) {
+	useResponseCache(t, nil)
+	client := newDouYinClient()
+	calls := countingDouYin(t, http.StatusOK, `{"data":{"error_code":0}}`)
+
+	if _, status := doCached(t, client, "open-id", "/cache/bypass/", ""); status != "MISS" {
+		t.Fatalf("first request X-Cache = %q", status)
+	}
+	for _, header := range []string{"no-cache", "max-age=0, No-Cache"} {
+		if _, status := doCached(t, client, "open-id", "/cache/bypass/", header); status != "BYPASS" {
+			t.Fatalf("Cache-Control %q: X-Cache = %q, want BYPASS", header, status)
+		}
+	}
+	if n := atomic.LoadInt32(calls); n != 3 {
+		t.Fatalf("upstream called %d times, want 3", n)
+	}
+	if _, status := doCached(t, client, "open-id", "/cache/bypass/", ""); status != "HIT" {
+		t.Fatalf("request after bypass X-Cache = %q, want HIT", status)
+	}
+}
+
+func TestDoCachedSkipsErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"error code", http.StatusOK, `{"data":{"error_code":2190008,"description":"access_token expired"}}`},
+		{"extra error code", http.StatusOK, `{"data":{},"extra":{"error_code":10002}}`},
+		{"http error", http.StatusBadRequest, `{"message":"bad request"}`},
+	}
+	for i, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			backend := useResponseCache(t, nil)
+			client := newDouYinClient()
+			calls := countingDouYin(t, tc.status, tc.body)
+			path := fmt.Sprintf("/cache/error/%d/", i)
+			for j := 0; j < 2; j++ {
+				body, status := doCached(t, client, "open-id", path, "")
+				if status != "MISS" || body != tc.body {
+					t.Fatalf("request %d: X-Cache = %q, body %s", j, status, body)
+				}
+			}
+			if n := atomic.LoadInt32(calls); n != 2 {
+				t.Fatalf("upstream called %d times, want 2", n)
+			}
+			if ttls := backend.TTLs(); len(ttls) != 0 {
+				t.Fatalf("error responses are cached: %v", ttls)
+			}
+		})
+	}
+}
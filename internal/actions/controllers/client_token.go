@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"douyin-action-example/internal/apps"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const getClientTokenPath string = "/oauth/client_token/"
+
+// clientTokenRefreshAhead 为 client_token 提前刷新的时间，避免临近过期时使用
+const clientTokenRefreshAhead = 5 * time.Minute
+
+// ClientTokenProvider 获取并缓存抖音应用级的 client_token
+// 详见: https://developer.open-douyin.com/docs/resource/zh-CN/dop/develop/openapi/account-permission/client-token
+type ClientTokenProvider struct {
+	app       *apps.App
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+var (
+	clientTokensMu sync.Mutex
+	clientTokens   = make(map[string]*ClientTokenProvider)
+)
+
+// ClientTokensFor 返回应用的 client_token，各应用的 client_token 分别获取及缓存
+func ClientTokensFor(app *apps.App) *ClientTokenProvider {
+	clientTokensMu.Lock()
+	defer clientTokensMu.Unlock()
+	p, ok := clientTokens[app.ID]
+	if !ok || p.app != app {
+		p = &ClientTokenProvider{app: app}
+		clientTokens[app.ID] = p
+	}
+	return p
+}
+
+// Configured 判断是否配置了获取 client_token 所需的应用凭证
+func (p *ClientTokenProvider) Configured() bool {
+	return p.app.HasCredentials()
+}
+
+// Token 返回有效的 client_token，缓存的 client_token 即将过期时重新获取
+func (p *ClientTokenProvider) Token(ctx context.Context) (string, error) {
+	if !p.Configured() {
+		return "", errors.Errorf("douyin client_key and client_secret of app %s are not configured", p.app.ID)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.token != "" && time.Now().Add(clientTokenRefreshAhead).Before(p.expiresAt) {
+		return p.token, nil
+	}
+
+	requestBody, err := json.Marshal(map[string]string{
+		"client_key":    p.app.DouYinClientKey,
+		"client_secret": p.app.DouYinClientSecret,
+		"grant_type":    "client_credential",
+	})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	httpRequest, err := http.NewRequestWithContext(apps.WithApp(ctx, p.app), http.MethodPost, douYinUrl(getClientTokenPath), bytes.NewBuffer(requestBody))
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	dyClient, err := NewDouYinClient()
+	if err != nil {
+		return "", err
+	}
+	response, err := dyClient.Do(httpRequest)
+	if err != nil {
+		return "", errors.Wrap(err, "request client_token")
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("get client_token response not ok, statusCode=%d", response.StatusCode)
+	}
+
+	var clientTokenResponse struct {
+		Data struct {
+			AccessToken string  `json:"access_token"`
+			ExpiresIn   int64   `json:"expires_in"`
+			ErrorCode   float64 `json:"error_code"`
+			Description string  `json:"description"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &clientTokenResponse); err != nil {
+		return "", errors.Wrap(err, "decode client_token response")
+	}
+	if clientTokenResponse.Data.ErrorCode != 0 {
+		return "", fmt.Errorf("get client_token returns error, error_code=%v, description=%s",
+			clientTokenResponse.Data.ErrorCode, clientTokenResponse.Data.Description)
+	}
+	p.token = clientTokenResponse.Data.AccessToken
+	p.expiresAt = time.Now().Add(time.Duration(clientTokenResponse.Data.ExpiresIn) * time.Second)
+	return p.token, nil
+}
@@ -0,0 +1,194 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"douyin-action-example/internal/apps"
+	"douyin-action-example/internal/breaker"
+	"douyin-action-example/internal/conf"
+	"douyin-action-example/internal/logging"
+	"douyin-action-example/internal/metrics"
+	"douyin-action-example/internal/tracing"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrUpstreamUnavailable 表示抖音开放平台的端点处于熔断状态，请求未被发出
+var ErrUpstreamUnavailable = errors.New("douyin open platform is temporarily unavailable")
+
+// retryableDouYinErrorCodes 为抖音返回的可重试业务错误码：2100004 系统繁忙，请稍候再试
+var retryableDouYinErrorCodes = map[int64]bool{
+	2100004: true,
+}
+
+type DouYinClient struct {
+	httpClient *http.Client
+	breakers   *breaker.Group
+}
+
+var (
+	sharedDouYinClient     *DouYinClient
+	sharedDouYinClientOnce sync.Once
+)
+
+// NewDouYinClient 返回共享的抖音开放平台客户端，复用连接池、熔断状态及监控埋点
+func NewDouYinClient() (*DouYinClient, error) {
+	sharedDouYinClientOnce.Do(func() {
+		sharedDouYinClient = newDouYinClient()
+	})
+	return sharedDouYinClient, nil
+}
+
+func newDouYinClient() *DouYinClient {
+	httpClient := &http.Client{
+		Transport: metrics.InstrumentRoundTripper(logging.PropagateRequestID(tracing.Transport(&http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+				dialer := net.Dialer{}
+				return dialer.DialContext(ctx, "tcp", addr)
+			},
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 60 * time.Second,
+			ResponseHeaderTimeout: 60 * time.Second,
+		}))),
+	}
+	circuitBreaker := conf.App.DouYin.CircuitBreaker
+	return &DouYinClient{
+		httpClient: httpClient,
+		breakers:   breaker.NewGroup(circuitBreaker.FailureThreshold, time.Duration(circuitBreaker.OpenDuration)),
+	}
+}
+
+// Do 发送请求到抖音开放平台并读取完整的响应体，返回的响应体可被再次读取
+// 每次尝试的超时取端点配置且受调用方 ctx 约束；GET 请求在网络错误、5xx 或可重试错误码时以带抖动的指数退避重试；
+// 端点连续失败达到阈值后熔断，熔断期间直接返回 ErrUpstreamUnavailable，调用方取消的请求不计入熔断
+func (dc *DouYinClient) Do(request *http.Request) (*http.Response, error) {
+	endpoint := request.URL.Path
+	if err := allowEndpoint(apps.FromContext(request.Context()), endpoint); err != nil {
+		return nil, err
+	}
+	cb := dc.breakers.Get(endpoint)
+	if !cb.Allow() {
+		return nil, errors.Wrapf(ErrUpstreamUnavailable, "circuit breaker of %s is open", endpoint)
+	}
+
+	retry := conf.App.DouYin.Retry
+	attempts := 1
+	if request.Method == http.MethodGet && request.Body == nil && retry.MaxAttempts > 1 {
+		attempts = retry.MaxAttempts
+	}
+
+	var response *http.Response
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			delay := backoff(attempt-1, time.Duration(retry.BaseDelay), time.Duration(retry.MaxDelay))
+			logging.FromContext(request.Context()).Infof("retry douyin request %s in %s, attempt=%d, last_err=%v",
+				endpoint, delay, attempt, err)
+			select {
+			case <-request.Context().Done():
+				cb.Release()
+				return nil, errors.WithStack(request.Context().Err())
+			case <-time.After(delay):
+			}
+		}
+		var retryable bool
+		response, retryable, err = dc.attempt(request, endpoint)
+		if !retryable {
+			break
+		}
+	}
+
+	// 调用方断开连接或超过调用方的截止时间导致的失败与抖音无关，不计入熔断，避免少数调用方的取消使端点对全部用户熔断
+	switch {
+	case err != nil && request.Context().Err() != nil:
+		cb.Release()
+	case err != nil || response.StatusCode >= http.StatusInternalServerError:
+		cb.Failure()
+	default:
+		cb.Success()
+	}
+	return response, err
+}
+
+// attempt 发送一次请求，返回结果是否可以重试
+func (dc *DouYinClient) attempt(request *http.Request, endpoint string) (*http.Response, bool, error) {
+	ctx, cancel := context.WithTimeout(request.Context(), endpointTimeout(endpoint))
+	defer cancel()
+	response, err := dc.httpClient.Do(request.Clone(ctx))
+	if err != nil {
+		// 调用方取消请求时不再重试
+		return nil, request.Context().Err() == nil, errors.Wrap(err, "failed to send request")
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, request.Context().Err() == nil, errors.Wrap(err, "failed to read response")
+	}
+	response.Body = io.NopCloser(bytes.NewReader(body))
+	errorCode, hasErrorCode := douYinErrorCode(body)
+	// 配额耗尽时重试无济于事，暂停调用该 API 直到退避结束
+	if response.StatusCode == http.StatusTooManyRequests || (hasErrorCode && quotaDouYinErrorCodes[errorCode]) {
+		logging.FromContext(request.Context()).Warnf("douyin api %s quota exhausted, back off for %s",
+			endpoint, time.Duration(conf.App.RateLimit.QuotaBackoff))
+		backOffEndpoint(apps.FromContext(request.Context()), endpoint)
+		return response, false, nil
+	}
+	if response.StatusCode >= http.StatusInternalServerError {
+		return response, true, nil
+	}
+	if hasErrorCode && retryableDouYinErrorCodes[errorCode] {
+		return response, true, nil
+	}
+	return response, false, nil
+}
+
+// endpointTimeout 返回端点的超时，未单独配置时使用 douyin.timeout
+func endpointTimeout(endpoint string) time.Duration {
+	if timeout, ok := conf.App.DouYin.EndpointTimeouts[endpoint]; ok {
+		return time.Duration(timeout)
+	}
+	return time.Duration(conf.App.DouYin.Timeout)
+}
+
+// backoff 返回第 n 次重试前的等待时间，在指数退避的基础上加入随机抖动，避免重试请求同时到达
+func backoff(n int, base, max time.Duration) time.Duration {
+	delay := base << (n - 1)
+	if delay <= 0 || delay > max {
+		delay = max
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// douYinErrorCode 从抖音的响应中解析业务错误码，不同接口的错误码位于 data 或 extra 中
+func douYinErrorCode(body []byte) (int64, bool) {
+	var envelope struct {
+		Data struct {
+			ErrorCode *int64 `json:"error_code"`
+		} `json:"data"`
+		Extra struct {
+			ErrorCode *int64 `json:"error_code"`
+		} `json:"extra"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return 0, false
+	}
+	if envelope.Extra.ErrorCode != nil && *envelope.Extra.ErrorCode != 0 {
+		return *envelope.Extra.ErrorCode, true
+	}
+	if envelope.Data.ErrorCode != nil {
+		return *envelope.Data.ErrorCode, true
+	}
+	if envelope.Extra.ErrorCode != nil {
+		return *envelope.Extra.ErrorCode, true
+	}
+	return 0, false
+}
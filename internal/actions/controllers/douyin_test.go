@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"context"
+	"douyin-action-example/internal/actions/models"
+	"douyin-action-example/internal/breaker"
+	"douyin-action-example/internal/conf"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// useRetryConfig 使用毫秒级退避的重试配置及 threshold 次失败即熔断的配置，返回按该配置新建的客户端
+func useRetryConfig(t *testing.T, threshold int, openDuration time.Duration) *DouYinClient {
+	t.Helper()
+	config := testAppsConfig()
+	config.DouYin.Retry = conf.RetryConfig{
+		MaxAttempts: 3,
+		BaseDelay:   conf.Duration(time.Millisecond),
+		MaxDelay:    conf.Duration(4 * time.Millisecond),
+	}
+	config.DouYin.CircuitBreaker = conf.CircuitBreakerConfig{FailureThreshold: threshold, OpenDuration: conf.Duration(openDuration)}
+	useConfig(t, config)
+	return newDouYinClient()
+}
+
+// sequenceServer 依次以 responses 中的状态码及响应体应答，超出后重复最后一个，返回调用次数
+func sequenceServer(t *testing.T, responses ...func(w http.ResponseWriter)) *int32 {
+	var calls int32
+	fakeDouYin(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&calls, 1))
+		if n > len(responses) {
+			n = len(responses)
+		}
+		responses[n-1](w)
+	}))
+	return &calls
+}
+
+func respondWith(status int, body string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}
+}
+
+func doRequest(ctx context.Context, client *DouYinClient, method, path string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, method, conf.App.DouYin.OpenApiBaseUrl+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(request)
+}
+
+func TestDoRetries(t *testing.T) {
+	const ok = `{"data":{"error_code":0}}`
+	cases := []struct {
+		name      string
+		method    string
+		responses []func(w http.ResponseWriter)
+		status    int
+		calls     int32
+	}{
+		{"5xx", http.MethodGet, []func(http.ResponseWriter){respondWith(502, ""), respondWith(503, ""), respondWith(200, ok)}, 200, 3},
+		{"busy error code", http.MethodGet, []func(http.ResponseWriter){respondWith(200, `{"data":{"error_code":2100004}}`), respondWith(200, ok)}, 200, 2},
+		{"attempts exhausted", http.MethodGet, []func(http.ResponseWriter){respondWith(500, "")}, 500, 3},
+		{"not retried for post", http.MethodPost, []func(http.ResponseWriter){respondWith(500, ""), respondWith(200, ok)}, 500, 1},
+		{"client error not retried", http.MethodGet, []func(http.ResponseWriter){respondWith(400, ""), respondWith(200, ok)}, 400, 1},
+	}
+	for i, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := useRetryConfig(t, 10, time.Minute)
+			calls := sequenceServer(t, tc.responses...)
+			response, err := doRequest(context.Background(), client, tc.method, "/retry/"+string(rune('a'+i)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if response.StatusCode != tc.status || atomic.LoadInt32(calls) != tc.calls {
+				t.Errorf("status = %d after %d calls, want %d after %d", response.StatusCode, atomic.LoadInt32(calls), tc.status, tc.calls)
+			}
+		})
+	}
+}
+
+func TestDoQuotaErrorBacksOff(t *testing.T) {
+	client := useRetryConfig(t, 10, time.Minute)
+	calls := sequenceServer(t, respondWith(200, `{"data":{"error_code":2190001}}`), respondWith(200, `{"data":{"error_code":0}}`))
+	if _, err := doRequest(context.Background(), client, http.MethodGet, "/quota/"); err != nil {
+		t.Fatal(err)
+	}
+	// 配额耗尽时不重试，并在退避期间不再调用该 API
+	_, err := doRequest(context.Background(), client, http.MethodGet, "/quota/")
+	var rateLimited *RateLimitedError
+	if n := atomic.LoadInt32(calls); !errors.As(err, &rateLimited) || n != 1 {
+		t.Fatalf("expect the endpoint to be backed off after one call, got err=%v calls=%d", err, n)
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	base, max := 100*time.Millisecond, time.Second
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 50; i++ {
+		for n, want := range map[int]time.Duration{1: base, 2: 2 * base, 3: 4 * base, 10: max} {
+			delay := backoff(n, base, max)
+			if delay < want/2 || delay > want {
+				t.Fatalf("backoff(%d) = %s, want within [%s, %s]", n, delay, want/2, want)
+			}
+			if n == 1 {
+				seen[delay] = true
+			}
+		}
+	}
+	if len(seen) < 2 {
+		t.Error("backoff should add random jitter")
+	}
+}
+
+func TestDoCircuitBreaker(t *testing.T) {
+	client := useRetryConfig(t, 2, 50*time.Millisecond)
+	conf.App.DouYin.Retry.MaxAttempts = 1
+	var healthy atomic.Bool
+	var calls int32
+	fakeDouYin(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if healthy.Load() {
+			respondWith(200, `{"data":{"error_code":0}}`)(w)
+			return
+		}
+		respondWith(500, "")(w)
+	}))
+
+	for i := 0; i < 2; i++ {
+		if _, err := doRequest(context.Background(), client, http.MethodGet, "/breaker/"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := doRequest(context.Background(), client, http.MethodGet, "/breaker/")
+	if n := atomic.LoadInt32(&calls); !errors.Is(err, ErrUpstreamUnavailable) || n != 2 {
+		t.Fatalf("expect fail-fast after 2 failures, got err=%v calls=%d", err, n)
+	}
+	// 熔断期间的错误以 503 upstream_unavailable 返回给调用方
+	recorder := serve(http.MethodGet, "/action", func(c *gin.Context) { respondError(c, err) },
+		httptest.NewRequest(http.MethodGet, "/action", nil))
+	response := &models.ServiceError{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), response); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Code != http.StatusServiceUnavailable || response.Error != string(KindUpstreamUnavailable) {
+		t.Fatalf("fail-fast response = %d %+v", recorder.Code, response)
+	}
+
+	// 熔断时间结束后放行探测请求，成功后恢复
+	time.Sleep(60 * time.Millisecond)
+	healthy.Store(true)
+	probe, err := doRequest(context.Background(), client, http.MethodGet, "/breaker/")
+	if err != nil || probe.StatusCode != http.StatusOK {
+		t.Fatalf("half-open probe failed: %v", err)
+	}
+	if state := client.breakers.Get("/breaker/").State(); state != breaker.Closed {
+		t.Fatalf("state = %s, want closed after a successful probe", state)
+	}
+}
+
+func TestDoCallerCancelDoesNotTripBreaker(t *testing.T) {
+	client := useRetryConfig(t, 1, time.Minute)
+	fakeDouYin(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := doRequest(ctx, client, http.MethodGet, "/cancel/"); err == nil {
+		t.Fatal("expect an error for a cancelled request")
+	}
+	if state := client.breakers.Get("/cancel/").State(); state != breaker.Closed {
+		t.Fatalf("state = %s, caller cancellation must not count as an upstream failure", state)
+	}
+}
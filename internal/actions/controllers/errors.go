@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"douyin-action-example/internal/actions/models"
+	"douyin-action-example/internal/logging"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+// ErrorKind 为本服务的错误分类，决定错误响应的 HTTP 状态码及 error 字段
+type ErrorKind string
+
+const (
+	KindBadRequest          ErrorKind = "invalid_request"
+	KindUnauthorized        ErrorKind = "invalid_token"
+	KindInsufficientScope   ErrorKind = "insufficient_scope"
+	KindNotFound            ErrorKind = "not_found"
+	KindForbidden           ErrorKind = "access_denied"
+	KindUpstream            ErrorKind = "upstream_error"
+	KindUpstreamUnavailable ErrorKind = "upstream_unavailable"
+	KindRateLimited         ErrorKind = "rate_limited"
+	KindInternal            ErrorKind = "server_error"
+)
+
+var errorKindStatus = map[ErrorKind]int{
+	KindBadRequest:          http.StatusBadRequest,
+	KindUnauthorized:        http.StatusUnauthorized,
+	KindInsufficientScope:   http.StatusForbidden,
+	KindNotFound:            http.StatusNotFound,
+	KindForbidden:           http.StatusForbidden,
+	KindUpstream:            http.StatusBadGateway,
+	KindUpstreamUnavailable: http.StatusServiceUnavailable,
+	KindRateLimited:         http.StatusTooManyRequests,
+	KindInternal:            http.StatusInternalServerError,
+}
+
+// Status 返回错误分类对应的 HTTP 状态码
+func (k ErrorKind) Status() int {
+	if status, ok := errorKindStatus[k]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// ActionError 为业务动作的错误，由 respondError 渲染为 models.ServiceError
+type ActionError struct {
+	Kind ErrorKind
+	// Description 为可以转述给用户的错误说明
+	Description string
+	// Endpoint 及 DouYinCode 为抖音开放平台返回业务错误时的 API 路径及错误码
+	Endpoint   string
+	DouYinCode int64
+	// Status 不为 0 时覆盖错误分类的 HTTP 状态码
+	Status int
+	Err    error
+}
+
+func (e *ActionError) Error() string {
+	message := fmt.Sprintf("%s: %s", e.Kind, e.Description)
+	if e.DouYinCode != 0 {
+		message = fmt.Sprintf("%s (douyin %s error_code=%d)", message, e.Endpoint, e.DouYinCode)
+	}
+	if e.Err != nil {
+		message = fmt.Sprintf("%s: %s", message, e.Err.Error())
+	}
+	return message
+}
+
+func (e *ActionError) Unwrap() error {
+	return e.Err
+}
+
+// NewActionError 创建指定分类的错误，err 为可选的内部原因，不会返回给调用方
+func NewActionError(kind ErrorKind, description string, err error) *ActionError {
+	return &ActionError{Kind: kind, Description: description, Err: err}
+}
+
+// BadRequest 表示调用方的请求参数不合法
+func BadRequest(description string) *ActionError {
+	return NewActionError(KindBadRequest, description, nil)
+}
+
+// InternalError 表示本服务内部错误，err 仅记录在日志中
+func InternalError(err error) *ActionError {
+	return NewActionError(KindInternal, "服务内部错误，请稍后再试", err)
+}
+
+// UpstreamError 将抖音开放平台返回的业务错误码转换为错误，错误说明优先使用 douYinErrorMessages 中的友好提示
+func UpstreamError(endpoint string, errorCode int64, description string) *ActionError {
+	e := &ActionError{
+		Kind:        KindUpstream,
+		Description: description,
+		Endpoint:    endpoint,
+		DouYinCode:  errorCode,
+	}
+	if message, ok := douYinErrorMessages[errorCode]; ok {
+		e.Description = message.Description
+		e.Status = message.Status
+	}
+	if e.Description == "" {
+		e.Description = fmt.Sprintf("抖音开放平台返回错误，错误码 %d", errorCode)
+	}
+	return e
+}
+
+// UpstreamStatusError 表示抖音开放平台返回了非 200 的 HTTP 状态码
+func UpstreamStatusError(endpoint string, statusCode int) *ActionError {
+	return NewActionError(KindUpstream, "抖音开放平台返回异常，请稍后再试",
+		errors.Errorf("douyin %s responded with status %d", endpoint, statusCode))
+}
+
+// douYinFailure 返回抖音响应中的业务错误码及描述，不同接口的错误码位于 data 或 extra 中
+func douYinFailure(data models.DouYinError, extra models.DouYinExtra) (int64, string) {
+	if extra.ErrorCode != 0 {
+		return extra.ErrorCode, extra.Description
+	}
+	return data.ErrorCode, data.Description
+}
+
+// respondError 按错误分类渲染错误响应，未分类的错误视为内部错误
+func respondError(c *gin.Context, err error) {
+	var rateLimitedError *RateLimitedError
+	if errors.As(err, &rateLimitedError) {
+		respondRateLimited(c, rateLimitedError)
+		return
+	}
+	var actionError *ActionError
+	if !errors.As(err, &actionError) {
+		if errors.Is(err, ErrUpstreamUnavailable) {
+			actionError = NewActionError(KindUpstreamUnavailable, "抖音开放平台暂时不可用，请稍后再试", err)
+		} else {
+			actionError = InternalError(err)
+		}
+	}
+
+	log := logging.FromContext(c.Request.Context())
+	if actionError.DouYinCode != 0 {
+		observeDouYinError(c, actionError.Endpoint, float64(actionError.DouYinCode))
+	}
+	status := actionError.Status
+	if status == 0 {
+		status = actionError.Kind.Status()
+	}
+	if status >= http.StatusInternalServerError {
+		log.Errorf("request failed, status=%d, err=%+v", status, err)
+	} else {
+		log.Infof("request failed, status=%d, err=%s", status, err.Error())
+	}
+	recordRecentError(c, status, actionError.Kind, actionError.Description, actionError.DouYinCode)
+	setAuditResult(c, actionError.Kind, actionError.DouYinCode)
+	c.JSON(status, &models.ServiceError{
+		Error:            string(actionError.Kind),
+		ErrorCode:        float64(actionError.DouYinCode),
+		ErrorDescription: actionError.Description,
+	})
+}
+
+// Recovery 将处理请求时的 panic 转换为内部错误响应，并记录堆栈
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			// http.ErrAbortHandler 用于主动中断响应，交由 net/http 处理
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+			err := errors.Errorf("panic: %v", recovered)
+			if c.Writer.Written() {
+				logging.FromContext(c.Request.Context()).Errorf("recovered after response was written, err=%+v", err)
+				c.Abort()
+				return
+			}
+			respondError(c, err)
+			c.Abort()
+		}()
+		c.Next()
+	}
+}
+
+type douYinErrorMessage struct {
+	Status      int
+	Description string
+}
+
+// douYinErrorMessages 为抖音开放平台常见业务错误码对应的 HTTP 状态码及可以转述给用户的说明
+var douYinErrorMessages = map[int64]douYinErrorMessage{
+	2100004: {http.StatusServiceUnavailable, "抖音系统繁忙，请稍后再试"},
+	2100005: {http.StatusBadRequest, "请求参数不合法"},
+	2100007: {http.StatusForbidden, "没有权限执行该操作"},
+	2190001: {http.StatusTooManyRequests, "抖音开放平台的调用次数已用完，请稍后再试"},
+	2190002: {http.StatusUnauthorized, "抖音授权已失效，请重新授权"},
+	2190003: {http.StatusForbidden, "用户未授予该权限，请重新授权并勾选对应的权限"},
+	2190004: {http.StatusForbidden, "应用未获得该能力，请联系管理员在抖音开放平台申请"},
+	2190008: {http.StatusUnauthorized, "抖音授权已过期，请重新授权"},
+	2190015: {http.StatusUnauthorized, "抖音授权与当前账号不匹配，请重新授权"},
+	2190016: {http.StatusForbidden, "应用已被封禁或下线，请联系管理员"},
+	10008:   {http.StatusUnauthorized, "抖音授权已过期，请重新授权"},
+}
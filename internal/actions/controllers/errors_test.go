@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"douyin-action-example/internal/actions/models"
+	"douyin-action-example/internal/actions/storage"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRespondError(t *testing.T) {
+	useConfig(t, testAppsConfig())
+	cases := []struct {
+		name        string
+		err         error
+		status      int
+		kind        ErrorKind
+		errorCode   float64
+		description string
+	}{
+		{"bad request", BadRequest("cursor must be a number"), 400, KindBadRequest, 0, "cursor must be a number"},
+		{"wrapped action error", errors.Wrap(NewActionError(KindNotFound, "账号不存在", nil), "select account"), 404, KindNotFound, 0, "账号不存在"},
+		{"translated douyin code", UpstreamError("/video/list/", 2190008, "access_token expired"), 401, KindUpstream, 2190008, "抖音授权已过期，请重新授权"},
+		{"douyin quota code", UpstreamError("/video/list/", 2190001, "quota exhausted"), 429, KindUpstream, 2190001, "抖音开放平台的调用次数已用完，请稍后再试"},
+		{"unknown douyin code", UpstreamError("/video/list/", 123, "something odd"), 502, KindUpstream, 123, "something odd"},
+		{"unknown douyin code without description", UpstreamError("/video/list/", 123, ""), 502, KindUpstream, 123, "抖音开放平台返回错误，错误码 123"},
+		{"douyin http status", UpstreamStatusError("/video/list/", 500), 502, KindUpstream, 0, "抖音开放平台返回异常，请稍后再试"},
+		{"upstream unavailable", errors.Wrap(ErrUpstreamUnavailable, "circuit breaker is open"), 503, KindUpstreamUnavailable, 0, "抖音开放平台暂时不可用，请稍后再试"},
+		{"unclassified error", errors.New("dial tcp 10.0.0.1:443: connection refused"), 500, KindInternal, 0, "服务内部错误，请稍后再试"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := serve(http.MethodGet, "/action", func(c *gin.Context) {
+				respondError(c, tc.err)
+			}, httptest.NewRequest(http.MethodGet, "/action", nil))
+			if recorder.Code != tc.status {
+				t.Fatalf("status = %d, want %d", recorder.Code, tc.status)
+			}
+			body := serviceError(t, recorder)
+			if body.Error != string(tc.kind) || body.ErrorCode != tc.errorCode || body.ErrorDescription != tc.description {
+				t.Fatalf("body = %+v", body)
+			}
+			// 内部原因只记录在日志中
+			if strings.Contains(recorder.Body.String(), "10.0.0.1") || strings.Contains(recorder.Body.String(), "circuit breaker") {
+				t.Fatalf("body leaks the internal error: %s", recorder.Body)
+			}
+		})
+	}
+}
+
+// recovered 以 Recovery 中间件调用 handler
+func recovered(handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	engine := gin.New()
+	engine.Use(Recovery())
+	engine.GET("/action", handler)
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/action", nil))
+	return recorder
+}
+
+func TestRecovery(t *testing.T) {
+	useConfig(t, testAppsConfig())
+	recorder := recovered(func(c *gin.Context) {
+		var data map[string]interface{}
+		_ = data["list"].([]interface{})
+	})
+	if recorder.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d", recorder.Code)
+	}
+	if body := serviceError(t, recorder); body.Error != string(KindInternal) || strings.Contains(body.ErrorDescription, "interface conversion") {
+		t.Fatalf("body = %+v", body)
+	}
+
+	// 已写入响应后发生 panic 时保留已写入的响应
+	recorder = recovered(func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+		panic("after write")
+	})
+	if recorder.Code != http.StatusOK || recorder.Body.String() != `{"status":"ok"}` {
+		t.Fatalf("status = %d, body %s", recorder.Code, recorder.Body)
+	}
+
+	// http.ErrAbortHandler 交由 net/http 中断响应
+	defer func() {
+		if r := recover(); r != http.ErrAbortHandler {
+			t.Fatalf("recovered %v, want http.ErrAbortHandler", r)
+		}
+	}()
+	recovered(func(c *gin.Context) {
+		panic(http.ErrAbortHandler)
+	})
+	t.Fatal("http.ErrAbortHandler was swallowed")
+}
+
+// getVideoList 以 open_id 的 Token 调用 GetVideoList
+func getVideoList(openId string) *httptest.ResponseRecorder {
+	return serve(http.MethodGet, "/video/list", func(c *gin.Context) {
+		c.Set(tokenInfoKey, &storage.TokenInfo{AccessToken: "act-" + openId, OpenID: openId})
+		NewBizController().GetVideoList(c)
+	}, httptest.NewRequest(http.MethodGet, "/video/list", nil))
+}
+
+func TestGetVideoListToleratesMissingFields(t *testing.T) {
+	useRetryConfig(t, 10, time.Minute)
+	fakeDouYin(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":{"list":[null,{"title":"no statistics"},{"statistics":{"play_count":1}}]}}`))
+	}))
+	recorder := getVideoList("open-missing-fields")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", recorder.Code, recorder.Body)
+	}
+	response := &models.GetVideoListResponse{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Videos) != 1 || response.Videos[0].Title != "no statistics" || response.Videos[0].PlayCount != 0 {
+		t.Fatalf("videos = %s", recorder.Body)
+	}
+}
+
+func TestGetVideoListUpstreamFailures(t *testing.T) {
+	cases := []struct {
+		name   string
+		status int
+		body   string
+		want   int
+		kind   ErrorKind
+	}{
+		{"http error", http.StatusBadRequest, `{}`, http.StatusBadGateway, KindUpstream},
+		{"douyin error code", http.StatusOK, `{"data":{"error_code":2190008,"description":"access_token expired"}}`, http.StatusUnauthorized, KindUpstream},
+		{"extra error code", http.StatusOK, `{"data":{},"extra":{"error_code":2190003,"description":"scope not granted"}}`, http.StatusForbidden, KindUpstream},
+		{"not json", http.StatusOK, `<html>`, http.StatusBadGateway, KindUpstream},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			useRetryConfig(t, 10, time.Minute)
+			fakeDouYin(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				respondWith(tc.status, tc.body)(w)
+			}))
+			recorder := getVideoList("open-" + tc.name)
+			if recorder.Code != tc.want {
+				t.Fatalf("status = %d, want %d, body %s", recorder.Code, tc.want, recorder.Body)
+			}
+			if body := serviceError(t, recorder); body.Error != string(tc.kind) || body.ErrorDescription == "" {
+				t.Fatalf("body = %+v", body)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"context"
+	"douyin-action-example/internal/actions/storage"
+	"douyin-action-example/internal/apps"
+	"douyin-action-example/internal/buildinfo"
+	"douyin-action-example/internal/logging"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"net/http"
+	"time"
+)
+
+// readinessTimeout 为就绪检查的整体超时，避免探针因抖音响应慢而长时间挂起
+const readinessTimeout = 5 * time.Second
+
+type HealthController struct {
+}
+
+func NewHealthController() *HealthController {
+	return &HealthController{}
+}
+
+// Healthz 用于存活探针，进程能够处理请求即返回成功
+func (hc *HealthController) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz 用于就绪探针，检查 Token 存储可用，以及在配置了应用凭证时能获取 client_token；配置在启动时已校验，不合法时服务不会启动
+// 探针无需认证，响应中每项只给出 ok、failed 或 skipped，失败原因仅记录在日志中
+func (hc *HealthController) Readyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	ready := true
+	checks := make(map[string]string)
+	check := func(name string, err error) {
+		if err != nil {
+			ready = false
+			checks[name] = "failed"
+			logging.FromContext(ctx).Warnf("readiness check %s failed, err=%s", name, err.Error())
+			return
+		}
+		checks[name] = "ok"
+	}
+
+	check("token_store", pingStores())
+	all := apps.All()
+	for _, app := range all {
+		// 仅有一个应用时沿用 client_token 作为检查项名称
+		name := "client_token"
+		if len(all) > 1 {
+			name += "/" + app.ID
+		}
+		provider := ClientTokensFor(app)
+		if !provider.Configured() {
+			checks[name] = "skipped"
+			continue
+		}
+		_, err := provider.Token(ctx)
+		check(name, err)
+	}
+
+	status, statusCode := "ready", http.StatusOK
+	if !ready {
+		status, statusCode = "not_ready", http.StatusServiceUnavailable
+	}
+	c.JSON(statusCode, gin.H{"status": status, "checks": checks})
+}
+
+// pingStores 检查全部命名空间的 Token 存储
+func pingStores() error {
+	for namespace, store := range storage.Stores() {
+		if err := store.Ping(); err != nil {
+			return errors.Wrapf(err, "token store of namespace %q", namespace)
+		}
+	}
+	return nil
+}
+
+// Version 返回构建信息
+func (hc *HealthController) Version(c *gin.Context) {
+	c.JSON(http.StatusOK, buildinfo.Get())
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"douyin-action-example/internal/conf"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type readiness struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+func readyz(t *testing.T) (int, *readiness, string) {
+	t.Helper()
+	recorder := serve(http.MethodGet, "/readyz", NewHealthController().Readyz, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	body := &readiness{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), body); err != nil {
+		t.Fatal(err)
+	}
+	return recorder.Code, body, recorder.Body.String()
+}
+
+func TestReadyz(t *testing.T) {
+	useConfig(t, conf.Default())
+	code, body, _ := readyz(t)
+	if code != http.StatusOK || body.Status != "ready" || body.Checks["token_store"] != "ok" || body.Checks["client_token"] != "skipped" {
+		t.Fatalf("status = %d, body %+v", code, body)
+	}
+	if _, ok := body.Checks["config"]; ok {
+		t.Fatalf("config is validated at startup and is not a readiness check: %+v", body)
+	}
+}
+
+func TestReadyzStorePingFailure(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "secret-dir")
+	config := conf.Default()
+	config.Storage.Backend = "file"
+	config.Storage.Path = filepath.Join(dir, "tokens.json")
+	useConfig(t, config)
+	// 存储目录在启动后被删除
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	code, body, raw := readyz(t)
+	if code != http.StatusServiceUnavailable || body.Status != "not_ready" || body.Checks["token_store"] != "failed" {
+		t.Fatalf("status = %d, body %s", code, raw)
+	}
+	// 探针无需认证，失败原因只记录在日志中
+	for _, leaked := range []string{"secret-dir", "no such file"} {
+		if strings.Contains(raw, leaked) {
+			t.Fatalf("readiness body leaks %q: %s", leaked, raw)
+		}
+	}
+}
+
+func TestReadyzClientTokenFailure(t *testing.T) {
+	useConfig(t, testAppsConfig())
+	fakeDouYin(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(r.URL.Path, "client_token") && strings.Contains(readBody(r), "beta-key") {
+			_, _ = w.Write([]byte(`{"data":{"error_code":10003,"description":"client_key invalid"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"access_token":"client-token","expires_in":7200,"error_code":0}}`))
+	}))
+
+	code, body, raw := readyz(t)
+	if code != http.StatusServiceUnavailable || body.Status != "not_ready" || body.Checks["token_store"] != "ok" ||
+		body.Checks["client_token/alpha"] != "ok" || body.Checks["client_token/beta"] != "failed" {
+		t.Fatalf("status = %d, body %s", code, raw)
+	}
+	if strings.Contains(raw, "client_key invalid") {
+		t.Fatalf("readiness body leaks the upstream error: %s", raw)
+	}
+}
+
+func readBody(r *http.Request) string {
+	body, _ := io.ReadAll(r.Body)
+	return string(body)
+}
@@ -1,38 +1,16 @@
 package controllers
 
 import (
-	"context"
+	"douyin-action-example/internal/conf"
+	"douyin-action-example/internal/logging"
+	"douyin-action-example/internal/metrics"
+	"fmt"
+	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
-	"net"
 	"net/http"
 	"strings"
-	"time"
 )
 
-type DouYinClient struct {
-	httpClient *http.Client
-}
-
-func NewDouYinClient() (*DouYinClient, error) {
-	httpClient := &http.Client{
-		Transport: &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
-			DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
-				dialer := net.Dialer{}
-				return dialer.DialContext(ctx, "tcp", addr)
-			},
-			MaxIdleConns:          100,
-			IdleConnTimeout:       90 * time.Second,
-			TLSHandshakeTimeout:   10 * time.Second,
-			ExpectContinueTimeout: 60 * time.Second,
-			ResponseHeaderTimeout: 60 * time.Second,
-		},
-	}
-	return &DouYinClient{
-		httpClient: httpClient,
-	}, nil
-}
-
 func GetBearerToken(r *http.Request) (string, error) {
 	// Get Authorization header
 	authHeader := r.Header.Get("Authorization")
@@ -49,3 +27,40 @@ func GetBearerToken(r *http.Request) (string, error) {
 
 	return parts[1], nil
 }
+
+// publicBaseUrl 返回本服务对外的访问地址，用于拼接回调地址、元数据中的端点以及 OpenAPI 描述中的 servers
+// 优先使用配置的地址；未配置时仅在信任反向代理的情况下采用 X-Forwarded-* 头，否则使用 Host 头
+func publicBaseUrl(c *gin.Context) string {
+	if conf.App.PublicBaseUrl != "" {
+		return conf.App.PublicBaseUrl
+	}
+	scheme, host := "https", c.Request.Host
+	if conf.App.TrustForwardedHeaders {
+		if proto := firstHeaderValue(c.Request.Header.Get("X-Forwarded-Proto")); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwardedHost := firstHeaderValue(c.Request.Header.Get("X-Forwarded-Host")); forwardedHost != "" {
+			host = forwardedHost
+		}
+	}
+	return fmt.Sprintf("%s://%s", scheme, host)
+}
+
+// firstHeaderValue 取逗号分隔的头部值中的第一个，多级代理会追加多个值
+func firstHeaderValue(value string) string {
+	if i := strings.Index(value, ","); i >= 0 {
+		value = value[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+// douYinUrl 返回抖音开放平台 API 的完整地址
+func douYinUrl(path string) string {
+	return conf.App.DouYin.OpenApiBaseUrl + path
+}
+
+// observeDouYinError 记录抖音开放平台返回的业务错误码，用于监控及访问日志
+func observeDouYinError(c *gin.Context, endpoint string, errorCode float64) {
+	metrics.ObserveDouYinError(endpoint, errorCode)
+	logging.SetUpstreamErrorCode(c.Request.Context(), errorCode)
+}
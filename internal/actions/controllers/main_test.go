@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"douyin-action-example/internal/actions/storage"
+	"douyin-action-example/internal/apps"
+	"douyin-action-example/internal/conf"
+	"github.com/chzealot/gobase/logger"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	logger.DefaultLogger = zap.NewNop()
+	logger.DefaultSugarLogger = logger.DefaultLogger.Sugar()
+	os.Exit(m.Run())
+}
+
+// useConfig 以 config 作为当前配置重新加载应用及内存存储，测试结束后恢复默认配置
+func useConfig(t *testing.T, config *conf.Config) {
+	t.Helper()
+	config.Normalize()
+	if err := config.Validate(); err != nil {
+		t.Fatalf("invalid test config: %v", err)
+	}
+	apply := func(config *conf.Config) {
+		conf.Use(config)
+		if err := apps.Init(config); err != nil {
+			t.Fatalf("init apps: %v", err)
+		}
+		if err := storage.Init(config.Storage, apps.Namespaces()); err != nil {
+			t.Fatalf("init storage: %v", err)
+		}
+	}
+	apply(config)
+	t.Cleanup(func() { apply(conf.Default()) })
+}
+
+// testAppsConfig 返回包含两个应用的配置，两个应用均配置了抖音应用凭证，因此会校验钉钉侧的客户端凭证
+func testAppsConfig() *conf.Config {
+	config := conf.Default()
+	config.Apps = []conf.DouYinAppConfig{
+		{ID: "alpha", ClientID: "alpha-client", ClientSecret: "alpha-secret", DouYinClientKey: "alpha-key", DouYinClientSecret: "alpha-douyin-secret",
+			RedirectUris: []string{"https://client.example.com/cb"}},
+		{ID: "beta", ClientID: "beta-client", ClientSecret: "beta-secret", DouYinClientKey: "beta-key", DouYinClientSecret: "beta-douyin-secret",
+			RedirectUris: []string{"https://beta.example.com/cb"}},
+	}
+	return config
+}
+
+// fakeDouYin 启动模拟的抖音开放平台，并将当前配置的 open_api_base_url 指向它
+func fakeDouYin(t *testing.T, handler http.Handler) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	conf.App.DouYin.OpenApiBaseUrl = server.URL
+	return server
+}
+
+// serve 以单个路由处理请求并返回响应
+func serve(method, path string, handler gin.HandlerFunc, request *http.Request) *httptest.ResponseRecorder {
+	engine := gin.New()
+	engine.Handle(method, path, handler)
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, request)
+	return recorder
+}
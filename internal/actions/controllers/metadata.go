@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"douyin-action-example/internal/actions/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+type MetadataController struct {
+	router *Router
+}
+
+func NewMetadataController(router *Router) *MetadataController {
+	return &MetadataController{
+		router: router,
+	}
+}
+
+// AuthorizationServer 返回 RFC 8414 定义的授权服务器元数据，端点地址取自实际注册的路由
+func (mc *MetadataController) AuthorizationServer(c *gin.Context) {
+	baseUrl := publicBaseUrl(c)
+	metadata := &models.AuthorizationServerMetadata{
+		Issuer:                            baseUrl,
+		AuthorizationEndpoint:             mc.endpointUrl(baseUrl, EndpointAuthorize),
+		TokenEndpoint:                     mc.endpointUrl(baseUrl, EndpointToken),
+		IntrospectionEndpoint:             mc.endpointUrl(baseUrl, EndpointIntrospect),
+		ScopesSupported:                   models.ScopeNames(),
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{grantTypeAuthorizationCode},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_post"},
+		CodeChallengeMethodsSupported:     supportedCodeChallengeMethods,
+	}
+	if metadata.IntrospectionEndpoint != "" {
+		metadata.IntrospectionEndpointAuthMethodsSupported = []string{"client_secret_basic", "client_secret_post"}
+	}
+	c.Header("Access-Control-Allow-Origin", "*")
+	c.JSON(http.StatusOK, metadata)
+}
+
+func (mc *MetadataController) endpointUrl(baseUrl, name string) string {
+	path := mc.router.Path(name)
+	if path == "" {
+		return ""
+	}
+	return baseUrl + path
+}
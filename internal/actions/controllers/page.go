@@ -0,0 +1,27 @@
+package controllers
+
+import (
+	"douyin-action-example/internal/actions/assets"
+	"douyin-action-example/internal/logging"
+	"github.com/gin-gonic/gin"
+	"html/template"
+)
+
+var errorPageTemplate = template.Must(template.New("error").Parse(assets.ErrorPageHtml))
+
+// ErrorPage 为展示给终端用户的错误页内容
+type ErrorPage struct {
+	Title   string
+	Message string
+	Detail  string
+}
+
+// renderErrorPage 向浏览器中的用户展示错误页，用于无法重定向回客户端的场景
+func renderErrorPage(c *gin.Context, status int, page *ErrorPage) {
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := errorPageTemplate.Execute(c.Writer, page); err != nil {
+		logging.FromContext(c.Request.Context()).Errorf("render error page failed, err=%s", err.Error())
+	}
+	c.Abort()
+}
@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+)
+
+// PKCE 定义详见: https://datatracker.ietf.org/doc/html/rfc7636
+const (
+	codeChallengeMethodPlain = "plain"
+	codeChallengeMethodS256  = "S256"
+)
+
+var supportedCodeChallengeMethods = []string{codeChallengeMethodPlain, codeChallengeMethodS256}
+
+func isSupportedCodeChallengeMethod(method string) bool {
+	for _, m := range supportedCodeChallengeMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func verifyCodeVerifier(verifier, challenge, method string) bool {
+	if verifier == "" {
+		return false
+	}
+	expected := verifier
+	if method == codeChallengeMethodS256 {
+		sum := sha256.Sum256([]byte(verifier))
+		expected = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
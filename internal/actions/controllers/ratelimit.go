@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"douyin-action-example/internal/actions/models"
+	"douyin-action-example/internal/apps"
+	"douyin-action-example/internal/conf"
+	"douyin-action-example/internal/ratelimit"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// quotaDouYinErrorCodes 为抖音返回的配额类错误码：2190001 quota 已用完
+var quotaDouYinErrorCodes = map[int64]bool{
+	2190001: true,
+}
+
+// RateLimitedError 表示请求因本服务的限流被拒绝
+type RateLimitedError struct {
+	RetryAfter time.Duration
+	Reason     string
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", e.Reason, e.RetryAfter)
+}
+
+// appLimiters 为一个应用的限流器，抖音按应用计算配额，各应用的限流互不影响
+type appLimiters struct {
+	user     *ratelimit.Limiter
+	endpoint *ratelimit.Limiter
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = make(map[string]*appLimiters)
+)
+
+// Limiters 返回应用按抖音用户及按抖音 API 路径的限流器，app 为 nil 时返回不属于任何应用的调用使用的限流器，
+// 未启用限流时返回 nil
+func Limiters(app *apps.App) (user, endpoint *ratelimit.Limiter) {
+	config := conf.App.RateLimit
+	if !config.Enabled {
+		return nil, nil
+	}
+	id := ""
+	if app != nil {
+		id = app.ID
+	}
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	l, ok := limiters[id]
+	if !ok {
+		overrides := make(map[string]ratelimit.Quota, len(config.Endpoints))
+		for path, quota := range config.Endpoints {
+			overrides[path] = toQuota(quota)
+		}
+		l = &appLimiters{
+			user:     ratelimit.New(toQuota(config.PerUser), nil),
+			endpoint: ratelimit.New(toQuota(config.PerEndpoint), overrides),
+		}
+		limiters[id] = l
+	}
+	return l.user, l.endpoint
+}
+
+func toQuota(q conf.QuotaConfig) ratelimit.Quota {
+	return ratelimit.Quota{Rate: q.Rate, Burst: q.Burst, Daily: q.Daily}
+}
+
+// RateLimit 按抖音用户限流，需在 RequireScope 之后执行
+func RateLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limiter, _ := Limiters(apps.FromContext(c.Request.Context()))
+		info, ok := tokenInfoFromContext(c)
+		if limiter == nil || !ok {
+			c.Next()
+			return
+		}
+		if allowed, retryAfter := limiter.Allow(info.OpenID); !allowed {
+			respondRateLimited(c, &RateLimitedError{RetryAfter: retryAfter, Reason: "too many requests for this douyin account"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// allowEndpoint 按应用及抖音 API 路径限流
+func allowEndpoint(app *apps.App, endpoint string) error {
+	_, limiter := Limiters(app)
+	if limiter == nil {
+		return nil
+	}
+	if allowed, retryAfter := limiter.Allow(endpoint); !allowed {
+		return &RateLimitedError{RetryAfter: retryAfter, Reason: fmt.Sprintf("douyin api %s is rate limited", endpoint)}
+	}
+	return nil
+}
+
+// backOffEndpoint 在抖音返回配额耗尽或 429 时暂停调用该 API
+func backOffEndpoint(app *apps.App, endpoint string) {
+	_, limiter := Limiters(app)
+	if limiter == nil {
+		return
+	}
+	limiter.Block(endpoint, time.Duration(conf.App.RateLimit.QuotaBackoff))
+}
+
+func respondRateLimited(c *gin.Context, err *RateLimitedError) {
+	recordRecentError(c, http.StatusTooManyRequests, KindRateLimited, err.Error(), 0)
+	setAuditResult(c, KindRateLimited, 0)
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(err.RetryAfter.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, &models.ServiceError{
+		Error:            string(KindRateLimited),
+		ErrorDescription: err.Error(),
+	})
+}
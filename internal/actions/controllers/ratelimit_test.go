@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"douyin-action-example/internal/actions/models"
+	"douyin-action-example/internal/actions/storage"
+	"douyin-action-example/internal/conf"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// useRateLimit 启用按抖音用户限流为 perUser、配额错误退避一分钟的限流配置，并丢弃之前按其他配置创建的限流器
+func useRateLimit(t *testing.T, perUser conf.QuotaConfig) {
+	t.Helper()
+	config := testAppsConfig()
+	config.RateLimit.Enabled = true
+	config.RateLimit.PerUser = perUser
+	config.RateLimit.QuotaBackoff = conf.Duration(time.Minute)
+	useConfig(t, config)
+	resetLimiters := func() {
+		limitersMu.Lock()
+		limiters = make(map[string]*appLimiters)
+		limitersMu.Unlock()
+	}
+	resetLimiters()
+	t.Cleanup(resetLimiters)
+}
+
+// rateLimited 以 open_id 的 Token 经 RateLimit 中间件调用 handler
+func rateLimited(openId string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	engine := gin.New()
+	engine.GET("/action", func(c *gin.Context) {
+		c.Set(tokenInfoKey, &storage.TokenInfo{OpenID: openId})
+	}, RateLimit(), handler)
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/action", nil))
+	return recorder
+}
+
+func assertRateLimited(t *testing.T, recorder *httptest.ResponseRecorder, retryAfter string) {
+	t.Helper()
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, body %s", recorder.Code, recorder.Body)
+	}
+	if got := recorder.Header().Get("Retry-After"); got != retryAfter {
+		t.Fatalf("Retry-After = %q, want %q", got, retryAfter)
+	}
+	body := &models.ServiceError{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), body); err != nil || body.Error != string(KindRateLimited) {
+		t.Fatalf("body %s, err %v", recorder.Body, err)
+	}
+}
+
+func TestRateLimitPerUser(t *testing.T) {
+	// 每 100 秒恢复一个令牌，请求间隔可忽略，被拒绝时需等待的时间向上取整为 100 秒
+	useRateLimit(t, conf.QuotaConfig{Rate: 0.01, Burst: 2})
+	ok := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	for i := 0; i < 2; i++ {
+		if recorder := rateLimited("open-a", ok); recorder.Code != http.StatusNoContent {
+			t.Fatalf("request %d: status = %d, body %s", i, recorder.Code, recorder.Body)
+		}
+	}
+	assertRateLimited(t, rateLimited("open-a", ok), "100")
+	if recorder := rateLimited("open-b", ok); recorder.Code != http.StatusNoContent {
+		t.Fatalf("other account is limited: status = %d", recorder.Code)
+	}
+}
+
+func TestRateLimitEndpointBackoff(t *testing.T) {
+	useRateLimit(t, conf.QuotaConfig{Rate: 100, Burst: 100})
+	backOffEndpoint(nil, "/ratelimit/backoff/")
+	recorder := rateLimited("open-id", func(c *gin.Context) {
+		respondError(c, allowEndpoint(nil, "/ratelimit/backoff/"))
+	})
+	assertRateLimited(t, recorder, "60")
+}
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxRecentErrors 为每个抖音账号保留的最近错误数
+const maxRecentErrors = 20
+
+// RecentError 为抖音账号最近一次失败的请求，仅保存在内存中，用于运维排查
+type RecentError struct {
+	Time        time.Time
+	OpenID      string
+	Action      string
+	Status      int
+	Kind        ErrorKind
+	Description string
+	DouYinCode  int64
+}
+
+type accountErrors struct {
+	namespace string
+	openId    string
+	errors    []*RecentError
+}
+
+var (
+	recentErrorsMu sync.Mutex
+	// recentErrors 以命名空间及 openId 区分账号，每个账号按时间顺序保留最近的错误
+	recentErrors = make(map[string]*accountErrors)
+)
+
+// recordRecentError 记录已选择抖音账号的请求的错误，未经过授权的请求不记录
+func recordRecentError(c *gin.Context, status int, kind ErrorKind, description string, douYinCode int64) {
+	info, ok := tokenInfoFromContext(c)
+	if !ok {
+		return
+	}
+	namespace := appNamespace(c)
+	key := namespace + "\x00" + info.OpenID
+	recentErrorsMu.Lock()
+	defer recentErrorsMu.Unlock()
+	account, ok := recentErrors[key]
+	if !ok {
+		account = &accountErrors{namespace: namespace, openId: info.OpenID}
+		recentErrors[key] = account
+	}
+	account.errors = append(account.errors, &RecentError{
+		Time:        time.Now(),
+		OpenID:      info.OpenID,
+		Action:      c.FullPath(),
+		Status:      status,
+		Kind:        kind,
+		Description: description,
+		DouYinCode:  douYinCode,
+	})
+	if overflow := len(account.errors) - maxRecentErrors; overflow > 0 {
+		account.errors = append(account.errors[:0:0], account.errors[overflow:]...)
+	}
+}
+
+// RecentErrors 返回命名空间中抖音账号最近的错误，最近的在前；openId 为空时返回命名空间中全部账号的错误
+func RecentErrors(namespace, openId string) []*RecentError {
+	recentErrorsMu.Lock()
+	var result []*RecentError
+	for _, account := range recentErrors {
+		if account.namespace != namespace || (openId != "" && account.openId != openId) {
+			continue
+		}
+		result = append(result, account.errors...)
+	}
+	recentErrorsMu.Unlock()
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Time.After(result[j].Time)
+	})
+	return result
+}
+
+// RecentErrorCount 返回抖音账号保留的最近错误数
+func RecentErrorCount(namespace, openId string) int {
+	recentErrorsMu.Lock()
+	defer recentErrorsMu.Unlock()
+	if account, ok := recentErrors[namespace+"\x00"+openId]; ok {
+		return len(account.errors)
+	}
+	return 0
+}
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"context"
+	"douyin-action-example/internal/actions/models"
+	"douyin-action-example/internal/actions/storage"
+	"douyin-action-example/internal/apps"
+	"douyin-action-example/internal/logging"
+	"douyin-action-example/internal/metrics"
+	"github.com/pkg/errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const refreshTokenPath string = "/oauth/refresh_token/"
+
+// RefreshToken 以 refresh_token 向抖音换取新的 Token，并替换应用存储中的原 Token
+// 详见: https://developer.open-douyin.com/docs/resource/zh-CN/dop/develop/openapi/account-permission/refresh-token
+func RefreshToken(ctx context.Context, app *apps.App, info *storage.TokenInfo) (*storage.TokenInfo, error) {
+	refreshed, err := refreshToken(ctx, app, info)
+	if err != nil {
+		metrics.ObserveTokenExchange("refresh_token", "failure")
+		return nil, err
+	}
+	metrics.ObserveTokenExchange("refresh_token", "success")
+	return refreshed, nil
+}
+
+func refreshToken(ctx context.Context, app *apps.App, info *storage.TokenInfo) (*storage.TokenInfo, error) {
+	if info.RefreshToken == "" {
+		return nil, errors.New("token has no refresh_token")
+	}
+	form := url.Values{}
+	form.Set("client_key", app.ClientKey(info.ClientID))
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", info.RefreshToken)
+	httpRequest, err := http.NewRequestWithContext(apps.WithApp(ctx, app), http.MethodPost,
+		douYinUrl(refreshTokenPath), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	httpRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	dyClient, err := NewDouYinClient()
+	if err != nil {
+		return nil, err
+	}
+	response, err := dyClient.Do(httpRequest)
+	if err != nil {
+		return nil, errors.Wrap(err, "request refresh_token")
+	}
+	defer response.Body.Close()
+	douYinResponse := &models.DouYinGetTokenResponse{}
+	if err := decodeDouYinResponse(response, refreshTokenPath, douYinResponse); err != nil {
+		return nil, err
+	}
+	if errorCode := douYinResponse.Data.ErrorCode; errorCode != 0 {
+		return nil, UpstreamError(refreshTokenPath, errorCode, douYinResponse.Data.Description)
+	}
+
+	refreshed := *info
+	refreshed.AccessToken = douYinResponse.Data.AccessToken
+	if douYinResponse.Data.RefreshToken != "" {
+		refreshed.RefreshToken = douYinResponse.Data.RefreshToken
+	}
+	if douYinResponse.Data.Scope != "" {
+		refreshed.Scopes = models.FromDouYinScopes(models.ParseScopes(douYinResponse.Data.Scope))
+	}
+	refreshed.IssuedAt = time.Now()
+	refreshed.ExpiresAt = refreshed.IssuedAt.Add(time.Duration(douYinResponse.Data.ExpiresIn) * time.Second)
+	if refreshExpiresIn := douYinResponse.Data.RefreshExpiresIn; refreshExpiresIn > 0 {
+		refreshed.RefreshExpiresAt = refreshed.IssuedAt.Add(time.Duration(refreshExpiresIn) * time.Second)
+	}
+	if err := storage.Tokens(app.Namespace).Replace(info.AccessToken, &refreshed); err != nil {
+		return nil, err
+	}
+	return &refreshed, nil
+}
+
+// TokenRefresher 定期以 RefreshToken 刷新已过期的 Token，使共享给其他钉钉用户的抖音账号在过期后仍可使用；
+// 每个抖音用户只刷新最近颁发的 Token，与按账号访问时使用的 Token 一致
+type TokenRefresher struct {
+	interval time.Duration
+}
+
+func NewTokenRefresher(interval time.Duration) *TokenRefresher {
+	return &TokenRefresher{
+		interval: interval,
+	}
+}
+
+func (r *TokenRefresher) Name() string {
+	return "token-refresher"
+}
+
+// Run 在启动时及每个间隔刷新一次，ctx 取消后中止进行中的刷新并返回
+func (r *TokenRefresher) Run(ctx context.Context) {
+	r.refreshExpired(ctx, time.Now())
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			r.refreshExpired(ctx, now)
+		}
+	}
+}
+
+// refreshExpired 刷新全部应用中在 now 时刻已过期且 RefreshToken 仍有效的 Token，返回刷新成功的数量
+func (r *TokenRefresher) refreshExpired(ctx context.Context, now time.Time) int {
+	log := logging.FromContext(ctx)
+	refreshed := 0
+	for namespace, d := range storage.Stores() {
+		app, ok := apps.ByNamespace(namespace)
+		if !ok {
+			continue
+		}
+		latest := make(map[string]*storage.TokenInfo)
+		for _, info := range d.List() {
+			// List 按颁发时间排序，后颁发的 Token 覆盖先颁发的
+			latest[info.OpenID] = info
+		}
+		for _, info := range latest {
+			if !info.IsExpired(now) || !info.CanRefresh(now) {
+				continue
+			}
+			if ctx.Err() != nil {
+				return refreshed
+			}
+			if _, err := RefreshToken(ctx, app, info); err != nil {
+				log.Warnf("refresh expired token failed, app=%s, open_id=%s, token=%s, err=%+v", app.ID, info.OpenID, info.ID(), err)
+				continue
+			}
+			refreshed++
+		}
+	}
+	if refreshed > 0 {
+		log.Infof("refreshed %d expired tokens", refreshed)
+	}
+	return refreshed
+}
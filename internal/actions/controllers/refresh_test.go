@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"context"
+	"douyin-action-example/internal/actions/storage"
+	"douyin-action-example/internal/apps"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeRefreshEndpoint 模拟抖音的刷新 Token 接口，返回调用次数
+func fakeRefreshEndpoint(t *testing.T) *int32 {
+	var calls int32
+	fakeDouYin(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.URL.Path != refreshTokenPath || r.FormValue("grant_type") != "refresh_token" {
+			t.Errorf("unexpected request %s %s", r.URL.Path, r.FormValue("grant_type"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"access_token":       "refreshed-" + r.FormValue("refresh_token"),
+				"refresh_token":      r.FormValue("refresh_token"),
+				"expires_in":         3600,
+				"refresh_expires_in": 86400,
+			},
+			"message": "success",
+		})
+	}))
+	return &calls
+}
+
+func TestTokenRefresherRefreshesExpiredTokens(t *testing.T) {
+	useConfig(t, testAppsConfig())
+	calls := fakeRefreshEndpoint(t)
+	app, _ := apps.ByID("alpha")
+	now := time.Now()
+	expired := func(accessToken, refreshToken, openId string, issuedAt time.Time) *storage.TokenInfo {
+		return &storage.TokenInfo{AccessToken: accessToken, RefreshToken: refreshToken, OpenID: openId, ClientID: "alpha-client",
+			IssuedAt: issuedAt, ExpiresAt: now.Add(-time.Minute), RefreshExpiresAt: now.Add(time.Hour)}
+	}
+	mustSave(t, app.Namespace, expired("older", "r-older", "open-1", now.Add(-3*time.Hour)))
+	mustSave(t, app.Namespace, expired("latest", "r-latest", "open-1", now.Add(-2*time.Hour)))
+	mustSave(t, app.Namespace, expired("no-refresh-token", "", "open-2", now.Add(-2*time.Hour)))
+	mustSave(t, app.Namespace, &storage.TokenInfo{AccessToken: "active", RefreshToken: "r-active", OpenID: "open-3",
+		IssuedAt: now, ExpiresAt: now.Add(time.Hour)})
+
+	if refreshed := NewTokenRefresher(time.Minute).refreshExpired(context.Background(), now); refreshed != 1 {
+		t.Fatalf("refreshed %d tokens, want 1", refreshed)
+	}
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Fatalf("refresh endpoint called %d times, want 1", n)
+	}
+	// 每个抖音用户只刷新最近颁发的 Token，刷新后按账号访问时使用新的 Token
+	store := storage.Tokens(app.Namespace)
+	info, err := store.LatestByOpenID("open-1")
+	if err != nil || info.AccessToken != "refreshed-r-latest" || info.IsExpired(now) {
+		t.Fatalf("latest token of open-1: %+v, %v", info, err)
+	}
+	if _, err := store.GetTokenInfo("latest"); err == nil {
+		t.Error("refreshed token is kept")
+	}
+	for _, accessToken := range []string{"older", "no-refresh-token", "active"} {
+		if _, err := store.GetTokenInfo(accessToken); err != nil {
+			t.Errorf("%s was removed", accessToken)
+		}
+	}
+}
+
+func TestTokenRefresherStopsOnShutdown(t *testing.T) {
+	useConfig(t, testAppsConfig())
+	fakeRefreshEndpoint(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		NewTokenRefresher(time.Millisecond).Run(ctx)
+		close(stopped)
+	}()
+	time.Sleep(5 * time.Millisecond)
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("refresher did not stop after shutdown")
+	}
+}
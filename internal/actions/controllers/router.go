@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"douyin-action-example/internal/actions/storage"
+	"douyin-action-example/internal/openapi"
+	"github.com/gin-gonic/gin"
+	"sync"
+)
+
+// 具名路由，元数据发现接口据此生成各端点的地址
+const (
+	EndpointAuthorize  = "authorization_endpoint"
+	EndpointToken      = "token_endpoint"
+	EndpointIntrospect = "introspection_endpoint"
+)
+
+// Router 包装了 gin 的路由注册，记录具名路由的路径，保证对外公布的端点与实际注册的路由一致
+type Router struct {
+	routes gin.IRoutes
+	paths  map[string]string
+	mu     sync.RWMutex
+}
+
+func NewRouter(routes gin.IRoutes) *Router {
+	return &Router{
+		routes: routes,
+		paths:  make(map[string]string),
+	}
+}
+
+// Handle 注册路由，name 非空时记录为具名路由
+func (r *Router) Handle(method, path, name string, handlers ...gin.HandlerFunc) {
+	r.routes.Handle(method, path, handlers...)
+	if name == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paths[name] = path
+}
+
+// Path 返回具名路由的路径，未注册时返回空字符串
+func (r *Router) Path(name string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.paths[name]
+}
+
+// Action 描述了一个供钉钉助理调用的业务动作，openapi.yaml 由全部动作生成
+type Action struct {
+	Method string
+	Path   string
+	// Scope 为调用该动作所需的授权范围，为空时只需要有效的 Token
+	Scope   string
+	Handler gin.HandlerFunc
+
+	OperationID string
+	Summary     string
+	Description string
+	// Query 为描述查询参数的结构体，字段以 form 标签命名、description 标签说明、enum 标签列出可选值，binding:"required" 表示必填
+	Query interface{}
+	// Body 为 JSON 请求体的结构体，字段以 json 标签命名、description 标签说明，binding:"required" 表示必填
+	Body interface{}
+	// Response 为成功响应的结构体，字段以 json 标签命名、description 标签说明
+	Response interface{}
+	// AllAccounts 为 true 时动作作用于钉钉用户关联的全部抖音账号，不按 account 参数选择账号
+	AllAccounts bool
+	// Role 为访问他人共享的抖音账号时需要的角色，为空时为 read
+	Role storage.Role
+}
+
+// HandleAction 注册业务动作，每次调用都会记录审计日志；调用前校验 Token 是否被授予了动作所需的授权范围，按 openapi.yaml 校验请求参数，
+// 按 account 参数选择抖音账号并校验共享账号的角色，按抖音用户限流，并处理缓存控制请求头
+func (r *Router) HandleAction(action *Action) {
+	handlers := []gin.HandlerFunc{Audit(), RequireScope(action.Scope), ValidateRequest(action.Method, action.Path)}
+	if !action.AllAccounts {
+		role := action.Role
+		if role == "" {
+			role = storage.RoleRead
+		}
+		handlers = append(handlers, SelectAccount(action.Scope, role))
+	}
+	handlers = append(handlers, RateLimit(), CacheControl(), action.Handler)
+	r.Handle(action.Method, action.Path, "", handlers...)
+}
+
+// Endpoint 返回用于生成 openapi.yaml 的动作描述
+func (a *Action) Endpoint() *openapi.Endpoint {
+	return &openapi.Endpoint{
+		Method:      a.Method,
+		Path:        a.Path,
+		OperationID: a.OperationID,
+		Summary:     a.Summary,
+		Description: a.Description,
+		Scope:       a.Scope,
+		Query:       a.Query,
+		Body:        a.Body,
+		Response:    a.Response,
+	}
+}
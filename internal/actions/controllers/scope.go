@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"douyin-action-example/internal/actions/models"
+	"douyin-action-example/internal/actions/storage"
+	"douyin-action-example/internal/apps"
+	"douyin-action-example/internal/logging"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+// RequireScope 校验 Bearer Token 有效且被授予了指定的授权范围
+// 错误响应遵循 RFC 6750 3.1，见 https://datatracker.ietf.org/doc/html/rfc6750#section-3.1
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accessToken, err := GetBearerToken(c.Request)
+		if err != nil {
+			abortWithBearerError(c, KindUnauthorized, err.Error(), scope)
+			return
+		}
+		info, namespace, err := storage.FindToken(accessToken)
+		if err != nil || info.IsExpired(time.Now()) {
+			abortWithBearerError(c, KindUnauthorized, "access token is unknown or expired", scope)
+			return
+		}
+		app, ok := apps.ByNamespace(namespace)
+		if !ok {
+			abortWithBearerError(c, KindUnauthorized, "access token belongs to an app that is no longer configured", scope)
+			return
+		}
+		c.Request = c.Request.WithContext(apps.WithApp(c.Request.Context(), app))
+		logging.SetOpenID(c.Request.Context(), info.OpenID)
+		c.Set(tokenInfoKey, info)
+		if scope != "" && !info.HasScope(scope) {
+			abortWithBearerError(c, KindInsufficientScope,
+				fmt.Sprintf("this action requires scope %q, please re-authorize", scope), scope)
+			return
+		}
+		c.Next()
+	}
+}
+
+const tokenInfoKey = "tokenInfo"
+
+// tokenInfoFromContext 返回 RequireScope 校验通过的 Token
+func tokenInfoFromContext(c *gin.Context) (*storage.TokenInfo, bool) {
+	value, ok := c.Get(tokenInfoKey)
+	if !ok {
+		return nil, false
+	}
+	info, ok := value.(*storage.TokenInfo)
+	return info, ok
+}
+
+func abortWithBearerError(c *gin.Context, kind ErrorKind, description, scope string) {
+	setAuditResult(c, kind, 0)
+	c.Header("WWW-Authenticate", fmt.Sprintf(`Bearer error="%s", error_description="%s", scope="%s"`, kind, description, scope))
+	c.AbortWithStatusJSON(kind.Status(), &models.ServiceError{
+		Error:            string(kind),
+		ErrorDescription: description,
+	})
+}
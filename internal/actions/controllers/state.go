@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"douyin-action-example/internal/actions/models"
+	"douyin-action-example/internal/conf"
+	"encoding/base64"
+	"github.com/pkg/errors"
+	"io"
+)
+
+// sealState 使用配置的首个密钥以 AES-GCM 加密 OAuth state，防止经由抖音中转时被篡改
+// 未配置密钥时以明文 JSON 传递
+func sealState(oac *models.OAuthCallback) (string, error) {
+	plaintext, err := oac.ToString()
+	if err != nil {
+		return "", err
+	}
+	keys, err := conf.App.State.DecodedKeys()
+	if err != nil {
+		return "", err
+	}
+	if len(keys) == 0 {
+		return plaintext, nil
+	}
+	aead, err := newStateAEAD(keys[0])
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", errors.WithStack(err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// openState 解密 OAuth state，依次尝试所有配置的密钥以支持密钥轮换
+func openState(state string) (*models.OAuthCallback, error) {
+	keys, err := conf.App.State.DecodedKeys()
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return models.NewOAuthCallbackFromJson(state)
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(state)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode state")
+	}
+	for _, key := range keys {
+		aead, err := newStateAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(sealed) < aead.NonceSize() {
+			return nil, errors.New("state too short")
+		}
+		plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+		if err == nil {
+			return models.NewOAuthCallbackFromJson(string(plaintext))
+		}
+	}
+	return nil, errors.New("state can not be opened by any configured key")
+}
+
+func newStateAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	return aead, errors.WithStack(err)
+}
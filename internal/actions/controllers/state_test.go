@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"douyin-action-example/internal/actions/models"
+	"douyin-action-example/internal/conf"
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newStateKey(t *testing.T) string {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func TestStateSealing(t *testing.T) {
+	oldKey, newKey := newStateKey(t), newStateKey(t)
+	config := conf.Default()
+	config.State.Keys = []string{oldKey}
+	useConfig(t, config)
+
+	oac := &models.OAuthCallback{ClientID: "client", RedirectUri: "https://client.example.com/cb", State: "s1", User: "user-1"}
+	sealed, err := sealState(oac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, "client.example.com") || strings.Contains(sealed, "user-1") {
+		t.Fatalf("sealed state leaks its content: %s", sealed)
+	}
+	opened, err := openState(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(opened, oac) {
+		t.Fatalf("opened = %+v, want %+v", opened, oac)
+	}
+
+	// 篡改后无法解密
+	raw, _ := base64.RawURLEncoding.DecodeString(sealed)
+	raw[len(raw)-1] ^= 1
+	if _, err := openState(base64.RawURLEncoding.EncodeToString(raw)); err == nil {
+		t.Fatal("expect tampered state to be rejected")
+	}
+	// 未加密的 state 不被接受
+	plain, _ := oac.ToString()
+	if _, err := openState(plain); err == nil {
+		t.Fatal("expect plaintext state to be rejected when keys are configured")
+	}
+
+	// 轮换后旧密钥加密的 state 仍可解密，新的 state 以新密钥加密
+	conf.App.State.Keys = []string{newKey, oldKey}
+	if _, err := openState(sealed); err != nil {
+		t.Fatalf("open state sealed with the previous key: %v", err)
+	}
+	resealed, err := sealState(oac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.App.State.Keys = []string{newKey}
+	if _, err := openState(resealed); err != nil {
+		t.Fatalf("open state sealed with the new key: %v", err)
+	}
+	if _, err := openState(sealed); err == nil {
+		t.Fatal("expect state sealed with a removed key to be rejected")
+	}
+}
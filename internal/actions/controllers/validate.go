@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"bytes"
+	"douyin-action-example/internal/actions/assets"
+	"douyin-action-example/internal/conf"
+	"douyin-action-example/internal/logging"
+	"douyin-action-example/internal/openapi"
+	"github.com/chzealot/gobase/logger"
+	"github.com/gin-gonic/gin"
+	"io"
+	"strings"
+	"sync"
+)
+
+var (
+	apiSpec     *openapi.Document
+	apiSpecOnce sync.Once
+)
+
+// ApiSpec 返回内嵌的 openapi.yaml 的解析结果，解析失败时返回 nil
+func ApiSpec() *openapi.Document {
+	apiSpecOnce.Do(func() {
+		doc, err := openapi.Parse([]byte(assets.OpenApiSpecYaml))
+		if err != nil {
+			logger.Errorf("parse embedded openapi.yaml failed, request validation disabled: %+v", err)
+			return
+		}
+		apiSpec = doc
+	})
+	return apiSpec
+}
+
+// ValidateRequest 按 openapi.yaml 中动作的定义校验查询参数及 JSON 请求体，不符合时返回 400；
+// 调试模式下同时校验 JSON 响应，不符合时仅记录日志
+func ValidateRequest(method, path string) gin.HandlerFunc {
+	spec := ApiSpec()
+	var operation *openapi.Operation
+	if spec != nil {
+		operation, _ = spec.Operation(method, path)
+	}
+	if operation == nil {
+		logger.Warnf("action %s %s is not documented in openapi.yaml, skip validation", method, path)
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		if err := spec.ValidateQuery(operation, c.Request.URL.Query()); err != nil {
+			respondError(c, NewActionError(KindBadRequest, "invalid query parameter "+err.Error(), err))
+			c.Abort()
+			return
+		}
+		if operation.RequestBody != nil && c.Request.Body != nil {
+			body, err := io.ReadAll(c.Request.Body)
+			if err != nil {
+				respondError(c, NewActionError(KindBadRequest, "failed to read request body", err))
+				c.Abort()
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			if err := spec.ValidateRequestBody(operation, body); err != nil {
+				respondError(c, NewActionError(KindBadRequest, "invalid request "+err.Error(), err))
+				c.Abort()
+				return
+			}
+		}
+
+		if !conf.IsDebugMode {
+			c.Next()
+			return
+		}
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+		if !strings.HasPrefix(recorder.Header().Get("Content-Type"), "application/json") {
+			return
+		}
+		if err := spec.ValidateResponse(operation, recorder.Status(), recorder.body.Bytes()); err != nil {
+			logging.FromContext(c.Request.Context()).Warnf("response of %s %s does not match openapi.yaml, status=%d, violation: %s",
+				method, path, recorder.Status(), err.Error())
+		}
+	}
+}
+
+// responseRecorder 在写出响应的同时保留响应体，用于校验
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"douyin-action-example/internal/actions/models"
+	"douyin-action-example/internal/actions/storage"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"net/http"
+	"time"
+)
+
+// 访问记录每次最多返回 100 条
+const (
+	defaultAccessLogLimit = 20
+	maxAccessLogLimit     = 100
+)
+
+// workspaceUser 返回管理共享的钉钉用户，未能识别钉钉用户时返回错误
+func workspaceUser(c *gin.Context) (string, *storage.TokenInfo, error) {
+	bearer, err := selectedToken(c)
+	if err != nil {
+		return "", nil, err
+	}
+	user := currentUser(c, bearer)
+	if user == "" {
+		return "", nil, NewActionError(KindForbidden, "无法识别当前钉钉用户，共享抖音账号需要配置 accounts.user_header", nil)
+	}
+	return user, bearer, nil
+}
+
+// ownAccount 按 openId 或昵称查找当前用户自己关联的抖音账号
+func ownAccount(c *gin.Context, bearer *storage.TokenInfo, account string) (*accessibleAccount, error) {
+	_, accounts := accessibleAccounts(c, bearer)
+	var own []*accessibleAccount
+	for _, a := range accounts {
+		if a.Role == "" {
+			own = append(own, a)
+		}
+	}
+	selected := findAccount(own, account)
+	if selected == nil || account == "" {
+		return nil, NewActionError(KindNotFound, fmt.Sprintf("没有关联抖音账号 %s，只能管理自己关联的账号", account), nil)
+	}
+	return selected, nil
+}
+
+func grantItem(grant *storage.AccountGrant, nickname string) *models.GrantItem {
+	return &models.GrantItem{
+		Account:   grant.OpenID,
+		Nickname:  nickname,
+		Owner:     grant.GrantedBy,
+		User:      grant.User,
+		Role:      string(grant.Role),
+		GrantedAt: grant.GrantedAt.Format(time.RFC3339),
+	}
+}
+
+// GetGrants 列出当前用户授予他人及他人授予当前用户的角色
+func (bc *BizController) GetGrants(c *gin.Context) {
+	user, _, err := workspaceUser(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	namespace := appNamespace(c)
+	links := storage.AccountLinks(namespace)
+	response := &models.GetGrantsResponse{Granted: []*models.GrantItem{}, Received: []*models.GrantItem{}}
+	grants := storage.Workspaces(namespace).Grants(func(grant *storage.AccountGrant) bool {
+		return grant.GrantedBy == user || grant.User == user
+	})
+	for _, grant := range grants {
+		linked, owner, ok := links.Find(grant.OpenID)
+		if !ok || owner != grant.GrantedBy {
+			continue
+		}
+		if grant.GrantedBy == user {
+			response.Granted = append(response.Granted, grantItem(grant, linked.Nickname))
+		} else {
+			response.Received = append(response.Received, grantItem(grant, linked.Nickname))
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Grant 将当前用户关联的抖音账号以 read 或 write 角色共享给其他钉钉用户，已共享时更新角色
+func (bc *BizController) Grant(c *gin.Context) {
+	request := &models.GrantRequest{}
+	if err := c.ShouldBindJSON(request); err != nil {
+		respondError(c, NewActionError(KindBadRequest, "invalid request body: "+err.Error(), err))
+		return
+	}
+	role, ok := storage.ParseRole(request.Role)
+	if !ok {
+		respondError(c, BadRequest(fmt.Sprintf("unsupported role %q, expect read or write", request.Role)))
+		return
+	}
+	user, bearer, err := workspaceUser(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if request.User == user {
+		respondError(c, BadRequest("不需要将账号共享给自己"))
+		return
+	}
+	account, err := ownAccount(c, bearer, request.Account)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	grant := &storage.AccountGrant{
+		OpenID:    account.OpenID,
+		User:      request.User,
+		Role:      role,
+		GrantedBy: user,
+		GrantedAt: time.Now(),
+	}
+	if err := storage.Workspaces(appNamespace(c)).Grant(grant); err != nil {
+		respondError(c, InternalError(err))
+		return
+	}
+	c.JSON(http.StatusOK, grantItem(grant, account.Nickname))
+}
+
+// Revoke 收回当前用户授予其他钉钉用户的角色
+func (bc *BizController) Revoke(c *gin.Context) {
+	request := &models.RevokeRequest{}
+	if err := c.ShouldBindJSON(request); err != nil {
+		respondError(c, NewActionError(KindBadRequest, "invalid request body: "+err.Error(), err))
+		return
+	}
+	_, bearer, err := workspaceUser(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	account, err := ownAccount(c, bearer, request.Account)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	err = storage.Workspaces(appNamespace(c)).Revoke(account.OpenID, request.User)
+	if errors.Is(err, storage.ErrGrantNotFound) {
+		c.JSON(http.StatusOK, &models.RevokeResponse{Revoked: false})
+		return
+	}
+	if err != nil {
+		respondError(c, InternalError(err))
+		return
+	}
+	c.JSON(http.StatusOK, &models.RevokeResponse{Revoked: true})
+}
+
+// GetAccessLog 列出当前用户关联的抖音账号最近的访问记录
+func (bc *BizController) GetAccessLog(c *gin.Context) {
+	query := &models.GetAccessLogQuery{Limit: defaultAccessLogLimit}
+	if err := c.ShouldBindQuery(query); err != nil {
+		respondError(c, NewActionError(KindBadRequest, "invalid query parameter: "+err.Error(), err))
+		return
+	}
+	if query.Limit <= 0 || query.Limit > maxAccessLogLimit {
+		query.Limit = defaultAccessLogLimit
+	}
+	_, bearer, err := workspaceUser(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	owned := make(map[string]bool)
+	if query.Account != "" {
+		account, err := ownAccount(c, bearer, query.Account)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		owned[account.OpenID] = true
+	} else {
+		_, accounts := accessibleAccounts(c, bearer)
+		for _, account := range accounts {
+			if account.Role == "" {
+				owned[account.OpenID] = true
+			}
+		}
+	}
+
+	records, err := storage.AccessLogs(appNamespace(c)).Records(func(record *storage.AccessRecord) bool {
+		return owned[record.OpenID]
+	}, query.Limit)
+	if err != nil {
+		respondError(c, InternalError(err))
+		return
+	}
+	response := &models.GetAccessLogResponse{Records: []*models.AccessItem{}}
+	for _, record := range records {
+		// 允许的访问未使用授予的角色时为所有者访问，被拒绝的访问可能没有任何角色
+		role := string(record.Role)
+		if role == "" && record.Allowed {
+			role = roleOwner
+		}
+		response.Records = append(response.Records, &models.AccessItem{
+			Time:    record.Time.Format(time.RFC3339),
+			User:    record.User,
+			Account: record.OpenID,
+			Action:  record.Action,
+			Role:    role,
+			Allowed: record.Allowed,
+			Reason:  record.Reason,
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
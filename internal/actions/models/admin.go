@@ -0,0 +1,68 @@
+package models
+
+type AdminAccountItem struct {
+	App          string `json:"app" description:"抖音账号所属的应用"`
+	User         string `json:"user" description:"关联该账号的钉钉用户标识，未关联时为空"`
+	OpenID       string `json:"openId" description:"抖音账号的 openId"`
+	Nickname     string `json:"nickname" description:"抖音昵称，查询过用户信息后才有值"`
+	Primary      bool   `json:"primary" description:"是否为钉钉用户的主账号"`
+	LinkedAt     string `json:"linkedAt,omitempty" description:"关联时间，RFC 3339 格式"`
+	ExpiresAt    string `json:"expiresAt,omitempty" description:"最近颁发的 Token 的过期时间，RFC 3339 格式，没有 Token 时为空"`
+	Expired      bool   `json:"expired" description:"最近颁发的 Token 是否已过期，没有 Token 时为 true"`
+	RecentErrors int    `json:"recentErrors" description:"保留的最近错误数"`
+}
+
+type AdminAccountsResponse struct {
+	Accounts []*AdminAccountItem `json:"accounts" description:"已关联或持有 Token 的抖音账号"`
+}
+
+type AdminTokenItem struct {
+	ID              string   `json:"id" description:"Token ID，为 access_token 摘要的前 12 位"`
+	App             string   `json:"app" description:"Token 所属的应用"`
+	OpenID          string   `json:"openId" description:"抖音账号的 openId"`
+	User            string   `json:"user" description:"关联该账号的钉钉用户标识，未关联时为空"`
+	ClientID        string   `json:"clientId" description:"申请 Token 的钉钉侧 client_id"`
+	Scopes          []string `json:"scopes" description:"已授予的授权范围"`
+	IssuedAt        string   `json:"issuedAt" description:"颁发时间，RFC 3339 格式"`
+	ExpiresAt       string   `json:"expiresAt" description:"过期时间，RFC 3339 格式"`
+	Expired         bool     `json:"expired" description:"是否已过期"`
+	HasRefreshToken bool     `json:"hasRefreshToken" description:"是否可以刷新"`
+}
+
+type AdminTokensResponse struct {
+	Tokens []*AdminTokenItem `json:"tokens" description:"全部 Token，不包含 Token 值，按过期时间排序"`
+}
+
+type AdminErrorsQuery struct {
+	App     string `form:"app" description:"应用标识，默认为全部应用"`
+	Account string `form:"account" description:"只查看该抖音账号（openId）的错误"`
+}
+
+type AdminErrorItem struct {
+	Time        string  `json:"time" description:"发生时间，RFC 3339 格式"`
+	App         string  `json:"app" description:"请求所属的应用"`
+	OpenID      string  `json:"openId" description:"请求使用的抖音账号"`
+	Action      string  `json:"action" description:"业务动作的路径"`
+	Status      int     `json:"status" description:"响应的 HTTP 状态码"`
+	Error       string  `json:"error" description:"错误分类"`
+	Description string  `json:"description" description:"错误说明"`
+	ErrorCode   float64 `json:"errorCode,omitempty" description:"抖音开放平台返回的错误码"`
+}
+
+type AdminErrorsResponse struct {
+	Errors []*AdminErrorItem `json:"errors" description:"最近的错误，最近的在前，每个账号最多保留 20 条"`
+}
+
+type AdminRateLimitItem struct {
+	App          string  `json:"app" description:"限流器所属的应用"`
+	Kind         string  `json:"kind" description:"限流维度：user 按抖音账号，endpoint 按抖音 API 路径" enum:"user,endpoint"`
+	Key          string  `json:"key" description:"抖音账号的 openId 或抖音 API 路径"`
+	Tokens       float64 `json:"tokens" description:"令牌桶中剩余的令牌数"`
+	DailyUsed    int     `json:"dailyUsed" description:"当日已使用的次数"`
+	BlockedUntil string  `json:"blockedUntil,omitempty" description:"因抖音配额耗尽暂停调用的截止时间，RFC 3339 格式"`
+}
+
+type AdminRateLimitsResponse struct {
+	Enabled bool                  `json:"enabled" description:"是否启用了限流"`
+	Limits  []*AdminRateLimitItem `json:"limits" description:"各限流键的当前状态"`
+}
@@ -11,10 +11,10 @@ type GetUserInfoRequest struct {
 }
 
 type GetUserInfoResponse struct {
-	AvatarUrl string `json:"avatarUrl"`
-	Nick      string `json:"nick"`
-	OpenID    string `json:"openId"`
-	UnionID   string `json:"unionId"`
+	AvatarUrl string `json:"avatarUrl" description:"用户的头像 URL，可以在 Markdown 中以图片形式展示"`
+	Nick      string `json:"nick" description:"用户昵称"`
+	OpenID    string `json:"openId" description:"用户在当前应用的唯一标识"`
+	UnionID   string `json:"unionId" description:"用户在当前开发者账号下的唯一标识（未绑定开发者账号没有该字段）"`
 }
 
 type GetVideoListRequest struct {
@@ -24,21 +24,29 @@ type GetVideoListRequest struct {
 	Count       int    `json:"count"`
 }
 
+// AccountQuery 为各业务动作共用的账号选择参数
+type AccountQuery struct {
+	Account string `form:"account" description:"要查询的抖音账号，取值为 /accounts 返回的 openId 或昵称，默认为主账号"`
+}
+
+type GetVideoListQuery struct {
+	AccountQuery
+	Cursor int `form:"cursor" description:"分页游标，第一页为 0，下一页使用上一页响应中的 cursor"`
+	Count  int `form:"count" description:"每页的视频数量，默认 5，最大 20"`
+}
+
 type GetVideoListResponse struct {
-	Videos []*VideoItem `json:"videos"`
+	Videos  []*VideoItem `json:"videos" description:"最近发布的视频"`
+	Cursor  int          `json:"cursor" description:"下一页的分页游标"`
+	HasMore bool         `json:"hasMore" description:"是否还有更多视频"`
 }
 
 type VideoItem struct {
-	// 视频标题
-	Title string `json:"title"`
-	// 点赞数
-	DiggCount int64 `json:"diggCount"`
-	// 播放数，只有作者本人可见。公开视频设为私密后，播放数也会返回0
-	PlayCount int64 `json:"playCount"`
-	// 分享数
-	ShareCount int64 `json:"shareCount"`
-	// 评论数
-	CommentCount int64 `json:"commentCount"`
+	Title        string `json:"title" description:"视频标题"`
+	DiggCount    int64  `json:"diggCount" description:"点赞数"`
+	PlayCount    int64  `json:"playCount" description:"播放数，只有作者本人可见。公开视频设为私密后，播放数也会返回0"`
+	ShareCount   int64  `json:"shareCount" description:"分享数"`
+	CommentCount int64  `json:"commentCount" description:"评论数"`
 }
 
 type GetFansDataRequest struct {
@@ -47,8 +55,27 @@ type GetFansDataRequest struct {
 }
 
 type GetFansDataResponse struct {
-	// 分类
-	Item string `json:"item"`
-	// 数值
-	Value int64 `json:"value"`
+	AllFansNum int64           `json:"allFansNum" description:"粉丝总数"`
+	Items      []*FansDataItem `json:"items" description:"粉丝画像，按分类列出各项的占比"`
+}
+
+type FansDataItem struct {
+	Category string `json:"category" description:"分类：gender 性别，age 年龄，device 设备，interest 兴趣，active_days 活跃天数"`
+	Item     string `json:"item" description:"分类下的具体项，如男、女、18-23"`
+	Value    int64  `json:"value" description:"该项的粉丝数"`
+}
+
+type GetAccountsResponse struct {
+	Accounts []*AccountItem `json:"accounts" description:"当前钉钉用户关联的抖音账号，主账号在前"`
+}
+
+type AccountItem struct {
+	OpenID   string   `json:"openId" description:"账号在当前应用的唯一标识，可作为其他动作的 account 参数"`
+	Nickname string   `json:"nickname" description:"账号昵称，查询过用户信息后才有值，可作为其他动作的 account 参数"`
+	Primary  bool     `json:"primary" description:"是否为主账号，其他动作未指定 account 时查询主账号"`
+	Owner    string   `json:"owner" description:"账号所有者的钉钉用户标识，未配置钉钉用户标识时为空"`
+	Role     string   `json:"role" description:"当前用户在该账号上的角色：owner 所有者，read 只读，write 读写" enum:"owner,read,write"`
+	Scopes   []string `json:"scopes" description:"账号授予的授权范围"`
+	Expired  bool     `json:"expired" description:"账号的授权是否已过期，过期后需要用户重新授权该账号"`
+	LinkedAt string   `json:"linkedAt" description:"关联时间，RFC 3339 格式"`
 }
@@ -6,6 +6,13 @@ type OAuthCallback struct {
 	State       string `json:"state"`
 	ClientID    string `json:"clientId"`
 	RedirectUri string `json:"redirectUri"`
+	// PKCE 参数，客户端未使用 PKCE 时为空
+	CodeChallenge       string `json:"codeChallenge,omitempty"`
+	CodeChallengeMethod string `json:"codeChallengeMethod,omitempty"`
+	// User 为发起授权的钉钉用户标识，授权的抖音账号将关联到该用户，未配置 accounts.user_header 时为空
+	User string `json:"user,omitempty"`
+	// Scopes 为客户端申请的本服务授权范围，抖音未返回用户同意的授权范围时以此为准
+	Scopes []string `json:"scopes,omitempty"`
 }
 
 func NewOAuthCallbackFromJson(s string) (*OAuthCallback, error) {
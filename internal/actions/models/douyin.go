@@ -0,0 +1,92 @@
+package models
+
+// DouYinExtra 为抖音开放平台响应中的 extra 字段，部分接口的业务错误码位于此处
+type DouYinExtra struct {
+	ErrorCode      int64  `json:"error_code"`
+	Description    string `json:"description"`
+	SubErrorCode   int64  `json:"sub_error_code"`
+	SubDescription string `json:"sub_description"`
+	LogID          string `json:"logid"`
+	Now            int64  `json:"now"`
+}
+
+// DouYinError 为抖音开放平台响应中 data 字段内的业务错误码
+type DouYinError struct {
+	ErrorCode   int64  `json:"error_code"`
+	Description string `json:"description"`
+}
+
+// DouYinGetTokenResponse 抖音定义的获取Token的响应格式
+type DouYinGetTokenResponse struct {
+	Data struct {
+		DouYinError
+		AccessToken      string `json:"access_token"`
+		RefreshToken     string `json:"refresh_token"`
+		ExpiresIn        int    `json:"expires_in"`
+		RefreshExpiresIn int    `json:"refresh_expires_in"`
+		OpenID           string `json:"open_id"`
+		Scope            string `json:"scope"`
+	} `json:"data"`
+	Message string `json:"message"`
+}
+
+// DouYinUserInfoResponse 抖音定义的获取用户信息的响应格式
+type DouYinUserInfoResponse struct {
+	Data struct {
+		DouYinError
+		Avatar   string `json:"avatar"`
+		Nickname string `json:"nickname"`
+		OpenID   string `json:"open_id"`
+		UnionID  string `json:"union_id"`
+	} `json:"data"`
+	Extra DouYinExtra `json:"extra"`
+}
+
+// DouYinVideoListResponse 抖音定义的查询视频列表的响应格式
+type DouYinVideoListResponse struct {
+	Data struct {
+		DouYinError
+		Cursor  int            `json:"cursor"`
+		HasMore bool           `json:"has_more"`
+		List    []*DouYinVideo `json:"list"`
+	} `json:"data"`
+	Extra DouYinExtra `json:"extra"`
+}
+
+// DouYinVideo 抖音定义的视频信息
+type DouYinVideo struct {
+	ItemID     string `json:"item_id"`
+	Title      string `json:"title"`
+	CreateTime int64  `json:"create_time"`
+	IsTop      bool   `json:"is_top"`
+	Statistics struct {
+		DiggCount     int64 `json:"digg_count"`
+		PlayCount     int64 `json:"play_count"`
+		ShareCount    int64 `json:"share_count"`
+		CommentCount  int64 `json:"comment_count"`
+		ForwardCount  int64 `json:"forward_count"`
+		DownloadCount int64 `json:"download_count"`
+	} `json:"statistics"`
+}
+
+// DouYinFansDataResponse 抖音定义的获取粉丝画像的响应格式
+type DouYinFansDataResponse struct {
+	Data struct {
+		DouYinError
+		FansData struct {
+			AllFansNum              int64                 `json:"all_fans_num"`
+			GenderDistributions     []*DouYinDistribution `json:"gender_distributions"`
+			AgeDistributions        []*DouYinDistribution `json:"age_distributions"`
+			DeviceDistributions     []*DouYinDistribution `json:"device_distributions"`
+			ActiveDaysDistributions []*DouYinDistribution `json:"active_days_distributions"`
+			InterestDistributions   []*DouYinDistribution `json:"interest_distributions"`
+		} `json:"fans_data"`
+	} `json:"data"`
+	Extra DouYinExtra `json:"extra"`
+}
+
+// DouYinDistribution 抖音定义的粉丝分布项
+type DouYinDistribution struct {
+	Item  string `json:"item"`
+	Value int64  `json:"value"`
+}
@@ -7,6 +7,7 @@ type GetTokenRequest struct {
 	ClientSecret string `json:"client_secret"`
 	Code         string `json:"code"`
 	GrantType    string `json:"grant_type"`
+	CodeVerifier string `json:"code_verifier,omitempty"`
 }
 
 // GetTokenResponse 定义了符合OAuth标准的获取Token的响应格式
@@ -16,12 +17,14 @@ type GetTokenResponse struct {
 	RefreshToken string `json:"refresh_token"`
 	ExpireIn     int    `json:"expires_in"`
 	OpenID       string `json:"open_id"`
+	Scope        string `json:"scope,omitempty"`
 }
 
 // ServiceError 定义了本服务的错误响应格式
 type ServiceError struct {
-	ErrorCode        float64 `json:"error_code"`
-	ErrorDescription string  `json:"error_description"`
+	Error            string  `json:"error,omitempty" description:"错误类型，如 invalid_token、insufficient_scope、upstream_error、rate_limited"`
+	ErrorCode        float64 `json:"error_code" description:"抖音开放平台返回的错误码，非抖音错误时为 0"`
+	ErrorDescription string  `json:"error_description" description:"可以转述给用户的错误说明"`
 }
 
 // DouYinGetTokenRequest 抖音定义的获取Token的请求格式
@@ -31,3 +34,31 @@ type DouYinGetTokenRequest struct {
 	Code         string `json:"code"`
 	GrantType    string `json:"grant_type"`
 }
+
+// IntrospectResponse 定义了符合OAuth标准的Token内省响应格式
+// OAuth标准定义详见: https://datatracker.ietf.org/doc/html/rfc7662#section-2.2
+type IntrospectResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	OpenID    string `json:"open_id,omitempty"`
+}
+
+// AuthorizationServerMetadata 定义了OAuth授权服务器元数据格式
+// OAuth标准定义详见: https://datatracker.ietf.org/doc/html/rfc8414#section-2
+type AuthorizationServerMetadata struct {
+	Issuer                                    string   `json:"issuer"`
+	AuthorizationEndpoint                     string   `json:"authorization_endpoint,omitempty"`
+	TokenEndpoint                             string   `json:"token_endpoint,omitempty"`
+	IntrospectionEndpoint                     string   `json:"introspection_endpoint,omitempty"`
+	ScopesSupported                           []string `json:"scopes_supported"`
+	ResponseTypesSupported                    []string `json:"response_types_supported"`
+	GrantTypesSupported                       []string `json:"grant_types_supported"`
+	TokenEndpointAuthMethodsSupported         []string `json:"token_endpoint_auth_methods_supported"`
+	IntrospectionEndpointAuthMethodsSupported []string `json:"introspection_endpoint_auth_methods_supported,omitempty"`
+	CodeChallengeMethodsSupported             []string `json:"code_challenge_methods_supported"`
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 本服务对外的授权范围
+const (
+	ScopeUserInfo     = "user.info"
+	ScopeVideoList    = "video.list"
+	ScopeFansData     = "fans.data"
+	ScopeVideoComment = "video.comment"
+	ScopeVideoPublish = "video.publish"
+)
+
+// Scope 描述了本服务对外的授权范围及其对应的抖音授权范围
+type Scope struct {
+	Name        string
+	DouYinScope string
+	Description string
+	// Default 为 true 时，客户端未指定 scope 的授权请求默认申请该范围
+	Default bool
+}
+
+// ScopeCatalog 为本服务支持的全部授权范围
+var ScopeCatalog = []*Scope{
+	{Name: ScopeUserInfo, DouYinScope: "user_info", Description: "获取用户公开信息", Default: true},
+	{Name: ScopeVideoList, DouYinScope: "video.list.bytoken", Description: "查询授权账号视频数据", Default: true},
+	{Name: ScopeFansData, DouYinScope: "fans.data.bytoken", Description: "获取用户粉丝数据", Default: true},
+	{Name: ScopeVideoComment, DouYinScope: "item.comment", Description: "管理视频评论"},
+	{Name: ScopeVideoPublish, DouYinScope: "video.create", Description: "发布视频"},
+}
+
+// LookupScope 按本服务的授权范围名查找，兼容直接使用抖音授权范围名的客户端
+func LookupScope(name string) (*Scope, bool) {
+	for _, scope := range ScopeCatalog {
+		if scope.Name == name || scope.DouYinScope == name {
+			return scope, true
+		}
+	}
+	return nil, false
+}
+
+// ScopeNames 返回全部授权范围名
+func ScopeNames() []string {
+	names := make([]string, 0, len(ScopeCatalog))
+	for _, scope := range ScopeCatalog {
+		names = append(names, scope.Name)
+	}
+	return names
+}
+
+// ParseScopes 解析以空格、逗号或竖线分隔的授权范围
+func ParseScopes(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ' ' || r == ',' || r == '|'
+	})
+}
+
+// ToDouYinScopes 将请求的授权范围转换为抖音授权范围，未指定时使用默认授权范围
+func ToDouYinScopes(names []string) ([]string, error) {
+	if len(names) == 0 {
+		for _, scope := range ScopeCatalog {
+			if scope.Default {
+				names = append(names, scope.Name)
+			}
+		}
+	}
+	douYinScopes := make([]string, 0, len(names))
+	for _, name := range names {
+		scope, ok := LookupScope(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown scope %q", name)
+		}
+		douYinScopes = appendUnique(douYinScopes, scope.DouYinScope)
+	}
+	return douYinScopes, nil
+}
+
+// FromDouYinScopes 将抖音返回的授权范围转换为本服务的授权范围，忽略未知的范围
+func FromDouYinScopes(douYinScopes []string) []string {
+	names := make([]string, 0, len(douYinScopes))
+	for _, douYinScope := range douYinScopes {
+		if scope, ok := LookupScope(douYinScope); ok {
+			names = appendUnique(names, scope.Name)
+		}
+	}
+	return names
+}
+
+func appendUnique(list []string, value string) []string {
+	for _, v := range list {
+		if v == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
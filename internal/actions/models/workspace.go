@@ -0,0 +1,49 @@
+package models
+
+type GrantItem struct {
+	Account   string `json:"account" description:"被授予的抖音账号的 openId"`
+	Nickname  string `json:"nickname" description:"被授予的抖音账号的昵称，查询过用户信息后才有值"`
+	Owner     string `json:"owner" description:"抖音账号所有者的钉钉用户标识"`
+	User      string `json:"user" description:"被授予角色的钉钉用户标识"`
+	Role      string `json:"role" description:"角色：read 只读，write 读写" enum:"read,write"`
+	GrantedAt string `json:"grantedAt" description:"授予时间，RFC 3339 格式"`
+}
+
+type GetGrantsResponse struct {
+	Granted  []*GrantItem `json:"granted" description:"当前用户授予他人的角色"`
+	Received []*GrantItem `json:"received" description:"他人授予当前用户的角色"`
+}
+
+type GrantRequest struct {
+	Account string `json:"account" binding:"required" description:"要共享的抖音账号，取值为 /accounts 返回的 openId 或昵称，只能共享自己关联的账号"`
+	User    string `json:"user" binding:"required" description:"被授予角色的钉钉用户标识"`
+	Role    string `json:"role" binding:"required" description:"角色：read 只读，write 读写" enum:"read,write"`
+}
+
+type RevokeRequest struct {
+	Account string `json:"account" binding:"required" description:"已共享的抖音账号，取值为 openId 或昵称"`
+	User    string `json:"user" binding:"required" description:"要收回角色的钉钉用户标识"`
+}
+
+type RevokeResponse struct {
+	Revoked bool `json:"revoked" description:"是否已收回"`
+}
+
+type GetAccessLogQuery struct {
+	Account string `form:"account" description:"只查看该抖音账号的访问记录，取值为 openId 或昵称，默认查看自己关联的全部账号"`
+	Limit   int    `form:"limit" description:"返回的记录数，默认 20，最大 100"`
+}
+
+type GetAccessLogResponse struct {
+	Records []*AccessItem `json:"records" description:"访问记录，最近的在前"`
+}
+
+type AccessItem struct {
+	Time    string `json:"time" description:"访问时间，RFC 3339 格式"`
+	User    string `json:"user" description:"访问者的钉钉用户标识"`
+	Account string `json:"account" description:"被访问的抖音账号的 openId"`
+	Action  string `json:"action" description:"调用的业务动作"`
+	Role    string `json:"role,omitempty" description:"访问时使用的角色，所有者访问时为 owner，无权访问时为空" enum:"owner,read,write"`
+	Allowed bool   `json:"allowed" description:"是否被允许"`
+	Reason  string `json:"reason" description:"被拒绝的原因"`
+}
@@ -1,46 +1,187 @@
 package actions
 
 import (
+	"context"
+	"crypto/tls"
 	"douyin-action-example/internal/actions/controllers"
+	"douyin-action-example/internal/actions/storage"
+	"douyin-action-example/internal/audit"
+	"douyin-action-example/internal/conf"
+	"douyin-action-example/internal/logging"
+	"douyin-action-example/internal/metrics"
+	"douyin-action-example/internal/tracing"
 	"github.com/chzealot/gobase/logger"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
 	"net"
 	"net/http"
+	"sync"
+	"time"
 )
 
+// Worker 为随 HttpServer 启停的后台任务，Run 需在 ctx 取消后尽快返回
+type Worker interface {
+	Name() string
+	Run(ctx context.Context)
+}
+
 type HttpServer struct {
+	workers []Worker
+	// listening 非空时在开始监听后以实际监听的地址调用，便于测试监听 :0 的服务
+	listening func(addr net.Addr)
+}
+
+func init() {
+	metrics.RegisterTokenStoreSize(func() float64 {
+		size := 0
+		for _, d := range storage.Stores() {
+			size += d.Len()
+		}
+		return float64(size)
+	})
 }
 
 func NewHttpServer() *HttpServer {
-	return &HttpServer{}
+	return &HttpServer{
+		workers: []Worker{
+			storage.NewTokenJanitor(time.Duration(conf.App.Storage.JanitorInterval)),
+			controllers.NewTokenRefresher(time.Duration(conf.App.Storage.JanitorInterval)),
+		},
+	}
 }
 
-func (s *HttpServer) Run(address string) error {
-	logger.Infof("run http server on %s", address)
+func (s *HttpServer) handler() http.Handler {
 	gin.SetMode(gin.ReleaseMode)
-	r := gin.Default()
+	r := gin.New()
+	r.Use(tracing.Middleware(conf.App.Tracing.ServiceName), logging.Middleware(), controllers.Recovery())
+	if conf.App.Metrics.Enabled {
+		r.Use(metrics.Middleware())
+		r.GET(conf.App.Metrics.Path, metrics.Handler())
+	}
+
+	// 探针及构建信息不经过授权，供编排系统直接访问
+	hc := controllers.NewHealthController()
+	r.GET("/healthz", hc.Healthz)
+	r.GET("/readyz", hc.Readyz)
+	r.GET("/version", hc.Version)
 
 	asset := controllers.NewAssetHandler()
 	r.GET("/openapi.yaml", asset.OpenApiSpecYaml)
+	r.GET("/apps/:app/openapi.yaml", asset.AppOpenApiSpecYaml)
 
+	router := controllers.NewRouter(r)
 	ac := controllers.NewAuthController()
-	r.GET("/auth/authorize", ac.Authorize)
-	r.POST("/auth/token", ac.Token)
-	r.GET("/auth/callback", ac.Callback)
+	router.Handle(http.MethodGet, "/auth/authorize", controllers.EndpointAuthorize, ac.Authorize)
+	router.Handle(http.MethodPost, "/auth/token", controllers.EndpointToken, ac.Token)
+	router.Handle(http.MethodGet, "/auth/callback", "", ac.Callback)
+	router.Handle(http.MethodPost, "/auth/introspect", controllers.EndpointIntrospect, ac.Introspect)
+
+	mc := controllers.NewMetadataController(router)
+	r.GET("/.well-known/oauth-authorization-server", mc.AuthorizationServer)
+
+	// 管理接口使用独立的凭证，未配置 admin.token 时不提供
+	if conf.App.Admin.Token != "" {
+		adc := controllers.NewAdminController()
+		r.GET("/admin", adc.Console)
+		admin := r.Group("/admin/api", controllers.RequireAdmin())
+		admin.GET("/accounts", adc.Accounts)
+		admin.GET("/tokens", adc.Tokens)
+		admin.GET("/errors", adc.Errors)
+		admin.GET("/ratelimits", adc.RateLimits)
+	}
 
 	bc := controllers.NewBizController()
-	r.GET("/userInfo", bc.UserInfo)
-	r.GET("/videoList", bc.GetVideoList)
-	r.GET("/fansData", bc.GetFansData)
+	for _, action := range bc.Actions() {
+		router.HandleAction(action)
+	}
+	return r
+}
 
-	server := &http.Server{Addr: address, Handler: r}
-	ln, err := net.Listen("tcp4", address)
+// Run 启动 HTTP 服务及后台任务并阻塞，ctx 取消后停止接受新连接，在配置的时限内等待处理中的请求完成，
+// 随后停止后台任务并关闭 Token 存储
+func (s *HttpServer) Run(ctx context.Context, address string) error {
+	serverConfig := conf.App.Server
+	server := &http.Server{
+		Addr:              address,
+		Handler:           s.handler(),
+		ReadHeaderTimeout: time.Duration(serverConfig.ReadHeaderTimeout),
+		ReadTimeout:       time.Duration(serverConfig.ReadTimeout),
+		WriteTimeout:      time.Duration(serverConfig.WriteTimeout),
+		IdleTimeout:       time.Duration(serverConfig.IdleTimeout),
+	}
+	workers := s.workers
+	if serverConfig.TLS.Enabled() {
+		reloader, err := newCertReloader(serverConfig.TLS.CertFile, serverConfig.TLS.KeyFile,
+			time.Duration(serverConfig.TLS.ReloadInterval))
+		if err != nil {
+			return err
+		}
+		server.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: reloader.GetCertificate,
+		}
+		workers = append(workers, reloader)
+	}
+
+	// ListenConfig.KeepAlive 作用于每个已接受的连接，为负数时关闭 TCP keep-alive
+	lc := net.ListenConfig{KeepAlive: time.Duration(serverConfig.KeepAlive)}
+	ln, err := lc.Listen(ctx, serverConfig.Network, address)
 	if err != nil {
-		return err
+		return errors.WithStack(err)
+	}
+	logger.Infof("run http server on %s/%s, tls=%t", serverConfig.Network, ln.Addr(), serverConfig.TLS.Enabled())
+	if s.listening != nil {
+		s.listening(ln.Addr())
+	}
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for _, worker := range workers {
+		wg.Add(1)
+		go func(worker Worker) {
+			defer wg.Done()
+			logger.Infof("start worker %s", worker.Name())
+			worker.Run(workerCtx)
+			logger.Infof("worker %s stopped", worker.Name())
+		}(worker)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if server.TLSConfig != nil {
+			serveErr <- server.ServeTLS(ln, "", "")
+		} else {
+			serveErr <- server.Serve(ln)
+		}
+	}()
+
+	select {
+	case err = <-serveErr:
+		err = errors.WithStack(err)
+	case <-ctx.Done():
+		timeout := time.Duration(conf.App.ShutdownTimeout)
+		logger.Infof("shutting down http server, waiting up to %s for in-flight requests", timeout)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+		err = errors.WithStack(server.Shutdown(shutdownCtx))
+		cancel()
+	}
+
+	stopWorkers()
+	wg.Wait()
+	for namespace, d := range storage.Stores() {
+		if closeErr := d.Close(); closeErr != nil {
+			logger.Errorf("close token store failed, namespace=%q, err=%+v", namespace, closeErr)
+			if err == nil {
+				err = closeErr
+			}
+		}
 	}
-	type tcpKeepAliveListener struct {
-		*net.TCPListener
+	if closeErr := audit.Close(); closeErr != nil {
+		logger.Errorf("close audit sink failed, err=%+v", closeErr)
+		if err == nil {
+			err = closeErr
+		}
 	}
-	return errors.WithStack(server.Serve(tcpKeepAliveListener{ln.(*net.TCPListener)}))
+	_ = logger.DefaultLogger.Sync()
+	return err
 }
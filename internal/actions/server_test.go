@@ -0,0 +1,151 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"douyin-action-example/internal/actions/storage"
+	"douyin-action-example/internal/apps"
+	"douyin-action-example/internal/conf"
+	"encoding/json"
+	"github.com/chzealot/gobase/logger"
+	"go.uber.org/zap"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	logger.DefaultLogger = zap.NewNop()
+	logger.DefaultSugarLogger = logger.DefaultLogger.Sugar()
+	os.Exit(m.Run())
+}
+
+// stopRecorder 记录后台任务是否在 ctx 取消后退出
+type stopRecorder struct {
+	stopped chan struct{}
+}
+
+func (w *stopRecorder) Name() string {
+	return "stop-recorder"
+}
+
+func (w *stopRecorder) Run(ctx context.Context) {
+	<-ctx.Done()
+	close(w.stopped)
+}
+
+func TestRunDrainsInFlightRequests(t *testing.T) {
+	// 模拟的抖音获取 Token 接口在收到请求后阻塞，直到测试放行
+	received, release := make(chan struct{}), make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(received)
+		<-release
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"access_token": "access", "open_id": "open-1", "expires_in": 3600, "scope": "user_info"},
+		})
+	}))
+	defer upstream.Close()
+
+	config := conf.Default()
+	config.Listen = "127.0.0.1:0"
+	config.DouYin.OpenApiBaseUrl = upstream.URL
+	config.Metrics.Enabled = false
+	conf.Use(config)
+	defer conf.Use(conf.Default())
+	if err := apps.Init(config); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Init(config.Storage, apps.Namespaces()); err != nil {
+		t.Fatal(err)
+	}
+	storage.GrantService.Save("code-1", &storage.AuthorizationGrant{App: apps.DefaultID})
+
+	worker := &stopRecorder{stopped: make(chan struct{})}
+	addrs := make(chan net.Addr, 1)
+	server := &HttpServer{workers: []Worker{worker}, listening: func(addr net.Addr) { addrs <- addr }}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- server.Run(ctx, config.Listen) }()
+
+	var addr net.Addr
+	select {
+	case addr = <-addrs:
+	case err := <-done:
+		t.Fatalf("Run returned before listening: %v", err)
+	}
+	baseUrl := "http://" + addr.String()
+
+	status := make(chan int, 1)
+	go func() {
+		body, _ := json.Marshal(map[string]string{"client_id": "client", "client_secret": "secret", "code": "code-1", "grant_type": "authorization_code"})
+		response, err := http.Post(baseUrl+"/auth/token", "application/json", bytes.NewReader(body))
+		if err != nil {
+			status <- 0
+			return
+		}
+		response.Body.Close()
+		status <- response.StatusCode
+	}()
+	<-received
+
+	cancel()
+	select {
+	case err := <-done:
+		t.Fatalf("Run returned with a request in flight: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	// 停止接受新连接
+	if _, err := net.DialTimeout("tcp", addr.String(), time.Second); err == nil {
+		t.Fatal("server still accepts connections after shutdown started")
+	}
+
+	close(release)
+	if code := <-status; code != http.StatusOK {
+		t.Fatalf("in-flight request finished with status %d, want 200", code)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after in-flight requests drained")
+	}
+	select {
+	case <-worker.stopped:
+	default:
+		t.Fatal("worker was not stopped")
+	}
+}
+
+func TestAdminRoutesRequireAdminToken(t *testing.T) {
+	defer conf.Use(conf.Default())
+	for _, tc := range []struct {
+		token           string
+		console, tokens int
+	}{
+		// 未配置 admin.token 时不提供管理接口及控制台
+		{"", http.StatusNotFound, http.StatusNotFound},
+		{"admin-token-0123456789", http.StatusOK, http.StatusUnauthorized},
+	} {
+		config := conf.Default()
+		config.Metrics.Enabled = false
+		config.Admin.Token = tc.token
+		conf.Use(config)
+		if err := apps.Init(config); err != nil {
+			t.Fatal(err)
+		}
+		handler := (&HttpServer{}).handler()
+		for path, want := range map[string]int{"/admin": tc.console, "/admin/api/tokens": tc.tokens} {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+			if recorder.Code != want {
+				t.Errorf("admin.token %q: %s status = %d, want %d", tc.token, path, recorder.Code, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"douyin-action-example/internal/conf"
+	"douyin-action-example/internal/logfile"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// defaultMaxAccessRecords 为未经 Init 打开的命名空间在内存中保留的访问记录数
+const defaultMaxAccessRecords = 10000
+
+// AccessRecord 记录了钉钉用户对抖音账号的一次访问
+type AccessRecord struct {
+	Time   time.Time
+	User   string
+	OpenID string
+	Action string
+	// Role 为访问时使用的角色，账号所有者访问时为空
+	Role    Role
+	Allowed bool
+	Reason  string `json:",omitempty"`
+}
+
+// AccessLog 只追加地记录账号访问，与授予关系分开存储，每次访问不会重写授予关系文件；
+// 内存存储只保留最近的记录，文件存储按大小轮转
+type AccessLog struct {
+	mu sync.Mutex
+	// records 为内存存储的访问记录，写满后从 next 开始覆盖最早的记录，writer 非空时不使用
+	records    []*AccessRecord
+	next       int
+	maxRecords int
+	// writer 非空时以 JSON Lines 追加写入 path
+	path   string
+	writer *logfile.Writer
+}
+
+// NewAccessLog 创建在内存中保留最近 maxRecords 条记录的 AccessLog
+func NewAccessLog(maxRecords int) *AccessLog {
+	return &AccessLog{maxRecords: maxRecords}
+}
+
+// NewFileAccessLog 创建追加写入 path 的 AccessLog，文件在第一次写入时创建，超过配置的大小后轮转
+func NewFileAccessLog(path string, config conf.AccessLogConfig) *AccessLog {
+	return &AccessLog{path: path, writer: logfile.New(path, int64(config.MaxSizeMB)<<20, config.MaxBackups)}
+}
+
+// Record 追加一条访问记录
+func (l *AccessLog) Record(record *AccessRecord) error {
+	if l.writer != nil {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		return errors.Wrapf(l.writer.Write(line), "append %s", l.path)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	copied := *record
+	if len(l.records) < l.maxRecords {
+		l.records = append(l.records, &copied)
+		return nil
+	}
+	l.records[l.next] = &copied
+	l.next = (l.next + 1) % len(l.records)
+	return nil
+}
+
+// Records 返回满足 match 的最近 limit 条访问记录，最近的在前；文件存储从最新的文件开始读取，取够 limit 条即停止
+func (l *AccessLog) Records(match func(record *AccessRecord) bool, limit int) ([]*AccessRecord, error) {
+	if l.writer != nil {
+		return l.readFiles(match, limit)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var records []*AccessRecord
+	for i := len(l.records) - 1; i >= 0 && len(records) < limit; i-- {
+		record := l.records[(l.next+i)%len(l.records)]
+		if match(record) {
+			copied := *record
+			records = append(records, &copied)
+		}
+	}
+	return records, nil
+}
+
+// Close 关闭文件存储打开的文件
+func (l *AccessLog) Close() error {
+	if l.writer == nil {
+		return nil
+	}
+	return l.writer.Close()
+}
+
+func (l *AccessLog) readFiles(match func(record *AccessRecord) bool, limit int) ([]*AccessRecord, error) {
+	files, err := logfile.Rotated(l.path)
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, l.path)
+	var records []*AccessRecord
+	for i := len(files) - 1; i >= 0 && len(records) < limit; i-- {
+		lines, err := readLines(files[i])
+		if err != nil {
+			return nil, err
+		}
+		for j := len(lines) - 1; j >= 0 && len(records) < limit; j-- {
+			record := &AccessRecord{}
+			// 无法解析的行（如写入中断的最后一行）被跳过
+			if err := json.Unmarshal(lines[j], record); err != nil {
+				continue
+			}
+			if match(record) {
+				records = append(records, record)
+			}
+		}
+	}
+	return records, nil
+}
+
+// readLines 读取文件的全部行，文件不存在时返回空
+func readLines(path string) ([][]byte, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer file.Close()
+	var lines [][]byte
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, bytes.Clone(scanner.Bytes()))
+	}
+	return lines, errors.Wrapf(scanner.Err(), "read %s", path)
+}
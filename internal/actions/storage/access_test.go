@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"douyin-action-example/internal/conf"
+	"douyin-action-example/internal/logfile"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessLogMigratesWorkspaceRecords(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	legacy := &workspaceData{
+		Grants: []*AccountGrant{{OpenID: "open-a", User: "bob", Role: RoleRead, GrantedBy: "alice", GrantedAt: now}},
+		Access: []*AccessRecord{{Time: now, User: "bob", OpenID: "open-a", Action: "/video/list", Role: RoleRead, Allowed: true}},
+	}
+	if err := writeJSONFile(filepath.Join(dir, "workspace.json"), legacy); err != nil {
+		t.Fatal(err)
+	}
+	config := conf.Default().Storage
+	config.Backend, config.Path = "file", filepath.Join(dir, "tokens.json")
+	if err := Init(config, nil); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = Init(conf.Default().Storage, nil) })
+
+	if err := AccessLogs("").Record(&AccessRecord{Time: now, User: "carol", OpenID: "open-a", Reason: "account is not accessible"}); err != nil {
+		t.Fatal(err)
+	}
+	// 重新打开后记录仍然保留，且不再写入工作区文件
+	if err := Init(config, nil); err != nil {
+		t.Fatal(err)
+	}
+	records, err := AccessLogs("").Records(func(*AccessRecord) bool { return true }, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].User != "carol" || records[1].User != "bob" || !records[1].Allowed {
+		t.Errorf("unexpected access records %+v", records)
+	}
+	workspace := &workspaceData{}
+	if _, err := readJSONFile(filepath.Join(dir, "workspace.json"), workspace); err != nil {
+		t.Fatal(err)
+	}
+	if len(workspace.Access) != 0 || len(workspace.Grants) != 1 {
+		t.Errorf("workspace file should only keep grants: %+v", workspace)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "access.jsonl")); err != nil {
+		t.Errorf("access log file is missing: %v", err)
+	}
+}
+
+func TestAccessLogKeepsRecentRecordsInMemory(t *testing.T) {
+	log := NewAccessLog(3)
+	for i := 0; i < 5; i++ {
+		if err := log.Record(&AccessRecord{User: fmt.Sprint(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, err := log.Records(func(*AccessRecord) bool { return true }, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := accessUsers(records); got != "4,3,2" {
+		t.Errorf("records = %s, want the 3 most recent", got)
+	}
+	records, _ = log.Records(func(r *AccessRecord) bool { return r.User != "3" }, 1)
+	if got := accessUsers(records); got != "4" {
+		t.Errorf("records = %s, want the most recent match", got)
+	}
+}
+
+func TestFileAccessLogRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.jsonl")
+	log := NewFileAccessLog(path, conf.AccessLogConfig{MaxSizeMB: 1, MaxBackups: 2})
+	t.Cleanup(func() { _ = log.Close() })
+	// 每条记录约 1KB，写入约 4MB 后应轮转多次并只保留 2 个轮转文件
+	reason := strings.Repeat("x", 1000)
+	for i := 0; i < 4000; i++ {
+		if err := log.Record(&AccessRecord{User: fmt.Sprint(i), Reason: reason}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rotated, err := logfile.Rotated(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotated) != 2 {
+		t.Errorf("rotated files = %v, want 2", rotated)
+	}
+	records, err := log.Records(func(*AccessRecord) bool { return true }, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := accessUsers(records); got != "3999,3998,3997" {
+		t.Errorf("records = %s, want the 3 most recent", got)
+	}
+	// 跨文件读取时仍按时间倒序
+	records, err = log.Records(func(*AccessRecord) bool { return true }, 2000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2000 || records[1999].User != "2000" {
+		t.Errorf("expect 2000 records ending with user 2000, got %d", len(records))
+	}
+}
+
+func accessUsers(records []*AccessRecord) string {
+	users := make([]string, len(records))
+	for i, record := range records {
+		users[i] = record.User
+	}
+	return strings.Join(users, ",")
+}
@@ -0,0 +1,258 @@
+package storage
+
+import (
+	"github.com/chzealot/gobase/logger"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+// LinkedAccount 为钉钉用户关联的一个抖音账号
+type LinkedAccount struct {
+	OpenID string
+	// Nickname 为最近一次查询到的抖音昵称，可用于按昵称选择账号
+	Nickname string
+	// Primary 为钉钉用户的主账号，业务动作未指定账号时使用，第一个关联的账号为主账号
+	Primary  bool
+	LinkedAt time.Time
+}
+
+var ErrAccountNotLinked = errors.New("douyin account is not linked")
+
+// AccountLinkDict 记录了钉钉用户与抖音账号的关联，一个抖音账号只属于一个钉钉用户
+type AccountLinkDict struct {
+	// users 为钉钉用户标识到已关联账号的映射，按关联时间排序
+	users map[string][]*LinkedAccount
+	mu    sync.Mutex
+	// fileState 的 path 非空时每次变更都会持久化到该文件，文件被其他进程（如管理命令）修改后重新加载
+	fileState
+}
+
+func NewAccountLinkDict() *AccountLinkDict {
+	return &AccountLinkDict{
+		users: make(map[string][]*LinkedAccount),
+	}
+}
+
+// NewFileAccountLinkDict 创建持久化到 path 的 AccountLinkDict，文件存在时加载其中的关联
+func NewFileAccountLinkDict(path string) (*AccountLinkDict, error) {
+	d := NewAccountLinkDict()
+	d.path = path
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// load 从文件加载全部关联，调用方需持有锁
+func (d *AccountLinkDict) load() error {
+	var users map[string][]*LinkedAccount
+	if ok, err := readJSONFile(d.path, &users); err != nil {
+		return errors.Wrap(err, "load account link file")
+	} else if !ok {
+		return nil
+	}
+	if users == nil {
+		users = make(map[string][]*LinkedAccount)
+	}
+	d.users = users
+	d.synced()
+	return nil
+}
+
+// reload 在文件被其他进程（如管理命令）修改后重新加载，加载失败时继续使用内存中的数据，调用方需持有锁
+func (d *AccountLinkDict) reload() {
+	changed, err := d.changed()
+	if err == nil && changed {
+		err = d.load()
+	}
+	if err != nil {
+		logger.Errorf("reload account link file %s failed: %+v", d.path, err)
+	}
+}
+
+// update 以 mutate 修改关联并写入文件，mutate 返回 false 时不写入，调用方需持有锁；
+// file 存储在文件锁内重新加载文件后修改副本，写入成功后才替换内存中的数据
+func (d *AccountLinkDict) update(mutate func(users map[string][]*LinkedAccount) (bool, error)) error {
+	if d.path == "" {
+		_, err := mutate(d.users)
+		return err
+	}
+	return withFileLock(d.path, func() error {
+		if err := d.load(); err != nil {
+			return err
+		}
+		users := make(map[string][]*LinkedAccount, len(d.users))
+		for user, accounts := range d.users {
+			for _, account := range accounts {
+				copied := *account
+				users[user] = append(users[user], &copied)
+			}
+		}
+		changed, err := mutate(users)
+		if err != nil || !changed {
+			return err
+		}
+		if err := writeJSONFile(d.path, users); err != nil {
+			return errors.Wrap(err, "persist account links")
+		}
+		d.users = users
+		d.synced()
+		return nil
+	})
+}
+
+// Link 将抖音账号关联到钉钉用户，账号已关联到其他钉钉用户时改为关联到 user
+func (d *AccountLinkDict) Link(user, openId string, now time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.update(func(users map[string][]*LinkedAccount) (bool, error) {
+		if owner, _, ok := findAccount(users, openId); ok {
+			if owner == user {
+				return false, nil
+			}
+			removeAccount(users, owner, openId)
+		}
+		users[user] = append(users[user], &LinkedAccount{
+			OpenID:   openId,
+			Primary:  len(users[user]) == 0,
+			LinkedAt: now,
+		})
+		return true, nil
+	})
+}
+
+// Unlink 解除抖音账号的关联，解除主账号时由最早关联的账号成为主账号
+func (d *AccountLinkDict) Unlink(openId string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.update(func(users map[string][]*LinkedAccount) (bool, error) {
+		owner, _, ok := findAccount(users, openId)
+		if !ok {
+			return false, ErrAccountNotLinked
+		}
+		removeAccount(users, owner, openId)
+		return true, nil
+	})
+}
+
+// Owner 返回抖音账号关联的钉钉用户
+func (d *AccountLinkDict) Owner(openId string) (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.reload()
+	owner, _, ok := findAccount(d.users, openId)
+	return owner, ok
+}
+
+// Find 返回抖音账号及其关联的钉钉用户
+func (d *AccountLinkDict) Find(openId string) (*LinkedAccount, string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.reload()
+	owner, account, ok := findAccount(d.users, openId)
+	if !ok {
+		return nil, "", false
+	}
+	copied := *account
+	return &copied, owner, true
+}
+
+// Accounts 返回钉钉用户关联的抖音账号，主账号在前
+func (d *AccountLinkDict) Accounts(user string) []*LinkedAccount {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.reload()
+	accounts := make([]*LinkedAccount, 0, len(d.users[user]))
+	for _, account := range d.users[user] {
+		copied := *account
+		if copied.Primary {
+			accounts = append([]*LinkedAccount{&copied}, accounts...)
+		} else {
+			accounts = append(accounts, &copied)
+		}
+	}
+	return accounts
+}
+
+// Users 返回全部钉钉用户的关联账号
+func (d *AccountLinkDict) Users() map[string][]*LinkedAccount {
+	d.mu.Lock()
+	d.reload()
+	users := make([]string, 0, len(d.users))
+	for user := range d.users {
+		users = append(users, user)
+	}
+	d.mu.Unlock()
+	copied := make(map[string][]*LinkedAccount, len(users))
+	for _, user := range users {
+		copied[user] = d.Accounts(user)
+	}
+	return copied
+}
+
+// SetNickname 记录抖音账号的昵称，昵称未变化时不写入
+func (d *AccountLinkDict) SetNickname(openId, nickname string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.update(func(users map[string][]*LinkedAccount) (bool, error) {
+		_, account, ok := findAccount(users, openId)
+		if !ok {
+			return false, ErrAccountNotLinked
+		}
+		if account.Nickname == nickname {
+			return false, nil
+		}
+		account.Nickname = nickname
+		return true, nil
+	})
+}
+
+// SetPrimary 将抖音账号设为其钉钉用户的主账号
+func (d *AccountLinkDict) SetPrimary(openId string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.update(func(users map[string][]*LinkedAccount) (bool, error) {
+		owner, _, ok := findAccount(users, openId)
+		if !ok {
+			return false, ErrAccountNotLinked
+		}
+		for _, account := range users[owner] {
+			account.Primary = account.OpenID == openId
+		}
+		return true, nil
+	})
+}
+
+// findAccount 查找抖音账号及其关联的钉钉用户
+func findAccount(users map[string][]*LinkedAccount, openId string) (string, *LinkedAccount, bool) {
+	for user, accounts := range users {
+		for _, account := range accounts {
+			if account.OpenID == openId {
+				return user, account, true
+			}
+		}
+	}
+	return "", nil, false
+}
+
+// removeAccount 从钉钉用户的关联中删除抖音账号
+func removeAccount(users map[string][]*LinkedAccount, user, openId string) {
+	var kept []*LinkedAccount
+	primaryRemoved := false
+	for _, account := range users[user] {
+		if account.OpenID == openId {
+			primaryRemoved = account.Primary
+			continue
+		}
+		kept = append(kept, account)
+	}
+	if len(kept) == 0 {
+		delete(users, user)
+		return
+	}
+	if primaryRemoved {
+		kept[0].Primary = true
+	}
+	users[user] = kept
+}
@@ -0,0 +1,222 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileAccountLinksConcurrentWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accounts.json")
+	writers := make([]*AccountLinkDict, 2)
+	for i := range writers {
+		d, err := NewFileAccountLinkDict(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writers[i] = d
+	}
+
+	const perWriter = 20
+	var wg sync.WaitGroup
+	for i, d := range writers {
+		wg.Add(1)
+		go func(i int, d *AccountLinkDict) {
+			defer wg.Done()
+			for j := 0; j < perWriter; j++ {
+				if err := d.Link(fmt.Sprintf("user-%d", i), fmt.Sprintf("open-id.%d.%d", i, j), time.Now()); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i, d)
+	}
+	wg.Wait()
+
+	loaded, err := NewFileAccountLinkDict(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range writers {
+		accounts := loaded.Accounts(fmt.Sprintf("user-%d", i))
+		if len(accounts) != perWriter {
+			t.Errorf("user-%d has %d accounts, want %d", i, len(accounts), perWriter)
+		}
+	}
+}
+
+func TestFileWorkspaceConcurrentWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "workspace.json")
+	writers := make([]*WorkspaceDict, 2)
+	for i := range writers {
+		d, err := NewFileWorkspaceDict(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writers[i] = d
+	}
+
+	const perWriter = 20
+	var wg sync.WaitGroup
+	for i, d := range writers {
+		wg.Add(1)
+		go func(i int, d *WorkspaceDict) {
+			defer wg.Done()
+			for j := 0; j < perWriter; j++ {
+				grant := &AccountGrant{OpenID: fmt.Sprintf("open-id.%d", i), User: fmt.Sprintf("user-%d", j), Role: RoleRead}
+				if err := d.Grant(grant); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i, d)
+	}
+	wg.Wait()
+
+	loaded, err := NewFileWorkspaceDict(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	grants := loaded.Grants(func(*AccountGrant) bool { return true })
+	if len(grants) != len(writers)*perWriter {
+		t.Errorf("file has %d grants, want %d", len(grants), len(writers)*perWriter)
+	}
+}
+
+func TestFileAccountLinksReloadChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accounts.json")
+	service, err := NewFileAccountLinkDict(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := service.Find("open-id"); ok {
+		t.Fatal("empty file has an account")
+	}
+	// 管理命令打开同一文件并修改关联
+	cli, err := NewFileAccountLinkDict(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.Link("alice", "open-id", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if owner, ok := service.Owner("open-id"); !ok || owner != "alice" {
+		t.Fatalf("service does not see the link: owner=%q ok=%v", owner, ok)
+	}
+	if err := cli.SetNickname("open-id", "nick"); err != nil {
+		t.Fatal(err)
+	}
+	if accounts := service.Accounts("alice"); len(accounts) != 1 || accounts[0].Nickname != "nick" {
+		t.Fatalf("service does not see the nickname: %+v", accounts)
+	}
+	if err := cli.Unlink("open-id"); err != nil {
+		t.Fatal(err)
+	}
+	if users := service.Users(); len(users) != 0 {
+		t.Fatalf("service still sees unlinked accounts: %+v", users)
+	}
+}
+
+func TestFileWorkspaceReloadChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "workspace.json")
+	service, err := NewFileWorkspaceDict(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := func(*AccountGrant) bool { return true }
+	if grants := service.Grants(all); len(grants) != 0 {
+		t.Fatalf("empty file has grants: %+v", grants)
+	}
+	cli, err := NewFileWorkspaceDict(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.Grant(&AccountGrant{OpenID: "open-id", User: "bob", Role: RoleRead}); err != nil {
+		t.Fatal(err)
+	}
+	if grants := service.Grants(all); len(grants) != 1 || grants[0].User != "bob" || grants[0].Role != RoleRead {
+		t.Fatalf("service does not see the grant: %+v", grants)
+	}
+	if err := cli.Grant(&AccountGrant{OpenID: "open-id", User: "bob", Role: RoleWrite}); err != nil {
+		t.Fatal(err)
+	}
+	if grants := service.Grants(all); len(grants) != 1 || grants[0].Role != RoleWrite {
+		t.Fatalf("service does not see the updated role: %+v", grants)
+	}
+	if err := cli.Revoke("open-id", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if grants := service.Grants(all); len(grants) != 0 {
+		t.Fatalf("service still sees revoked grants: %+v", grants)
+	}
+}
+
+// primaryOf 返回钉钉用户关联的抖音账号，主账号以 * 标记
+func primaryOf(d *AccountLinkDict, user string) []string {
+	var accounts []string
+	for _, account := range d.Accounts(user) {
+		if account.Primary {
+			accounts = append(accounts, "*"+account.OpenID)
+		} else {
+			accounts = append(accounts, account.OpenID)
+		}
+	}
+	return accounts
+}
+
+func TestAccountLinkPrimary(t *testing.T) {
+	d := NewAccountLinkDict()
+	now := time.Now()
+	for _, openId := range []string{"open-a", "open-b", "open-c"} {
+		if err := d.Link("alice", openId, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 第一个关联的账号为主账号，重复关联不改变关联
+	if err := d.Link("alice", "open-b", now); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(primaryOf(d, "alice")); got != "[*open-a open-b open-c]" {
+		t.Fatalf("accounts of alice = %s", got)
+	}
+
+	if err := d.SetPrimary("open-c"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(primaryOf(d, "alice")); got != "[*open-c open-a open-b]" {
+		t.Fatalf("accounts after SetPrimary = %s", got)
+	}
+	// 解除主账号时由最早关联的账号成为主账号
+	if err := d.Unlink("open-c"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(primaryOf(d, "alice")); got != "[*open-a open-b]" {
+		t.Fatalf("accounts after Unlink = %s", got)
+	}
+
+	// 关联到其他钉钉用户时从原用户移除，并成为新用户的主账号
+	if err := d.Link("bob", "open-a", now); err != nil {
+		t.Fatal(err)
+	}
+	if owner, ok := d.Owner("open-a"); !ok || owner != "bob" {
+		t.Fatalf("owner of open-a = %q, %v", owner, ok)
+	}
+	if got := fmt.Sprint(primaryOf(d, "alice")); got != "[*open-b]" {
+		t.Fatalf("accounts of alice after relinking = %s", got)
+	}
+	if got := fmt.Sprint(primaryOf(d, "bob")); got != "[*open-a]" {
+		t.Fatalf("accounts of bob = %s", got)
+	}
+
+	for name, err := range map[string]error{
+		"Unlink":      d.Unlink("open-unknown"),
+		"SetPrimary":  d.SetPrimary("open-unknown"),
+		"SetNickname": d.SetNickname("open-unknown", "nobody"),
+	} {
+		if err != ErrAccountNotLinked {
+			t.Errorf("%s of an unlinked account returns %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"douyin-action-example/internal/conf"
+	"github.com/pkg/errors"
+	"io"
+)
+
+// dataKeySize 为每个 Token 的数据密钥长度，使用 AES-256
+const dataKeySize = 32
+
+// Envelope 为信封加密的密文：Ciphertext 由数据密钥加密，DataKey 为主密钥 KeyID 加密后的数据密钥
+type Envelope struct {
+	KeyID      string
+	DataKey    []byte
+	Ciphertext []byte
+}
+
+type masterKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// Keyring 为加密 Token 的主密钥，第一个为加密新数据密钥的主密钥，其余仅用于解密；创建后不再变化，修改配置中的主密钥需重新 Init
+type Keyring struct {
+	keys []*masterKey
+}
+
+// NewKeyring 按配置创建 Keyring，未配置主密钥时返回 nil，表示不加密
+func NewKeyring(config conf.EncryptionConfig) (*Keyring, error) {
+	decoded, err := config.DecodedKeys()
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) == 0 {
+		return nil, nil
+	}
+	k := &Keyring{}
+	for i, key := range decoded {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		k.keys = append(k.keys, &masterKey{id: config.Keys[i].ID, aead: aead})
+	}
+	return k, nil
+}
+
+// PrimaryID 返回主密钥的 ID
+func (k *Keyring) PrimaryID() string {
+	return k.keys[0].id
+}
+
+func (k *Keyring) key(id string) (*masterKey, error) {
+	for _, key := range k.keys {
+		if key.id == id {
+			return key, nil
+		}
+	}
+	return nil, errors.Errorf("encryption key %q is not in storage.encryption.keys", id)
+}
+
+// Seal 以新的数据密钥加密 plaintext，并以主密钥加密数据密钥
+func (k *Keyring) Seal(plaintext []byte) (*Envelope, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := seal(aead, plaintext, nil)
+	if err != nil {
+		return nil, err
+	}
+	primary := k.keys[0]
+	wrapped, err := seal(primary.aead, dataKey, []byte(primary.id))
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{KeyID: primary.id, DataKey: wrapped, Ciphertext: ciphertext}, nil
+}
+
+// Open 以 KeyID 对应的主密钥解密数据密钥，再解密密文
+func (k *Keyring) Open(e *Envelope) ([]byte, error) {
+	dataKey, err := k.dataKey(e)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return open(aead, e.Ciphertext, nil)
+}
+
+// Rewrap 以主密钥重新加密数据密钥，密文不变；已由主密钥加密时返回 false
+func (k *Keyring) Rewrap(e *Envelope) (*Envelope, bool, error) {
+	primary := k.keys[0]
+	if e.KeyID == primary.id {
+		return e, false, nil
+	}
+	dataKey, err := k.dataKey(e)
+	if err != nil {
+		return nil, false, err
+	}
+	wrapped, err := seal(primary.aead, dataKey, []byte(primary.id))
+	if err != nil {
+		return nil, false, err
+	}
+	return &Envelope{KeyID: primary.id, DataKey: wrapped, Ciphertext: e.Ciphertext}, true, nil
+}
+
+func (k *Keyring) dataKey(e *Envelope) ([]byte, error) {
+	key, err := k.key(e.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	// 以主密钥 ID 作为附加数据，避免数据密钥被标记为其他主密钥加密
+	dataKey, err := open(key.aead, e.DataKey, []byte(key.id))
+	if err != nil {
+		return nil, errors.Wrapf(err, "decrypt data key with key %q", key.id)
+	}
+	return dataKey, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	return aead, errors.WithStack(err)
+}
+
+// seal 以随机 nonce 加密，nonce 置于密文之前
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additionalData)
+	return plaintext, errors.WithStack(err)
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"bytes"
+	"douyin-action-example/internal/conf"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testKey(b byte) conf.EncryptionKeyConfig {
+	return conf.EncryptionKeyConfig{ID: fmt.Sprintf("k%d", b), Key: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))}
+}
+
+func mustKeyring(t *testing.T, keys ...conf.EncryptionKeyConfig) *Keyring {
+	t.Helper()
+	keyring, err := NewKeyring(conf.EncryptionConfig{Keys: keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keyring
+}
+
+func TestKeyringRewrap(t *testing.T) {
+	old := mustKeyring(t, testKey(1))
+	envelope, err := old.Seal([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := mustKeyring(t, testKey(2), testKey(1))
+	rewrapped, changed, err := rotated.Rewrap(envelope)
+	if err != nil || !changed {
+		t.Fatalf("rewrap: changed=%v err=%v", changed, err)
+	}
+	if rewrapped.KeyID != "k2" || !bytes.Equal(rewrapped.Ciphertext, envelope.Ciphertext) {
+		t.Errorf("rewrap should only re-encrypt the data key with k2: %+v", rewrapped)
+	}
+	if _, changed, _ := rotated.Rewrap(rewrapped); changed {
+		t.Error("an envelope of the primary key should not be rewrapped")
+	}
+
+	// 删除旧密钥后仍可解密重新加密的 Token，但无法解密未重新加密的 Token
+	current := mustKeyring(t, testKey(2))
+	if plaintext, err := current.Open(rewrapped); err != nil || string(plaintext) != "secret" {
+		t.Errorf("open rewrapped envelope: %q, %v", plaintext, err)
+	}
+	if _, err := current.Open(envelope); err == nil {
+		t.Error("expect an error for an envelope of a removed key")
+	}
+	// 数据密钥与主密钥 ID 绑定，篡改 KeyID 后无法解密
+	tampered := *rewrapped
+	tampered.KeyID = "k1"
+	if _, err := rotated.Open(&tampered); err == nil {
+		t.Error("expect an error for an envelope with a tampered key id")
+	}
+}
+
+func TestFileTokensReencrypt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	d, err := NewFileOpenIdDict(path, mustKeyring(t, testKey(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if err := d.Save(&TokenInfo{AccessToken: "act.1", RefreshToken: "rft.1", OpenID: "open-1", IssuedAt: now, ExpiresAt: now.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "act.1") || strings.Contains(string(content), "rft.1") {
+		t.Fatalf("token file stores plaintext tokens: %s", content)
+	}
+
+	rotated, err := NewFileOpenIdDict(path, mustKeyring(t, testKey(2), testKey(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, err := rotated.Reencrypt(); err != nil || count != 1 {
+		t.Fatalf("reencrypt: count=%d err=%v", count, err)
+	}
+	if count, err := rotated.Reencrypt(); err != nil || count != 0 {
+		t.Fatalf("second reencrypt: count=%d err=%v", count, err)
+	}
+
+	// 重新加密后只保留新密钥即可加载
+	current, err := NewFileOpenIdDict(path, mustKeyring(t, testKey(2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := current.GetTokenInfo("act.1")
+	if err != nil || info.RefreshToken != "rft.1" {
+		t.Fatalf("load re-encrypted token: %+v, %v", info, err)
+	}
+	if matches, _ := filepath.Glob(path + ".*.tmp"); len(matches) != 0 {
+		t.Errorf("temp files are left behind: %v", matches)
+	}
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+)
+
+// fileState 记录 file 存储的文件路径及最近一次加载或写入后的文件状态，用于发现其他进程（如管理命令）写入的变更
+type fileState struct {
+	// path 非空时每次变更都会持久化到该文件
+	path string
+	stat os.FileInfo
+}
+
+// changed 判断文件在最近一次加载或写入后是否被其他进程修改，文件不存在时视为未修改；
+// 每次写入都会以新文件替换原文件，因此同时比较文件本身，避免修改时间精度不足时漏掉连续的写入
+func (f *fileState) changed() (bool, error) {
+	if f.path == "" {
+		return false, nil
+	}
+	stat, err := os.Stat(f.path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "stat %s", f.path)
+	}
+	return f.stat == nil || !os.SameFile(f.stat, stat) || !stat.ModTime().Equal(f.stat.ModTime()) || stat.Size() != f.stat.Size(), nil
+}
+
+// synced 记录加载或写入后的文件状态
+func (f *fileState) synced() {
+	if stat, err := os.Stat(f.path); err == nil {
+		f.stat = stat
+	}
+}
+
+// writeJSONFile 将 v 编码为 JSON 写入同目录的临时文件，落盘后替换 path，避免写入中断或并发写入导致文件损坏
+func writeJSONFile(path string, v interface{}) error {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// 每次写入使用独立的临时文件，多个进程（如服务及管理命令）同时写入时不会相互覆盖临时文件
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return errors.Wrapf(err, "create temp file for %s", path)
+	}
+	defer os.Remove(tmp.Name())
+	if err := writeAndSync(tmp, content); err != nil {
+		return errors.Wrapf(err, "write %s", tmp.Name())
+	}
+	return errors.Wrapf(os.Rename(tmp.Name(), path), "replace %s", path)
+}
+
+// writeAndSync 写入 content 并落盘后关闭文件，CreateTemp 创建的文件权限已为 0600
+func writeAndSync(file *os.File, content []byte) error {
+	_, err := file.Write(content)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// readJSONFile 读取 path 中的 JSON 到 v，文件不存在时返回 false
+func readJSONFile(path string, v interface{}) (bool, error) {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "read %s", path)
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		return false, errors.Wrapf(err, "parse %s", path)
+	}
+	return true, nil
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// authorizationGrantTTL 与授权码有效期保持一致，过期的记录不再参与换取 Token
+const authorizationGrantTTL = 10 * time.Minute
+
+// AuthorizationGrant 记录了授权回调时授权码附带的信息，在换取 Token 时使用
+type AuthorizationGrant struct {
+	// PKCE 参数，客户端未使用 PKCE 时为空
+	CodeChallenge       string
+	CodeChallengeMethod string
+	// 用户在抖音授权页同意的授权范围，抖音的授权回调未返回时为授权时申请的授权范围
+	Scopes []string
+	// App 为发起授权的应用标识，换取 Token 的应用需与之一致
+	App string
+	// User 为发起授权的钉钉用户标识，为空时不关联账号
+	User string
+
+	expiresAt time.Time
+}
+
+type AuthorizationGrantDict struct {
+	dict map[string]*AuthorizationGrant
+	mu   sync.Mutex
+}
+
+func NewAuthorizationGrantDict() *AuthorizationGrantDict {
+	return &AuthorizationGrantDict{
+		dict: make(map[string]*AuthorizationGrant),
+	}
+}
+
+var GrantService *AuthorizationGrantDict
+
+func init() {
+	GrantService = NewAuthorizationGrantDict()
+}
+
+func (d *AuthorizationGrantDict) Save(code string, grant *AuthorizationGrant) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	for k, v := range d.dict {
+		if now.After(v.expiresAt) {
+			delete(d.dict, k)
+		}
+	}
+	copied := *grant
+	copied.expiresAt = now.Add(authorizationGrantTTL)
+	d.dict[code] = &copied
+}
+
+// Get 返回授权码对应的未过期记录但不删除，换取 Token 的请求通过校验之前不消耗授权码
+func (d *AuthorizationGrantDict) Get(code string) (*AuthorizationGrant, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	grant, ok := d.dict[code]
+	if !ok || time.Now().After(grant.expiresAt) {
+		return nil, false
+	}
+	return grant, true
+}
+
+// Take 取出授权码对应的记录并删除，保证每个授权码只使用一次
+func (d *AuthorizationGrantDict) Take(code string) (*AuthorizationGrant, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	grant, ok := d.dict[code]
+	if !ok {
+		return nil, false
+	}
+	delete(d.dict, code)
+	if time.Now().After(grant.expiresAt) {
+		return nil, false
+	}
+	return grant, true
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"github.com/chzealot/gobase/logger"
+	"time"
+)
+
+// TokenJanitor 定期清理已过期且 RefreshToken 也已失效的 Token，并在启动时及每次清理时将旧主密钥加密的 Token 以当前主密钥重新加密
+type TokenJanitor struct {
+	interval time.Duration
+}
+
+func NewTokenJanitor(interval time.Duration) *TokenJanitor {
+	return &TokenJanitor{
+		interval: interval,
+	}
+}
+
+func (j *TokenJanitor) Name() string {
+	return "token-janitor"
+}
+
+func (j *TokenJanitor) Run(ctx context.Context) {
+	reencrypt()
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for namespace, d := range Stores() {
+				purged, err := d.PurgeExpired(now)
+				if err != nil {
+					logger.Errorf("purge expired tokens failed, namespace=%q, err=%+v", namespace, err)
+				} else if purged > 0 {
+					logger.Infof("purged %d expired tokens, namespace=%q", purged, namespace)
+				}
+			}
+			reencrypt()
+		}
+	}
+}
+
+// reencrypt 重新加密全部命名空间中由旧主密钥加密的 Token；主密钥只在 Init 时加载，新增主密钥需重启服务后才会生效
+func reencrypt() {
+	for namespace, d := range Stores() {
+		count, err := d.Reencrypt()
+		if err != nil {
+			logger.Errorf("re-encrypt tokens failed, namespace=%q, err=%+v", namespace, err)
+		} else if count > 0 {
+			logger.Infof("re-encrypted %d tokens with the primary key, namespace=%q", count, namespace)
+		}
+	}
+}
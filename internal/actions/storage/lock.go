@@ -0,0 +1,24 @@
+//go:build !windows
+
+package storage
+
+import (
+	"github.com/pkg/errors"
+	"os"
+	"syscall"
+)
+
+// withFileLock 持有 path 对应的 .lock 文件的排他锁执行 fn，服务与管理命令写入同一文件时依次进行，
+// 避免一方基于旧数据写入而覆盖另一方的变更
+func withFileLock(path string, fn func() error) error {
+	file, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "open lock file of %s", path)
+	}
+	defer file.Close()
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		return errors.Wrapf(err, "lock %s", path)
+	}
+	defer syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+	return fn()
+}
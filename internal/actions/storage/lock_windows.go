@@ -0,0 +1,6 @@
+package storage
+
+// withFileLock 在 Windows 上不加锁直接执行 fn，服务与管理命令不应同时写入同一文件
+func withFileLock(path string, fn func() error) error {
+	return fn()
+}
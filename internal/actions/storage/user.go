@@ -1,39 +1,564 @@
 package storage
 
 import (
+	"crypto/sha256"
+	"douyin-action-example/internal/conf"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/chzealot/gobase/logger"
 	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
+// TokenInfo 记录了一个已颁发 AccessToken 的元数据，用于业务请求鉴权以及 Token 内省
+type TokenInfo struct {
+	AccessToken  string
+	RefreshToken string
+	OpenID       string
+	ClientID     string
+	// 用户授予的本服务授权范围
+	Scopes    []string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	// RefreshExpiresAt 为 RefreshToken 的过期时间，抖音未返回 refresh_expires_in 时为空
+	RefreshExpiresAt time.Time
+}
+
+// defaultRefreshTokenLifetime 为抖音 refresh_token 的有效期，用于估算未记录过期时间的 RefreshToken
+const defaultRefreshTokenLifetime = 30 * 24 * time.Hour
+
+// IsExpired 判断 Token 在 now 时刻是否已过期，未记录过期时间的 Token 视为永不过期
+func (t *TokenInfo) IsExpired(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && !now.Before(t.ExpiresAt)
+}
+
+// CanRefresh 判断在 now 时刻能否以 RefreshToken 换取新的 Token，未记录 RefreshToken 过期时间时按颁发时间及抖音的有效期估算
+func (t *TokenInfo) CanRefresh(now time.Time) bool {
+	if t.RefreshToken == "" {
+		return false
+	}
+	expiresAt := t.RefreshExpiresAt
+	if expiresAt.IsZero() {
+		if t.IssuedAt.IsZero() {
+			return true
+		}
+		expiresAt = t.IssuedAt.Add(defaultRefreshTokenLifetime)
+	}
+	return now.Before(expiresAt)
+}
+
+// ID 返回 Token 的摘要，用于在管理命令及管理接口中指代 Token 而不暴露其内容
+func (t *TokenInfo) ID() string {
+	sum := sha256.Sum256([]byte(t.AccessToken))
+	return hex.EncodeToString(sum[:6])
+}
+
+// HasScope 判断 Token 是否被授予了指定的授权范围
+func (t *TokenInfo) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+var ErrTokenNotFound = errors.New("AccessToken not found")
+
+// persistedToken 为写入文件的 Token，加密存储时 AccessToken 及 RefreshToken 为空，由 Envelope 解密得到
+type persistedToken struct {
+	TokenInfo
+	Envelope *Envelope `json:",omitempty"`
+}
+
+// tokenSecrets 为加密存储的 Token 内容
+type tokenSecrets struct {
+	AccessToken  string
+	RefreshToken string
+}
+
 type OpenIdDict struct {
-	dict map[string]string
+	dict map[string]*TokenInfo
 	mu   sync.Mutex
+	// fileState 的 path 非空时每次变更都会持久化到该文件，文件被其他进程（如管理命令）修改后重新加载
+	fileState
+	// keyring 非空时文件中的 Token 以信封加密存储，envelopes 为已加密的 Token，写入时复用，避免每次写入都重新加密
+	keyring   *Keyring
+	envelopes map[string]*Envelope
 }
 
 func NewOpenIdDict() *OpenIdDict {
 	return &OpenIdDict{
-		dict: make(map[string]string),
+		dict:      make(map[string]*TokenInfo),
+		envelopes: make(map[string]*Envelope),
+	}
+}
+
+// NewFileOpenIdDict 创建持久化到 path 的 OpenIdDict，文件存在时加载其中的 Token，keyring 非空时加密存储
+func NewFileOpenIdDict(path string, keyring *Keyring) (*OpenIdDict, error) {
+	d := NewOpenIdDict()
+	d.path = path
+	d.keyring = keyring
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// load 从文件加载全部 Token，调用方需持有锁
+func (d *OpenIdDict) load() error {
+	var tokens []*persistedToken
+	if ok, err := readJSONFile(d.path, &tokens); err != nil {
+		return errors.Wrap(err, "load token file")
+	} else if !ok {
+		return nil
+	}
+	dict := make(map[string]*TokenInfo, len(tokens))
+	envelopes := make(map[string]*Envelope)
+	for _, token := range tokens {
+		info := token.TokenInfo
+		if token.Envelope != nil {
+			if d.keyring == nil {
+				return errors.New("token file is encrypted but storage.encryption.keys is empty")
+			}
+			plaintext, err := d.keyring.Open(token.Envelope)
+			if err != nil {
+				return errors.Wrapf(err, "decrypt token of open_id %s", info.OpenID)
+			}
+			secrets := &tokenSecrets{}
+			if err := json.Unmarshal(plaintext, secrets); err != nil {
+				return errors.Wrap(err, "decode decrypted token")
+			}
+			info.AccessToken, info.RefreshToken = secrets.AccessToken, secrets.RefreshToken
+			envelopes[info.AccessToken] = token.Envelope
+		}
+		dict[info.AccessToken] = &info
+	}
+	d.dict, d.envelopes = dict, envelopes
+	d.synced()
+	return nil
+}
+
+// reload 在文件被其他进程修改后重新加载，加载失败时继续使用内存中的数据，调用方需持有锁
+func (d *OpenIdDict) reload() {
+	changed, err := d.changed()
+	if err == nil && changed {
+		err = d.load()
+	}
+	if err != nil {
+		logger.Errorf("reload token file %s failed: %+v", d.path, err)
 	}
 }
 
+// update 以 mutate 修改 Token 并写入文件，mutate 返回 false 时不写入，调用方需持有锁。
+// file 存储在文件锁内重新加载文件后修改副本，写入成功后才替换内存中的数据：
+// 与管理命令同时写入时不会丢失对方的变更，写入失败时内存中的数据保持不变
+func (d *OpenIdDict) update(mutate func(dict map[string]*TokenInfo, envelopes map[string]*Envelope) (bool, error)) error {
+	if d.path == "" {
+		_, err := mutate(d.dict, d.envelopes)
+		return err
+	}
+	return withFileLock(d.path, func() error {
+		if err := d.load(); err != nil {
+			return err
+		}
+		dict := make(map[string]*TokenInfo, len(d.dict))
+		for accessToken, info := range d.dict {
+			dict[accessToken] = info
+		}
+		envelopes := make(map[string]*Envelope, len(d.envelopes))
+		for accessToken, envelope := range d.envelopes {
+			envelopes[accessToken] = envelope
+		}
+		changed, err := mutate(dict, envelopes)
+		if err != nil || !changed {
+			return err
+		}
+		if envelopes, err = d.persist(dict, envelopes); err != nil {
+			return err
+		}
+		d.dict, d.envelopes = dict, envelopes
+		d.synced()
+		return nil
+	})
+}
+
+// OpenIdService 为默认命名空间（空字符串）的 Token 存储
 var OpenIdService *OpenIdDict
 
+var (
+	storesMu sync.RWMutex
+	// stores 为按命名空间隔离的 Token 存储，多应用部署时每个应用使用独立的命名空间
+	stores map[string]*OpenIdDict
+	// links 为按命名空间隔离的账号关联
+	links map[string]*AccountLinkDict
+	// workspaces 为按命名空间隔离的账号授予关系
+	workspaces map[string]*WorkspaceDict
+	// accessLogs 为按命名空间隔离的账号访问记录
+	accessLogs map[string]*AccessLog
+)
+
 func init() {
 	OpenIdService = NewOpenIdDict()
+	stores = map[string]*OpenIdDict{"": OpenIdService}
+	links = map[string]*AccountLinkDict{"": NewAccountLinkDict()}
+	workspaces = map[string]*WorkspaceDict{"": NewWorkspaceDict()}
+	accessLogs = map[string]*AccessLog{"": NewAccessLog(defaultMaxAccessRecords)}
+}
+
+// Init 按配置初始化默认命名空间及 namespaces 中各命名空间的 Token 存储、账号关联及工作区，
+// file 存储的命名空间写入与 path 同目录的独立文件，如 tokens.json 对应 tokens.<namespace>.json，
+// 账号关联、工作区及访问记录写入同目录的 accounts.json、workspace.json、access.jsonl 及其命名空间文件；
+// 配置了主密钥时 Token 文件加密存储
+func Init(config conf.StorageConfig, namespaces []string) error {
+	keyring, err := NewKeyring(config.Encryption)
+	if err != nil {
+		return err
+	}
+	opened := make(map[string]*OpenIdDict, len(namespaces)+1)
+	openedLinks := make(map[string]*AccountLinkDict, len(namespaces)+1)
+	openedWorkspaces := make(map[string]*WorkspaceDict, len(namespaces)+1)
+	openedAccessLogs := make(map[string]*AccessLog, len(namespaces)+1)
+	for _, namespace := range append([]string{""}, namespaces...) {
+		if _, ok := opened[namespace]; ok {
+			continue
+		}
+		switch config.Backend {
+		case "file":
+			d, err := NewFileOpenIdDict(namespacePath(config.Path, namespace), keyring)
+			if err != nil {
+				return err
+			}
+			opened[namespace] = d
+			l, err := NewFileAccountLinkDict(namespacePath(siblingPath(config.Path, "accounts.json"), namespace))
+			if err != nil {
+				return err
+			}
+			openedLinks[namespace] = l
+			w, err := NewFileWorkspaceDict(namespacePath(siblingPath(config.Path, "workspace.json"), namespace))
+			if err != nil {
+				return err
+			}
+			openedWorkspaces[namespace] = w
+			a := NewFileAccessLog(namespacePath(siblingPath(config.Path, "access.jsonl"), namespace), config.AccessLog)
+			if err := w.migrateAccess(a); err != nil {
+				return err
+			}
+			openedAccessLogs[namespace] = a
+		default:
+			opened[namespace] = NewOpenIdDict()
+			openedLinks[namespace] = NewAccountLinkDict()
+			openedWorkspaces[namespace] = NewWorkspaceDict()
+			openedAccessLogs[namespace] = NewAccessLog(config.AccessLog.MaxRecords)
+		}
+	}
+	storesMu.Lock()
+	defer storesMu.Unlock()
+	OpenIdService = opened[""]
+	stores = opened
+	links = openedLinks
+	workspaces = openedWorkspaces
+	closed := accessLogs
+	accessLogs = openedAccessLogs
+	for namespace, a := range closed {
+		if err := a.Close(); err != nil {
+			logger.Warnf("close access log of namespace %q failed: %+v", namespace, err)
+		}
+	}
+	return nil
+}
+
+// siblingPath 返回与 path 同目录的文件 name 的路径
+func siblingPath(path, name string) string {
+	return filepath.Join(filepath.Dir(path), name)
+}
+
+func namespacePath(path, namespace string) string {
+	if namespace == "" {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + namespace + ext
+}
+
+// Tokens 返回命名空间的 Token 存储，未经 Init 打开的命名空间使用内存存储
+func Tokens(namespace string) *OpenIdDict {
+	storesMu.Lock()
+	defer storesMu.Unlock()
+	d, ok := stores[namespace]
+	if !ok {
+		d = NewOpenIdDict()
+		stores[namespace] = d
+	}
+	return d
+}
+
+// AccountLinks 返回命名空间的账号关联，未经 Init 打开的命名空间使用内存存储
+func AccountLinks(namespace string) *AccountLinkDict {
+	storesMu.Lock()
+	defer storesMu.Unlock()
+	l, ok := links[namespace]
+	if !ok {
+		l = NewAccountLinkDict()
+		links[namespace] = l
+	}
+	return l
+}
+
+// Workspaces 返回命名空间的账号授予关系，未经 Init 打开的命名空间使用内存存储
+func Workspaces(namespace string) *WorkspaceDict {
+	storesMu.Lock()
+	defer storesMu.Unlock()
+	w, ok := workspaces[namespace]
+	if !ok {
+		w = NewWorkspaceDict()
+		workspaces[namespace] = w
+	}
+	return w
+}
+
+// AccessLogs 返回命名空间的账号访问记录，未经 Init 打开的命名空间使用内存存储
+func AccessLogs(namespace string) *AccessLog {
+	storesMu.Lock()
+	defer storesMu.Unlock()
+	a, ok := accessLogs[namespace]
+	if !ok {
+		a = NewAccessLog(defaultMaxAccessRecords)
+		accessLogs[namespace] = a
+	}
+	return a
+}
+
+// Stores 返回全部命名空间的 Token 存储
+func Stores() map[string]*OpenIdDict {
+	storesMu.RLock()
+	defer storesMu.RUnlock()
+	copied := make(map[string]*OpenIdDict, len(stores))
+	for namespace, d := range stores {
+		copied[namespace] = d
+	}
+	return copied
+}
+
+// FindToken 在全部命名空间中查找 Token，返回其所在的命名空间
+func FindToken(accessToken string) (*TokenInfo, string, error) {
+	for namespace, d := range Stores() {
+		if info, err := d.GetTokenInfo(accessToken); err == nil {
+			return info, namespace, nil
+		}
+	}
+	return nil, "", ErrTokenNotFound
+}
+
+// persist 将 dict 中的全部 Token 写入文件，返回写入的信封，envelopes 中没有信封的 Token 以主密钥加密
+func (d *OpenIdDict) persist(dict map[string]*TokenInfo, envelopes map[string]*Envelope) (map[string]*Envelope, error) {
+	tokens := make([]*persistedToken, 0, len(dict))
+	written := make(map[string]*Envelope)
+	for accessToken, info := range dict {
+		token := &persistedToken{TokenInfo: *info}
+		if d.keyring != nil {
+			envelope, ok := envelopes[accessToken]
+			if !ok {
+				plaintext, err := json.Marshal(&tokenSecrets{AccessToken: info.AccessToken, RefreshToken: info.RefreshToken})
+				if err != nil {
+					return nil, errors.WithStack(err)
+				}
+				if envelope, err = d.keyring.Seal(plaintext); err != nil {
+					return nil, errors.Wrap(err, "encrypt token")
+				}
+			}
+			written[accessToken] = envelope
+			token.AccessToken, token.RefreshToken = "", ""
+			token.Envelope = envelope
+		}
+		tokens = append(tokens, token)
+	}
+	if err := writeJSONFile(d.path, tokens); err != nil {
+		return nil, errors.Wrap(err, "persist tokens")
+	}
+	return written, nil
 }
 
 func (d *OpenIdDict) GetOpenIdByAccessToken(accessToken string) (string, error) {
+	info, err := d.GetTokenInfo(accessToken)
+	if err != nil {
+		return "", err
+	}
+	return info.OpenID, nil
+}
+
+func (d *OpenIdDict) GetTokenInfo(accessToken string) (*TokenInfo, error) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	openId, ok := d.dict[accessToken]
+	d.reload()
+	info, ok := d.dict[accessToken]
 	if ok {
-		return openId, nil
+		copied := *info
+		return &copied, nil
+	}
+	return nil, ErrTokenNotFound
+}
+
+// LatestByOpenID 返回抖音用户最近颁发的 Token
+func (d *OpenIdDict) LatestByOpenID(openId string) (*TokenInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.reload()
+	var latest *TokenInfo
+	for _, info := range d.dict {
+		if info.OpenID == openId && (latest == nil || info.IssuedAt.After(latest.IssuedAt)) {
+			latest = info
+		}
+	}
+	if latest == nil {
+		return nil, ErrTokenNotFound
+	}
+	copied := *latest
+	return &copied, nil
+}
+
+func (d *OpenIdDict) Save(info *TokenInfo) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	copied := *info
+	copied.Scopes = append([]string(nil), info.Scopes...)
+	return d.update(func(dict map[string]*TokenInfo, envelopes map[string]*Envelope) (bool, error) {
+		dict[info.AccessToken] = &copied
+		delete(envelopes, info.AccessToken)
+		return true, nil
+	})
+}
+
+// List 返回存储中的全部 Token，按颁发时间排序
+func (d *OpenIdDict) List() []*TokenInfo {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.reload()
+	tokens := make([]*TokenInfo, 0, len(d.dict))
+	for _, info := range d.dict {
+		copied := *info
+		tokens = append(tokens, &copied)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].IssuedAt.Before(tokens[j].IssuedAt)
+	})
+	return tokens
+}
+
+// Delete 删除 Token
+func (d *OpenIdDict) Delete(accessToken string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.update(func(dict map[string]*TokenInfo, envelopes map[string]*Envelope) (bool, error) {
+		if _, ok := dict[accessToken]; !ok {
+			return false, ErrTokenNotFound
+		}
+		delete(dict, accessToken)
+		return true, nil
+	})
+}
+
+// Replace 以刷新得到的 Token 替换原 Token
+func (d *OpenIdDict) Replace(oldAccessToken string, info *TokenInfo) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	copied := *info
+	copied.Scopes = append([]string(nil), info.Scopes...)
+	return d.update(func(dict map[string]*TokenInfo, envelopes map[string]*Envelope) (bool, error) {
+		delete(dict, oldAccessToken)
+		dict[info.AccessToken] = &copied
+		delete(envelopes, info.AccessToken)
+		return true, nil
+	})
+}
+
+// Reencrypt 以主密钥重新加密由其他主密钥加密的数据密钥，并加密尚未加密的 Token，返回重新加密的 Token 数量；
+// 信封加密只需重新加密数据密钥，Token 密文保持不变
+func (d *OpenIdDict) Reencrypt() (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.path == "" || d.keyring == nil {
+		return 0, nil
+	}
+	count := 0
+	err := d.update(func(dict map[string]*TokenInfo, envelopes map[string]*Envelope) (bool, error) {
+		count = 0
+		for accessToken := range dict {
+			envelope, ok := envelopes[accessToken]
+			if !ok {
+				// 写入时以主密钥加密
+				count++
+				continue
+			}
+			rewrapped, changed, err := d.keyring.Rewrap(envelope)
+			if err != nil {
+				return false, err
+			}
+			if changed {
+				envelopes[accessToken] = rewrapped
+				count++
+			}
+		}
+		return count > 0, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Len 返回存储中的 Token 数量
+func (d *OpenIdDict) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.reload()
+	return len(d.dict)
+}
+
+// PurgeExpired 删除在 now 时刻已过期且无法再刷新的 Token，返回删除的数量；RefreshToken 仍有效的 Token 保留，以便之后刷新
+func (d *OpenIdDict) PurgeExpired(now time.Time) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	purged := 0
+	err := d.update(func(dict map[string]*TokenInfo, envelopes map[string]*Envelope) (bool, error) {
+		purged = 0
+		for accessToken, info := range dict {
+			if info.IsExpired(now) && !info.CanRefresh(now) {
+				delete(dict, accessToken)
+				purged++
+			}
+		}
+		return purged > 0, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+// Ping 检查存储是否可用，持久化存储需要能够写入所在目录
+func (d *OpenIdDict) Ping() error {
+	if d.path == "" {
+		return nil
+	}
+	f, err := os.CreateTemp(filepath.Dir(d.path), ".ping-*")
+	if err != nil {
+		return errors.Wrap(err, "token file directory is not writable")
 	}
-	return "", errors.New("AccessToken not found")
+	f.Close()
+	return errors.WithStack(os.Remove(f.Name()))
 }
 
-func (d *OpenIdDict) Save(accessToken, openId string) {
+// Close 关闭存储，持久化存储会在关闭前写入最新的数据
+func (d *OpenIdDict) Close() error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	d.dict[accessToken] = openId
+	return d.update(func(dict map[string]*TokenInfo, envelopes map[string]*Envelope) (bool, error) {
+		return true, nil
+	})
 }
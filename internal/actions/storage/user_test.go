@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPurgeExpiredKeepsRefreshableTokens(t *testing.T) {
+	now := time.Now()
+	d := NewOpenIdDict()
+	tokens := []*TokenInfo{
+		{AccessToken: "active", RefreshToken: "r1", IssuedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{AccessToken: "refreshable", RefreshToken: "r2", IssuedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour), RefreshExpiresAt: now.Add(time.Hour)},
+		{AccessToken: "refresh-expired", RefreshToken: "r3", IssuedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour), RefreshExpiresAt: now.Add(-time.Minute)},
+		{AccessToken: "no-refresh-token", IssuedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour)},
+		// 未记录 RefreshToken 过期时间时按抖音 30 天的有效期估算
+		{AccessToken: "legacy-refreshable", RefreshToken: "r4", IssuedAt: now.Add(-24 * time.Hour), ExpiresAt: now.Add(-time.Hour)},
+		{AccessToken: "legacy-expired", RefreshToken: "r5", IssuedAt: now.Add(-31 * 24 * time.Hour), ExpiresAt: now.Add(-30 * 24 * time.Hour)},
+	}
+	for _, info := range tokens {
+		if err := d.Save(info); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	purged, err := d.PurgeExpired(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 3 {
+		t.Fatalf("purged %d tokens, want 3", purged)
+	}
+	for _, accessToken := range []string{"active", "refreshable", "legacy-refreshable"} {
+		if _, err := d.GetTokenInfo(accessToken); err != nil {
+			t.Errorf("%s was purged", accessToken)
+		}
+	}
+	for _, accessToken := range []string{"refresh-expired", "no-refresh-token", "legacy-expired"} {
+		if _, err := d.GetTokenInfo(accessToken); err == nil {
+			t.Errorf("%s was kept", accessToken)
+		}
+	}
+}
+
+func TestFileTokensConcurrentWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	// 服务与管理命令分别打开同一文件
+	writers := make([]*OpenIdDict, 2)
+	for i := range writers {
+		d, err := NewFileOpenIdDict(path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writers[i] = d
+	}
+
+	const perWriter = 20
+	var wg sync.WaitGroup
+	for i, d := range writers {
+		wg.Add(1)
+		go func(i int, d *OpenIdDict) {
+			defer wg.Done()
+			for j := 0; j < perWriter; j++ {
+				if err := d.Save(&TokenInfo{AccessToken: fmt.Sprintf("act.%d.%d", i, j), OpenID: "open-id"}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i, d)
+	}
+	wg.Wait()
+
+	loaded, err := NewFileOpenIdDict(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := loaded.Len(); n != len(writers)*perWriter {
+		t.Fatalf("file has %d tokens, want %d", n, len(writers)*perWriter)
+	}
+	if err := writers[0].Delete("act.1.0"); err != nil {
+		t.Fatalf("delete a token saved by the other writer: %v", err)
+	}
+	if n := writers[1].Len(); n != len(writers)*perWriter-1 {
+		t.Errorf("other writer sees %d tokens, want %d", n, len(writers)*perWriter-1)
+	}
+}
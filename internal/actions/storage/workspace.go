@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"github.com/chzealot/gobase/logger"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+// Role 为账号所有者授予其他钉钉用户的角色
+type Role string
+
+const (
+	RoleRead  Role = "read"
+	RoleWrite Role = "write"
+)
+
+// ParseRole 解析角色，不支持的角色返回 false
+func ParseRole(s string) (Role, bool) {
+	switch Role(s) {
+	case RoleRead, RoleWrite:
+		return Role(s), true
+	}
+	return "", false
+}
+
+// Allows 判断角色是否满足 required，write 角色同时具有 read 权限
+func (r Role) Allows(required Role) bool {
+	return r == RoleWrite || r == required
+}
+
+// AccountGrant 记录了账号所有者将抖音账号授予其他钉钉用户的角色
+type AccountGrant struct {
+	OpenID string
+	User   string
+	Role   Role
+	// GrantedBy 为授予时的账号所有者，账号改为关联到其他钉钉用户后授予失效
+	GrantedBy string
+	GrantedAt time.Time
+}
+
+var ErrGrantNotFound = errors.New("account grant not found")
+
+// WorkspaceDict 记录了抖音账号的授予关系，访问记录另由 AccessLog 追加写入
+type WorkspaceDict struct {
+	data workspaceData
+	mu   sync.Mutex
+	// fileState 的 path 非空时每次变更都会持久化到该文件，文件被其他进程（如管理命令）修改后重新加载
+	fileState
+}
+
+type workspaceData struct {
+	Grants []*AccountGrant
+	// Access 为旧版本写入工作区文件的访问记录，加载后迁移到访问日志
+	Access []*AccessRecord `json:",omitempty"`
+}
+
+func NewWorkspaceDict() *WorkspaceDict {
+	return &WorkspaceDict{}
+}
+
+// NewFileWorkspaceDict 创建持久化到 path 的 WorkspaceDict，文件存在时加载其中的授予关系
+func NewFileWorkspaceDict(path string) (*WorkspaceDict, error) {
+	d := NewWorkspaceDict()
+	d.path = path
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// load 从文件加载授予关系，调用方需持有锁
+func (d *WorkspaceDict) load() error {
+	var data workspaceData
+	if ok, err := readJSONFile(d.path, &data); err != nil {
+		return errors.Wrap(err, "load workspace file")
+	} else if !ok {
+		return nil
+	}
+	d.data = data
+	d.synced()
+	return nil
+}
+
+// reload 在文件被其他进程（如管理命令）修改后重新加载，加载失败时继续使用内存中的数据，调用方需持有锁
+func (d *WorkspaceDict) reload() {
+	changed, err := d.changed()
+	if err == nil && changed {
+		err = d.load()
+	}
+	if err != nil {
+		logger.Errorf("reload workspace file %s failed: %+v", d.path, err)
+	}
+}
+
+// update 以 mutate 修改授予关系并写入文件，mutate 返回 false 时不写入，调用方需持有锁；
+// file 存储在文件锁内重新加载文件后修改副本，写入成功后才替换内存中的数据
+func (d *WorkspaceDict) update(mutate func(data *workspaceData) (bool, error)) error {
+	if d.path == "" {
+		_, err := mutate(&d.data)
+		return err
+	}
+	return withFileLock(d.path, func() error {
+		if err := d.load(); err != nil {
+			return err
+		}
+		data := workspaceData{
+			Grants: append([]*AccountGrant(nil), d.data.Grants...),
+			Access: append([]*AccessRecord(nil), d.data.Access...),
+		}
+		changed, err := mutate(&data)
+		if err != nil || !changed {
+			return err
+		}
+		if err := writeJSONFile(d.path, &data); err != nil {
+			return errors.Wrap(err, "persist workspace")
+		}
+		d.data = data
+		d.synced()
+		return nil
+	})
+}
+
+// Grant 授予钉钉用户抖音账号的角色，已授予时更新角色
+func (d *WorkspaceDict) Grant(grant *AccountGrant) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	copied := *grant
+	return d.update(func(data *workspaceData) (bool, error) {
+		for i, g := range data.Grants {
+			if g.OpenID == grant.OpenID && g.User == grant.User {
+				data.Grants[i] = &copied
+				return true, nil
+			}
+		}
+		data.Grants = append(data.Grants, &copied)
+		return true, nil
+	})
+}
+
+// Revoke 收回钉钉用户在抖音账号上的角色
+func (d *WorkspaceDict) Revoke(openId, user string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.update(func(data *workspaceData) (bool, error) {
+		for i, g := range data.Grants {
+			if g.OpenID == openId && g.User == user {
+				data.Grants = append(data.Grants[:i:i], data.Grants[i+1:]...)
+				return true, nil
+			}
+		}
+		return false, ErrGrantNotFound
+	})
+}
+
+// Grants 返回满足 match 的授予关系
+func (d *WorkspaceDict) Grants(match func(grant *AccountGrant) bool) []*AccountGrant {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.reload()
+	var grants []*AccountGrant
+	for _, g := range d.data.Grants {
+		if match(g) {
+			copied := *g
+			grants = append(grants, &copied)
+		}
+	}
+	return grants
+}
+
+// migrateAccess 将旧版本文件中的访问记录追加到 log，并从工作区文件中移除
+func (d *WorkspaceDict) migrateAccess(log *AccessLog) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.data.Access) == 0 {
+		return nil
+	}
+	return d.update(func(data *workspaceData) (bool, error) {
+		if len(data.Access) == 0 {
+			return false, nil
+		}
+		for _, record := range data.Access {
+			if err := log.Record(record); err != nil {
+				return false, errors.Wrap(err, "migrate access records")
+			}
+		}
+		data.Access = nil
+		return true, nil
+	})
+}
@@ -0,0 +1,165 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testKeyPair 为 PEM 编码的自签名证书及私钥
+type testKeyPair struct {
+	cert []byte
+	key  []byte
+}
+
+func newTestKeyPair(t *testing.T, commonName string) *testKeyPair {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testKeyPair{
+		cert: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		key:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+	}
+}
+
+// writeKeyPair 写入证书及私钥文件，并将修改时间设为 modTime，避免文件系统时间精度导致变更检测不到
+func writeKeyPair(t *testing.T, certFile, keyFile string, cert, key []byte, modTime time.Time) {
+	t.Helper()
+	for file, content := range map[string][]byte{certFile: cert, keyFile: key} {
+		if err := os.WriteFile(file, content, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func currentCommonName(t *testing.T, r *certReloader) string {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+// waitForCommonName 等待 Run 加载通用名为 want 的证书
+func waitForCommonName(t *testing.T, r *certReloader, want string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for currentCommonName(t, r) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("certificate is %q, want %q", currentCommonName(t, r), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCertReloaderSwapsCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	old, renewed := newTestKeyPair(t, "old.example.com"), newTestKeyPair(t, "new.example.com")
+	modTime := time.Now().Add(-time.Hour)
+	writeKeyPair(t, certFile, keyFile, old.cert, old.key, modTime)
+
+	r, err := newCertReloader(certFile, keyFile, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := currentCommonName(t, r); name != "old.example.com" {
+		t.Fatalf("initial certificate is %q", name)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(stopped)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	writeKeyPair(t, certFile, keyFile, renewed.cert, renewed.key, modTime.Add(time.Minute))
+	waitForCommonName(t, r, "new.example.com")
+
+	// 新证书与旧私钥不匹配时保留当前证书，修正后再次加载
+	writeKeyPair(t, certFile, keyFile, old.cert, renewed.key, modTime.Add(2*time.Minute))
+	time.Sleep(20 * time.Millisecond)
+	if name := currentCommonName(t, r); name != "new.example.com" {
+		t.Fatalf("certificate is %q after a mismatched pair, want the previous one", name)
+	}
+	writeKeyPair(t, certFile, keyFile, old.cert, old.key, modTime.Add(3*time.Minute))
+	waitForCommonName(t, r, "old.example.com")
+}
+
+func TestCertReloaderKeepsCertificateOnBadPair(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	pair, other := newTestKeyPair(t, "example.com"), newTestKeyPair(t, "other.example.com")
+	modTime := time.Now().Add(-time.Hour)
+	writeKeyPair(t, certFile, keyFile, pair.cert, pair.key, modTime)
+	r, err := newCertReloader(certFile, keyFile, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, _ := r.GetCertificate(nil)
+
+	for name, bad := range map[string]*testKeyPair{
+		"mismatched key": {cert: pair.cert, key: other.key},
+		"corrupted cert": {cert: []byte("not a certificate"), key: pair.key},
+	} {
+		writeKeyPair(t, certFile, keyFile, bad.cert, bad.key, modTime.Add(time.Minute))
+		if err := r.reload(); err == nil {
+			t.Fatalf("%s: reload succeeded", name)
+		}
+		after, _ := r.GetCertificate(nil)
+		if after != before || !bytes.Equal(after.Certificate[0], before.Certificate[0]) {
+			t.Fatalf("%s: certificate was replaced", name)
+		}
+	}
+	if err := os.Remove(keyFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reload(); err == nil {
+		t.Fatal("reload succeeded without a key file")
+	}
+	if after, _ := r.GetCertificate(nil); after != before {
+		t.Fatal("certificate was replaced after the key file was removed")
+	}
+	if _, err := newCertReloader(certFile, keyFile, time.Minute); err == nil {
+		t.Fatal("newCertReloader succeeded without a key file")
+	}
+}
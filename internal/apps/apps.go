@@ -0,0 +1,217 @@
+package apps
+
+import (
+	"context"
+	"crypto/subtle"
+	"douyin-action-example/internal/actions/models"
+	"douyin-action-example/internal/conf"
+	"github.com/pkg/errors"
+	"sync"
+)
+
+// DefaultID 为未配置 apps 时，由 douyin 中的应用凭证构成的默认应用的标识
+const DefaultID = "default"
+
+// App 为一个抖音开放平台应用，钉钉侧以 client_id 区分
+type App struct {
+	ID string
+	// ClientID、ClientSecret 为钉钉侧的客户端凭证，默认应用的 ClientID 为空时接受任意 client_id
+	ClientID     string
+	ClientSecret string
+	// ClientKey、ClientSecret 为抖音开放平台的应用凭证，默认应用未配置时透传钉钉侧的客户端凭证
+	DouYinClientKey    string
+	DouYinClientSecret string
+	// RedirectUris 为允许的客户端回调地址，为空时拒绝全部授权请求
+	RedirectUris []string
+	// Scopes 为该应用开通的授权范围，为空时可使用全部授权范围
+	Scopes []string
+	// Namespace 为 Token 存储的命名空间，默认应用为空字符串
+	Namespace string
+}
+
+// AllowsScope 判断应用是否开通了授权范围
+func (a *App) AllowsScope(scope string) bool {
+	if len(a.Scopes) == 0 {
+		return true
+	}
+	for _, s := range a.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsRedirectUri 判断客户端回调地址是否已登记，需与登记的地址完全一致
+func (a *App) AllowsRedirectUri(redirectUri string) bool {
+	for _, uri := range a.RedirectUris {
+		if uri == redirectUri {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultScopes 返回客户端未指定 scope 时默认申请的、应用已开通的授权范围
+func (a *App) DefaultScopes() []string {
+	var scopes []string
+	for _, scope := range models.ScopeCatalog {
+		if scope.Default && a.AllowsScope(scope.Name) {
+			scopes = append(scopes, scope.Name)
+		}
+	}
+	return scopes
+}
+
+// ClientKey 返回授权时使用的抖音 client_key，未配置时透传钉钉侧的 client_id
+func (a *App) ClientKey(clientId string) string {
+	if a.DouYinClientKey != "" {
+		return a.DouYinClientKey
+	}
+	return clientId
+}
+
+// HasCredentials 判断是否配置了抖音应用凭证，获取 client_token 需要应用凭证
+func (a *App) HasCredentials() bool {
+	return a.DouYinClientKey != "" && a.DouYinClientSecret != ""
+}
+
+// Authenticate 在配置了抖音应用凭证时校验钉钉侧的客户端凭证，未配置时交由抖音校验
+func (a *App) Authenticate(clientId, clientSecret string) bool {
+	if a.DouYinClientSecret == "" {
+		return true
+	}
+	return (a.ClientID == "" || subtle.ConstantTimeCompare([]byte(clientId), []byte(a.ClientID)) == 1) &&
+		subtle.ConstantTimeCompare([]byte(clientSecret), []byte(a.ClientSecret)) == 1
+}
+
+// AuthenticateClient 校验钉钉侧的客户端凭证，与 Authenticate 不同，应用未配置 client_secret 时拒绝全部请求，
+// 用于 Token 内省等不经过抖音校验客户端凭证的接口
+func (a *App) AuthenticateClient(clientId, clientSecret string) bool {
+	if a.ClientSecret == "" {
+		return false
+	}
+	return (a.ClientID == "" || subtle.ConstantTimeCompare([]byte(clientId), []byte(a.ClientID)) == 1) &&
+		subtle.ConstantTimeCompare([]byte(clientSecret), []byte(a.ClientSecret)) == 1
+}
+
+var (
+	mu          sync.RWMutex
+	all         []*App
+	byClientID  map[string]*App
+	byID        map[string]*App
+	byNamespace map[string]*App
+	configured  bool
+)
+
+func init() {
+	_ = Init(conf.Default())
+}
+
+// Init 按配置加载应用，未配置 apps 时以 douyin 中的应用凭证作为唯一的默认应用
+func Init(config *conf.Config) error {
+	var loaded []*App
+	if len(config.Apps) == 0 {
+		loaded = []*App{{
+			ID:                 DefaultID,
+			ClientID:           config.DouYin.ClientKey,
+			ClientSecret:       config.DouYin.ClientSecret,
+			DouYinClientKey:    config.DouYin.ClientKey,
+			DouYinClientSecret: config.DouYin.ClientSecret,
+			RedirectUris:       config.DouYin.RedirectUris,
+		}}
+	}
+	for _, app := range config.Apps {
+		for _, scope := range app.Scopes {
+			if _, ok := models.LookupScope(scope); !ok {
+				return errors.Errorf("unknown scope %q of app %s", scope, app.ID)
+			}
+		}
+		clientSecret := app.ClientSecret
+		if clientSecret == "" {
+			clientSecret = app.DouYinClientSecret
+		}
+		loaded = append(loaded, &App{
+			ID:                 app.ID,
+			ClientID:           app.ClientID,
+			ClientSecret:       clientSecret,
+			DouYinClientKey:    app.DouYinClientKey,
+			DouYinClientSecret: app.DouYinClientSecret,
+			RedirectUris:       app.RedirectUris,
+			Scopes:             models.FromDouYinScopes(app.Scopes),
+			Namespace:          app.Namespace,
+		})
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	all = loaded
+	configured = len(config.Apps) > 0
+	byClientID = make(map[string]*App, len(loaded))
+	byID = make(map[string]*App, len(loaded))
+	byNamespace = make(map[string]*App, len(loaded))
+	for _, app := range loaded {
+		byClientID[app.ClientID] = app
+		byID[app.ID] = app
+		byNamespace[app.Namespace] = app
+	}
+	return nil
+}
+
+// All 返回全部应用
+func All() []*App {
+	mu.RLock()
+	defer mu.RUnlock()
+	return all
+}
+
+// Namespaces 返回全部应用的 Token 存储命名空间
+func Namespaces() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	namespaces := make([]string, 0, len(all))
+	for _, app := range all {
+		namespaces = append(namespaces, app.Namespace)
+	}
+	return namespaces
+}
+
+// ByClientID 按钉钉侧的 client_id 查找应用，未配置 apps 时总是返回默认应用
+func ByClientID(clientId string) (*App, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if !configured {
+		return all[0], true
+	}
+	app, ok := byClientID[clientId]
+	return app, ok
+}
+
+// ByID 按应用标识查找应用
+func ByID(id string) (*App, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	app, ok := byID[id]
+	return app, ok
+}
+
+// ByNamespace 按 Token 存储命名空间查找应用
+func ByNamespace(namespace string) (*App, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	app, ok := byNamespace[namespace]
+	return app, ok
+}
+
+type contextKey struct{}
+
+// WithApp 将处理当前请求的应用写入 ctx
+func WithApp(ctx context.Context, app *App) context.Context {
+	return context.WithValue(ctx, contextKey{}, app)
+}
+
+// FromContext 返回处理当前请求的应用，未写入时返回 nil
+func FromContext(ctx context.Context) *App {
+	app, _ := ctx.Value(contextKey{}).(*App)
+	return app
+}
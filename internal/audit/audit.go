@@ -0,0 +1,148 @@
+package audit
+
+import (
+	"douyin-action-example/internal/conf"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+// Record 为一次业务动作调用的审计记录
+type Record struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id,omitempty"`
+	App       string    `json:"app,omitempty"`
+	Method    string    `json:"method"`
+	Action    string    `json:"action"`
+	// User 为发起调用的钉钉用户，未配置 accounts.user_header 且账号未关联时为空
+	User   string `json:"user,omitempty"`
+	OpenID string `json:"open_id,omitempty"`
+	// Params 为查询参数及 JSON 请求体，Token、密钥等敏感参数已隐藏
+	Params map[string]interface{} `json:"params,omitempty"`
+	Status int                    `json:"status"`
+	Error  string                 `json:"error,omitempty"`
+	// UpstreamCode 为抖音开放平台返回的业务错误码，成功或未调用抖音时为 0
+	UpstreamCode int64 `json:"upstream_code"`
+	LatencyMs    int64 `json:"latency_ms"`
+}
+
+// Sink 为审计日志的写入目标，只能追加记录，实现需保证并发安全
+type Sink interface {
+	Write(record *Record) error
+	Close() error
+}
+
+// Filter 为查询审计记录的条件，零值字段不作限制
+type Filter struct {
+	Since  time.Time
+	Until  time.Time
+	App    string
+	User   string
+	OpenID string
+	Action string
+	// Limit 为返回的最大记录数，为 0 时不限制
+	Limit int
+}
+
+// Match 判断记录是否满足条件
+func (f *Filter) Match(record *Record) bool {
+	switch {
+	case !f.Since.IsZero() && record.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !record.Time.Before(f.Until):
+		return false
+	case f.App != "" && record.App != f.App:
+		return false
+	case f.User != "" && record.User != f.User:
+		return false
+	case f.OpenID != "" && record.OpenID != f.OpenID:
+		return false
+	case f.Action != "" && record.Action != f.Action:
+		return false
+	}
+	return true
+}
+
+// Querier 为支持查询的写入目标，按时间倒序返回满足条件的记录
+type Querier interface {
+	Query(filter *Filter) ([]*Record, error)
+}
+
+// Factory 按配置创建写入目标
+type Factory func(config conf.AuditConfig) (Sink, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = map[string]Factory{
+		"file": func(config conf.AuditConfig) (Sink, error) {
+			return NewFileSink(config.Path, int64(config.MaxSizeMB)<<20, config.MaxBackups), nil
+		},
+	}
+)
+
+// Register 注册写入目标，用于接入日志平台等外部系统，需在加载配置之前调用
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[name] = factory
+}
+
+// New 按配置中的 sink 创建写入目标
+func New(config conf.AuditConfig) (Sink, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[config.Sink]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, errors.Errorf("unknown audit sink %q", config.Sink)
+	}
+	return factory(config)
+}
+
+var (
+	sinkMu sync.RWMutex
+	sink   Sink
+)
+
+// Init 按配置创建服务使用的写入目标，未启用审计时不记录
+func Init(config conf.AuditConfig) error {
+	var s Sink
+	if config.Enabled {
+		var err error
+		if s, err = New(config); err != nil {
+			return err
+		}
+	}
+	sinkMu.Lock()
+	defer sinkMu.Unlock()
+	sink = s
+	return nil
+}
+
+// Enabled 判断是否启用了审计
+func Enabled() bool {
+	sinkMu.RLock()
+	defer sinkMu.RUnlock()
+	return sink != nil
+}
+
+// Write 追加一条审计记录，未启用审计时忽略
+func Write(record *Record) error {
+	sinkMu.RLock()
+	defer sinkMu.RUnlock()
+	if sink == nil {
+		return nil
+	}
+	return sink.Write(record)
+}
+
+// Close 关闭服务使用的写入目标
+func Close() error {
+	sinkMu.Lock()
+	defer sinkMu.Unlock()
+	if sink == nil {
+		return nil
+	}
+	err := sink.Close()
+	sink = nil
+	return err
+}
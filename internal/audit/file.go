@@ -0,0 +1,81 @@
+package audit
+
+import (
+	"bufio"
+	"douyin-action-example/internal/logfile"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"os"
+	"sort"
+)
+
+// FileSink 以 JSON Lines 追加写入审计记录，文件超过 maxSize 后重命名为 path.<轮转时间> 并写入新文件
+type FileSink struct {
+	path   string
+	writer *logfile.Writer
+}
+
+// NewFileSink 创建写入 path 的 FileSink，文件在第一次写入时打开，maxBackups 为 0 时保留全部轮转文件
+func NewFileSink(path string, maxSize int64, maxBackups int) *FileSink {
+	return &FileSink{path: path, writer: logfile.New(path, maxSize, maxBackups)}
+}
+
+func (s *FileSink) Write(record *Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return s.writer.Write(line)
+}
+
+func (s *FileSink) Close() error {
+	return s.writer.Close()
+}
+
+// Query 依次读取轮转文件及当前文件，按时间倒序返回满足条件的记录，无法解析的行被跳过
+func (s *FileSink) Query(filter *Filter) ([]*Record, error) {
+	files, err := logfile.Rotated(s.path)
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, s.path)
+	var records []*Record
+	for _, path := range files {
+		matched, err := readRecords(path, filter)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, matched...)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.After(records[j].Time)
+	})
+	if filter.Limit > 0 && len(records) > filter.Limit {
+		records = records[:filter.Limit]
+	}
+	return records, nil
+}
+
+func readRecords(path string, filter *Filter) ([]*Record, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer file.Close()
+	var records []*Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		record := &Record{}
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			continue
+		}
+		if filter.Match(record) {
+			records = append(records, record)
+		}
+	}
+	return records, errors.Wrapf(scanner.Err(), "read audit log %s", path)
+}
@@ -0,0 +1,137 @@
+package breaker
+
+import (
+	"sync"
+	"time"
+)
+
+// State 为熔断器的状态
+type State int
+
+const (
+	// Closed 正常放行请求
+	Closed State = iota
+	// Open 熔断中，直接拒绝请求
+	Open
+	// HalfOpen 熔断时间结束后放行一个探测请求，成功则恢复，失败则重新熔断
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Breaker 为连续失败计数的熔断器，连续失败达到阈值后熔断 openDuration
+type Breaker struct {
+	failureThreshold int
+	openDuration     time.Duration
+	now              func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func New(failureThreshold int, openDuration time.Duration) *Breaker {
+	return &Breaker{
+		failureThreshold: failureThreshold,
+		openDuration:     openDuration,
+		now:              time.Now,
+	}
+}
+
+// Allow 判断是否放行请求，放行后调用方需通过 Success、Failure 或 Release 报告结果
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case Open:
+		if b.now().Sub(b.openedAt) < b.openDuration {
+			return false
+		}
+		b.state = HalfOpen
+		b.probing = true
+		return true
+	case HalfOpen:
+		// 半开状态下同时只放行一个探测请求
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = Closed
+	b.failures = 0
+	b.probing = false
+}
+
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	if b.state == HalfOpen || b.failures >= b.failureThreshold {
+		b.state = Open
+		b.openedAt = b.now()
+	}
+}
+
+// Release 放弃报告放行的请求的结果，用于调用方取消等与下游无关的失败，不计入成功或失败；
+// 半开状态下释放探测名额，以便放行下一个探测请求
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// State 返回熔断器当前的状态
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Group 按名称管理一组配置相同的熔断器
+type Group struct {
+	failureThreshold int
+	openDuration     time.Duration
+
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+}
+
+func NewGroup(failureThreshold int, openDuration time.Duration) *Group {
+	return &Group{
+		failureThreshold: failureThreshold,
+		openDuration:     openDuration,
+		breakers:         make(map[string]*Breaker),
+	}
+}
+
+// Get 返回名称对应的熔断器，不存在时创建
+func (g *Group) Get(name string) *Breaker {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	b, ok := g.breakers[name]
+	if !ok {
+		b = New(g.failureThreshold, g.openDuration)
+		g.breakers[name] = b
+	}
+	return b
+}
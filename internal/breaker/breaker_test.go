@@ -0,0 +1,110 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock 为可手动推进的时钟
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newTestBreaker(threshold int, openDuration time.Duration) (*Breaker, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	b := New(threshold, openDuration)
+	b.now = clock.Now
+	return b, clock
+}
+
+func TestBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	b, _ := newTestBreaker(3, time.Minute)
+	for i := 0; i < 2; i++ {
+		if !b.Allow() {
+			t.Fatalf("request %d should be allowed", i)
+		}
+		b.Failure()
+	}
+	// 成功后重新计数
+	b.Allow()
+	b.Success()
+	for i := 0; i < 2; i++ {
+		b.Allow()
+		b.Failure()
+	}
+	if b.State() != Closed {
+		t.Fatalf("state = %s, want closed before reaching the threshold", b.State())
+	}
+	b.Allow()
+	b.Failure()
+	if b.State() != Open || b.Allow() {
+		t.Fatalf("state = %s, want open and requests rejected", b.State())
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	b, clock := newTestBreaker(1, time.Minute)
+	b.Allow()
+	b.Failure()
+
+	clock.now = clock.now.Add(59 * time.Second)
+	if b.Allow() {
+		t.Fatal("request should be rejected before open duration ends")
+	}
+	clock.now = clock.now.Add(time.Second)
+	if !b.Allow() || b.State() != HalfOpen {
+		t.Fatalf("state = %s, want a half-open probe", b.State())
+	}
+	if b.Allow() {
+		t.Fatal("only one probe is allowed while half-open")
+	}
+	// 探测失败重新熔断
+	b.Failure()
+	if b.State() != Open || b.Allow() {
+		t.Fatalf("state = %s, want open after a failed probe", b.State())
+	}
+
+	clock.now = clock.now.Add(time.Minute)
+	if !b.Allow() {
+		t.Fatal("probe should be allowed after open duration")
+	}
+	b.Success()
+	if b.State() != Closed || !b.Allow() || !b.Allow() {
+		t.Fatalf("state = %s, want closed after a successful probe", b.State())
+	}
+}
+
+func TestBreakerRelease(t *testing.T) {
+	b, clock := newTestBreaker(1, time.Minute)
+	b.Allow()
+	b.Release()
+	if b.State() != Closed {
+		t.Fatalf("state = %s, released requests must not count as failures", b.State())
+	}
+
+	b.Allow()
+	b.Failure()
+	clock.now = clock.now.Add(time.Minute)
+	b.Allow()
+	// 探测请求被调用方取消后释放名额，下一个请求可以继续探测
+	b.Release()
+	if b.State() != HalfOpen || !b.Allow() {
+		t.Fatalf("state = %s, want another probe allowed after release", b.State())
+	}
+}
+
+func TestGroup(t *testing.T) {
+	g := NewGroup(1, time.Minute)
+	if g.Get("/a") != g.Get("/a") || g.Get("/a") == g.Get("/b") {
+		t.Fatal("group should return one breaker per name")
+	}
+	g.Get("/a").Allow()
+	g.Get("/a").Failure()
+	if g.Get("/b").State() != Closed {
+		t.Fatal("breakers of different names must be independent")
+	}
+}
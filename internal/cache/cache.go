@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"container/list"
+	"douyin-action-example/internal/conf"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+// Backend 为缓存的存储后端，实现需保证并发安全
+type Backend interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration)
+	Delete(key string)
+}
+
+// Factory 按配置创建缓存后端
+type Factory func(config conf.CacheConfig) (Backend, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = map[string]Factory{
+		"memory": func(config conf.CacheConfig) (Backend, error) {
+			return NewLRU(config.Capacity), nil
+		},
+	}
+)
+
+// Register 注册缓存后端，用于接入 Redis 等外部缓存，需在加载配置之前调用
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[name] = factory
+}
+
+// New 按配置中的 backend 创建缓存后端
+func New(config conf.CacheConfig) (Backend, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[config.Backend]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, errors.Errorf("unknown cache backend %q", config.Backend)
+	}
+	return factory(config)
+}
+
+type entry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// LRU 为进程内的最近最少使用缓存，容量满时淘汰最久未访问的条目
+type LRU struct {
+	capacity int
+	now      func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func NewLRU(capacity int) *LRU {
+	return &LRU{
+		capacity: capacity,
+		now:      time.Now,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+func (l *LRU) Get(key string) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	element, ok := l.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := element.Value.(*entry)
+	if !l.now().Before(e.expiresAt) {
+		l.remove(element)
+		return nil, false
+	}
+	l.order.MoveToFront(element)
+	return e.value, true
+}
+
+func (l *LRU) Set(key string, value []byte, ttl time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	expiresAt := l.now().Add(ttl)
+	if element, ok := l.entries[key]; ok {
+		e := element.Value.(*entry)
+		e.value = value
+		e.expiresAt = expiresAt
+		l.order.MoveToFront(element)
+		return
+	}
+	l.entries[key] = l.order.PushFront(&entry{key: key, value: value, expiresAt: expiresAt})
+	for l.order.Len() > l.capacity {
+		l.remove(l.order.Back())
+	}
+}
+
+func (l *LRU) Delete(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if element, ok := l.entries[key]; ok {
+		l.remove(element)
+	}
+}
+
+// Len 返回缓存中的条目数量，包含已过期但尚未淘汰的条目
+func (l *LRU) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+func (l *LRU) remove(element *list.Element) {
+	l.order.Remove(element)
+	delete(l.entries, element.Value.(*entry).key)
+}
@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"douyin-action-example/internal/conf"
+	"testing"
+	"time"
+)
+
+// fakeClock 为可手动推进的时钟
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newTestLRU(capacity int) (*LRU, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := NewLRU(capacity)
+	l.now = clock.Now
+	return l, clock
+}
+
+func assertCached(t *testing.T, l *LRU, key, want string) {
+	t.Helper()
+	value, ok := l.Get(key)
+	if !ok {
+		t.Fatalf("%s is not cached", key)
+	}
+	if string(value) != want {
+		t.Fatalf("%s = %q, want %q", key, value, want)
+	}
+}
+
+func assertNotCached(t *testing.T, l *LRU, key string) {
+	t.Helper()
+	if value, ok := l.Get(key); ok {
+		t.Fatalf("%s is still cached: %q", key, value)
+	}
+}
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	l, _ := newTestLRU(2)
+	l.Set("a", []byte("1"), time.Minute)
+	l.Set("b", []byte("2"), time.Minute)
+	// 访问 a 后 b 成为最久未访问的条目
+	assertCached(t, l, "a", "1")
+	l.Set("c", []byte("3"), time.Minute)
+
+	assertNotCached(t, l, "b")
+	assertCached(t, l, "a", "1")
+	assertCached(t, l, "c", "3")
+	if n := l.Len(); n != 2 {
+		t.Fatalf("len = %d, want 2", n)
+	}
+}
+
+func TestLRUExpires(t *testing.T) {
+	l, clock := newTestLRU(10)
+	l.Set("short", []byte("1"), time.Second)
+	l.Set("long", []byte("2"), time.Minute)
+
+	clock.now = clock.now.Add(time.Second - time.Nanosecond)
+	assertCached(t, l, "short", "1")
+	clock.now = clock.now.Add(time.Nanosecond)
+	assertNotCached(t, l, "short")
+	assertCached(t, l, "long", "2")
+	// 过期条目在读取时淘汰
+	if n := l.Len(); n != 1 {
+		t.Fatalf("len = %d, want 1", n)
+	}
+}
+
+func TestLRUUpdateInPlace(t *testing.T) {
+	l, clock := newTestLRU(2)
+	l.Set("a", []byte("1"), time.Second)
+	l.Set("b", []byte("2"), time.Minute)
+	// 更新 a 的值及过期时间，并使其成为最近访问的条目
+	l.Set("a", []byte("updated"), time.Minute)
+	if n := l.Len(); n != 2 {
+		t.Fatalf("len = %d after update, want 2", n)
+	}
+	l.Set("c", []byte("3"), time.Minute)
+	assertNotCached(t, l, "b")
+
+	clock.now = clock.now.Add(2 * time.Second)
+	assertCached(t, l, "a", "updated")
+}
+
+func TestLRUDelete(t *testing.T) {
+	l, _ := newTestLRU(2)
+	l.Set("a", []byte("1"), time.Minute)
+	l.Delete("a")
+	l.Delete("missing")
+	assertNotCached(t, l, "a")
+	if n := l.Len(); n != 0 {
+		t.Fatalf("len = %d, want 0", n)
+	}
+}
+
+func TestNewUnknownBackend(t *testing.T) {
+	if _, err := New(conf.CacheConfig{Backend: "redis", Capacity: 1}); err == nil {
+		t.Fatal("unknown backend should fail")
+	}
+	backend, err := New(conf.CacheConfig{Backend: "memory", Capacity: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := backend.(*LRU); !ok {
+		t.Fatalf("memory backend is %T", backend)
+	}
+}
@@ -0,0 +1,865 @@
+package conf
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config 为服务的完整配置，依次由默认值、配置文件、环境变量覆盖得到
+type Config struct {
+	// Listen 为 HTTP 服务监听地址
+	Listen string `yaml:"listen" toml:"listen"`
+	// PublicBaseUrl 为本服务对外的访问地址（如 https://douyin-example.dingtalkapps.com），为空时根据请求推断
+	PublicBaseUrl string `yaml:"public_base_url" toml:"public_base_url"`
+	// TrustForwardedHeaders 为 true 时信任反向代理设置的 X-Forwarded-Host/X-Forwarded-Proto
+	TrustForwardedHeaders bool         `yaml:"trust_forwarded_headers" toml:"trust_forwarded_headers"`
+	Server                ServerConfig `yaml:"server" toml:"server"`
+	// ShutdownTimeout 为停止服务时等待处理中请求完成的最长时间
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	// LogLevel 可选 debug、info、warn、error
+	LogLevel string       `yaml:"log_level" toml:"log_level"`
+	DouYin   DouYinConfig `yaml:"douyin" toml:"douyin"`
+	// Apps 为多应用部署时的抖音应用列表，按钉钉侧的 client_id 区分，为空时只使用 douyin 中的应用凭证
+	Apps    []DouYinAppConfig `yaml:"apps" toml:"apps"`
+	Metrics MetricsConfig     `yaml:"metrics" toml:"metrics"`
+	Tracing TracingConfig     `yaml:"tracing" toml:"tracing"`
+	// RateLimit 为调用抖音开放平台前的限流配置，避免耗尽抖音的配额
+	RateLimit RateLimitConfig `yaml:"rate_limit" toml:"rate_limit"`
+	// Cache 为抖音读接口的响应缓存配置
+	Cache   CacheConfig   `yaml:"cache" toml:"cache"`
+	Storage StorageConfig `yaml:"storage" toml:"storage"`
+	State   StateConfig   `yaml:"state" toml:"state"`
+	// Accounts 为钉钉用户关联多个抖音账号的配置
+	Accounts AccountsConfig `yaml:"accounts" toml:"accounts"`
+	// Admin 为运维管理接口及控制台的配置
+	Admin AdminConfig `yaml:"admin" toml:"admin"`
+	// Audit 为业务动作审计日志的配置
+	Audit AuditConfig `yaml:"audit" toml:"audit"`
+}
+
+// ServerConfig 为 HTTP 服务的监听及连接配置
+type ServerConfig struct {
+	// Network 可选 tcp（IPv4/IPv6 双栈）、tcp4、tcp6
+	Network           string   `yaml:"network" toml:"network"`
+	ReadHeaderTimeout Duration `yaml:"read_header_timeout" toml:"read_header_timeout"`
+	ReadTimeout       Duration `yaml:"read_timeout" toml:"read_timeout"`
+	// WriteTimeout 需大于 douyin.timeout，否则调用抖音较慢时响应会被截断
+	WriteTimeout Duration `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout  Duration `yaml:"idle_timeout" toml:"idle_timeout"`
+	// KeepAlive 为 TCP keep-alive 探测间隔，为负数时关闭
+	KeepAlive Duration  `yaml:"keep_alive" toml:"keep_alive"`
+	TLS       TLSConfig `yaml:"tls" toml:"tls"`
+}
+
+// TLSConfig 为 HTTPS 配置，证书与私钥文件均为空时以 HTTP 提供服务
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file" toml:"cert_file"`
+	KeyFile  string `yaml:"key_file" toml:"key_file"`
+	// ReloadInterval 为检查证书文件变更的间隔，变更后自动加载新证书
+	ReloadInterval Duration `yaml:"reload_interval" toml:"reload_interval"`
+}
+
+// Enabled 判断是否启用了 HTTPS
+func (t *TLSConfig) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != ""
+}
+
+// DouYinConfig 为抖音开放平台相关配置
+type DouYinConfig struct {
+	// ClientKey、ClientSecret 为空时透传钉钉侧传入的 client_id、client_secret
+	ClientKey    string `yaml:"client_key" toml:"client_key"`
+	ClientSecret string `yaml:"client_secret" toml:"client_secret"`
+	// RedirectUris 为未配置 apps 时允许的客户端回调地址，授权请求的 redirect_uri 需与其中之一完全一致，为空时拒绝全部授权请求
+	RedirectUris []string `yaml:"redirect_uris" toml:"redirect_uris"`
+	// OpenApiBaseUrl 为抖音开放平台 API 的地址
+	OpenApiBaseUrl string `yaml:"open_api_base_url" toml:"open_api_base_url"`
+	// Timeout 为单次调用抖音开放平台 API 的超时
+	Timeout Duration `yaml:"timeout" toml:"timeout"`
+	// EndpointTimeouts 按 API 路径（如 /oauth/access_token/）单独设置超时
+	EndpointTimeouts map[string]Duration  `yaml:"endpoint_timeouts" toml:"endpoint_timeouts"`
+	Retry            RetryConfig          `yaml:"retry" toml:"retry"`
+	CircuitBreaker   CircuitBreakerConfig `yaml:"circuit_breaker" toml:"circuit_breaker"`
+}
+
+// DouYinAppConfig 为一个抖音开放平台应用，Token、client_token 及限流按应用隔离
+type DouYinAppConfig struct {
+	// ID 为应用标识，用于 /apps/{id}/openapi.yaml
+	ID string `yaml:"id" toml:"id"`
+	// ClientID、ClientSecret 为钉钉侧配置的客户端凭证，ClientSecret 为空时与 DouYinClientSecret 相同
+	ClientID     string `yaml:"client_id" toml:"client_id"`
+	ClientSecret string `yaml:"client_secret" toml:"client_secret"`
+	// DouYinClientKey、DouYinClientSecret 为抖音开放平台的应用凭证
+	DouYinClientKey    string `yaml:"douyin_client_key" toml:"douyin_client_key"`
+	DouYinClientSecret string `yaml:"douyin_client_secret" toml:"douyin_client_secret"`
+	// RedirectUris 为该应用允许的客户端回调地址，授权请求的 redirect_uri 需与其中之一完全一致
+	RedirectUris []string `yaml:"redirect_uris" toml:"redirect_uris"`
+	// Scopes 为该应用开通的授权范围，为空时可使用全部授权范围
+	Scopes []string `yaml:"scopes" toml:"scopes"`
+	// Namespace 为该应用的 Token 存储命名空间，为空时与 ID 相同
+	Namespace string `yaml:"namespace" toml:"namespace"`
+}
+
+// RetryConfig 为幂等的 GET 请求在抖音暂时不可用时的重试配置
+type RetryConfig struct {
+	// MaxAttempts 为包含首次请求在内的最大尝试次数，为 1 时不重试
+	MaxAttempts int      `yaml:"max_attempts" toml:"max_attempts"`
+	BaseDelay   Duration `yaml:"base_delay" toml:"base_delay"`
+	MaxDelay    Duration `yaml:"max_delay" toml:"max_delay"`
+}
+
+// CircuitBreakerConfig 为抖音 API 的熔断配置，每个 API 路径独立熔断
+type CircuitBreakerConfig struct {
+	// FailureThreshold 为触发熔断的连续失败次数
+	FailureThreshold int `yaml:"failure_threshold" toml:"failure_threshold"`
+	// OpenDuration 为熔断持续时间，结束后放行一个探测请求
+	OpenDuration Duration `yaml:"open_duration" toml:"open_duration"`
+}
+
+// MetricsConfig 为 Prometheus 指标配置
+type MetricsConfig struct {
+	Enabled bool   `yaml:"enabled" toml:"enabled"`
+	Path    string `yaml:"path" toml:"path"`
+}
+
+// TracingConfig 为 OpenTelemetry 链路追踪配置，span 通过 OTLP/HTTP 导出
+type TracingConfig struct {
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+	// Endpoint 为 OTLP/HTTP 接收端的 host:port
+	Endpoint string `yaml:"endpoint" toml:"endpoint"`
+	// Insecure 为 true 时以 HTTP 而非 HTTPS 导出，适用于本机的 collector
+	Insecure    bool    `yaml:"insecure" toml:"insecure"`
+	ServiceName string  `yaml:"service_name" toml:"service_name"`
+	SampleRatio float64 `yaml:"sample_ratio" toml:"sample_ratio"`
+}
+
+// RateLimitConfig 为按抖音用户及按抖音 API 路径的限流配置
+type RateLimitConfig struct {
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+	// PerUser 为每个抖音用户（open_id）调用业务动作的配额
+	PerUser QuotaConfig `yaml:"per_user" toml:"per_user"`
+	// PerEndpoint 为整个应用调用每个抖音 API 的配额
+	PerEndpoint QuotaConfig `yaml:"per_endpoint" toml:"per_endpoint"`
+	// Endpoints 按抖音 API 路径覆盖 PerEndpoint
+	Endpoints map[string]QuotaConfig `yaml:"endpoints" toml:"endpoints"`
+	// QuotaBackoff 为抖音返回配额耗尽或 429 后暂停调用该 API 的时间
+	QuotaBackoff Duration `yaml:"quota_backoff" toml:"quota_backoff"`
+}
+
+// QuotaConfig 为令牌桶配额：每秒补充 rate 个令牌，最多累积 burst 个；daily 为每日上限，为 0 时不限制
+type QuotaConfig struct {
+	Rate  float64 `yaml:"rate" toml:"rate"`
+	Burst int     `yaml:"burst" toml:"burst"`
+	Daily int     `yaml:"daily" toml:"daily"`
+}
+
+func (q *QuotaConfig) validate(name string) error {
+	if q.Rate <= 0 || q.Burst < 1 || q.Daily < 0 {
+		return errors.Errorf("invalid %s, expect rate > 0, burst >= 1 and daily >= 0", name)
+	}
+	return nil
+}
+
+// CacheConfig 为响应缓存配置，缓存按 (open_id, 抖音 API 路径, 参数) 区分，
+// 请求头 Cache-Control: no-cache 可跳过缓存
+type CacheConfig struct {
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+	// Backend 为缓存后端，内置 memory，其他后端需通过 cache.Register 注册
+	Backend string `yaml:"backend" toml:"backend"`
+	// Capacity 为 memory 后端最多缓存的响应数
+	Capacity int `yaml:"capacity" toml:"capacity"`
+	// DefaultTTL 为未单独配置的抖音 API 的缓存时间
+	DefaultTTL Duration `yaml:"default_ttl" toml:"default_ttl"`
+	// TTLs 按抖音 API 路径设置缓存时间
+	TTLs map[string]Duration `yaml:"ttls" toml:"ttls"`
+}
+
+// StorageConfig 为 Token 存储配置
+type StorageConfig struct {
+	// Backend 可选 memory、file
+	Backend string `yaml:"backend" toml:"backend"`
+	// Path 为 file 存储的文件路径
+	Path string `yaml:"path" toml:"path"`
+	// JanitorInterval 为清理过期 Token 及以 RefreshToken 刷新已过期 Token 的间隔，清理时同时将旧主密钥加密的 Token 以当前主密钥重新加密
+	JanitorInterval Duration `yaml:"janitor_interval" toml:"janitor_interval"`
+	// Encryption 为 file 存储中 access_token、refresh_token 的加密配置
+	Encryption EncryptionConfig `yaml:"encryption" toml:"encryption"`
+	// AccessLog 为账号访问记录的保留配置
+	AccessLog AccessLogConfig `yaml:"access_log" toml:"access_log"`
+}
+
+// AccessLogConfig 为账号访问记录的保留配置，memory 存储保留最近的记录，file 存储按大小轮转
+type AccessLogConfig struct {
+	// MaxRecords 为 memory 存储保留的最近访问记录数，超出时丢弃最早的记录
+	MaxRecords int `yaml:"max_records" toml:"max_records"`
+	// MaxSizeMB 为 file 存储单个访问记录文件的最大大小，超过后轮转
+	MaxSizeMB int `yaml:"max_size_mb" toml:"max_size_mb"`
+	// MaxBackups 为 file 存储保留的轮转文件数，为 0 时全部保留
+	MaxBackups int `yaml:"max_backups" toml:"max_backups"`
+}
+
+// EncryptionConfig 为 Token 的信封加密配置：每个 Token 使用独立的数据密钥加密，数据密钥由主密钥加密后与密文一同存储
+type EncryptionConfig struct {
+	// Keys 为主密钥，第一个用于加密新的数据密钥，其余仅用于解密，便于轮换；为空时不加密
+	Keys []EncryptionKeyConfig `yaml:"keys" toml:"keys"`
+}
+
+// EncryptionKeyConfig 为一个主密钥，ID 与密文一同存储，用于解密时选择主密钥
+type EncryptionKeyConfig struct {
+	ID string `yaml:"id" toml:"id"`
+	// Key 为 base64 编码的 32 字节 AES 密钥
+	Key string `yaml:"key" toml:"key"`
+}
+
+// StateConfig 为 OAuth state 参数的加密配置
+type StateConfig struct {
+	// Keys 为 base64 编码的 AES 密钥，第一个用于加密，其余仅用于解密，便于轮换
+	Keys []string `yaml:"keys" toml:"keys"`
+}
+
+// AccountsConfig 为钉钉用户关联多个抖音账号的配置
+type AccountsConfig struct {
+	// UserHeader 为可信网关传入当前钉钉用户标识的请求头，为空时不关联账号，每个 Token 只能访问自身的抖音账号
+	UserHeader string `yaml:"user_header" toml:"user_header"`
+	// TrustedProxies 为可信网关的 IP 或 CIDR，配置后只信任直接来自这些地址的请求中的 user_header
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies"`
+	// GatewaySecret 为可信网关在 X-Gateway-Secret 请求头中传入的共享密钥，配置后只信任携带该密钥的请求中的 user_header
+	GatewaySecret string `yaml:"gateway_secret" toml:"gateway_secret"`
+}
+
+// TrustedNetworks 返回解析后的可信网关地址，单个 IP 视为只包含该地址的网段
+func (a *AccountsConfig) TrustedNetworks() ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(a.TrustedProxies))
+	for i, proxy := range a.TrustedProxies {
+		if ip := net.ParseIP(proxy); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, errors.Errorf("invalid accounts.trusted_proxies[%d] %q, expect an IP or CIDR", i, proxy)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func (a *AccountsConfig) validate() error {
+	if _, err := a.TrustedNetworks(); err != nil {
+		return err
+	}
+	if a.GatewaySecret != "" && len(a.GatewaySecret) < minAdminTokenLength {
+		return errors.Errorf("accounts.gateway_secret must be at least %d characters", minAdminTokenLength)
+	}
+	// 任何客户端都可以设置请求头，未限定可信网关时无法区分伪造的钉钉用户标识
+	if a.UserHeader != "" && len(a.TrustedProxies) == 0 && a.GatewaySecret == "" {
+		return errors.New("accounts.user_header requires accounts.trusted_proxies or accounts.gateway_secret")
+	}
+	return nil
+}
+
+// AdminConfig 为 /admin 管理接口的配置，管理接口使用独立于用户 Token 的凭证
+type AdminConfig struct {
+	// Token 为访问管理接口的 Bearer 凭证，为空时不提供管理接口及控制台
+	Token string `yaml:"token" toml:"token"`
+}
+
+// AuditConfig 为审计日志配置，每次调用业务动作都会追加一条记录
+type AuditConfig struct {
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+	// Sink 为审计日志的写入目标，内置 file（JSON Lines 文件），其他目标需通过 audit.Register 注册
+	Sink string `yaml:"sink" toml:"sink"`
+	// Path 为 file 目标的文件路径，轮转后的文件以轮转时间为后缀
+	Path string `yaml:"path" toml:"path"`
+	// MaxSizeMB 为单个文件的最大大小，超过后轮转
+	MaxSizeMB int `yaml:"max_size_mb" toml:"max_size_mb"`
+	// MaxBackups 为保留的轮转文件数，为 0 时全部保留
+	MaxBackups int `yaml:"max_backups" toml:"max_backups"`
+}
+
+// minAdminTokenLength 为管理凭证及网关共享密钥的最小长度，避免使用容易猜测的凭证
+const minAdminTokenLength = 16
+
+// Duration 支持在配置文件中以 "10s"、"1m30s" 的形式书写时长
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+const redacted = "******"
+
+// Default 返回默认配置
+func Default() *Config {
+	return &Config{
+		Listen: ":3021",
+		Server: ServerConfig{
+			Network:           "tcp",
+			ReadHeaderTimeout: Duration(10 * time.Second),
+			ReadTimeout:       Duration(30 * time.Second),
+			WriteTimeout:      Duration(60 * time.Second),
+			IdleTimeout:       Duration(120 * time.Second),
+			KeepAlive:         Duration(3 * time.Minute),
+			TLS: TLSConfig{
+				ReloadInterval: Duration(time.Minute),
+			},
+		},
+		ShutdownTimeout: Duration(15 * time.Second),
+		LogLevel:        "info",
+		DouYin: DouYinConfig{
+			OpenApiBaseUrl: "https://open.douyin.com",
+			Timeout:        Duration(10 * time.Second),
+			Retry: RetryConfig{
+				MaxAttempts: 3,
+				BaseDelay:   Duration(200 * time.Millisecond),
+				MaxDelay:    Duration(2 * time.Second),
+			},
+			CircuitBreaker: CircuitBreakerConfig{
+				FailureThreshold: 5,
+				OpenDuration:     Duration(30 * time.Second),
+			},
+		},
+		Metrics: MetricsConfig{
+			Enabled: true,
+			Path:    "/metrics",
+		},
+		Tracing: TracingConfig{
+			Endpoint:    "localhost:4318",
+			Insecure:    true,
+			ServiceName: "douyin-action-example",
+			SampleRatio: 1,
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:      true,
+			PerUser:      QuotaConfig{Rate: 2, Burst: 10},
+			PerEndpoint:  QuotaConfig{Rate: 20, Burst: 40},
+			QuotaBackoff: Duration(time.Minute),
+		},
+		Cache: CacheConfig{
+			Enabled:    true,
+			Backend:    "memory",
+			Capacity:   10000,
+			DefaultTTL: Duration(time.Minute),
+			TTLs: map[string]Duration{
+				// 粉丝画像每天更新一次，视频统计变化较快
+				"/api/douyin/v1/user/fans_data/":   Duration(6 * time.Hour),
+				"/api/douyin/v1/video/video_list/": Duration(time.Minute),
+				"/oauth/userinfo/":                 Duration(10 * time.Minute),
+			},
+		},
+		Storage: StorageConfig{
+			Backend:         "memory",
+			JanitorInterval: Duration(10 * time.Minute),
+			AccessLog: AccessLogConfig{
+				MaxRecords: 10000,
+				MaxSizeMB:  16,
+				MaxBackups: 10,
+			},
+		},
+		Audit: AuditConfig{
+			Sink:      "file",
+			MaxSizeMB: 100,
+		},
+	}
+}
+
+// Load 加载配置：默认值 <- 配置文件（path 为空时跳过） <- 环境变量，规范化后校验结果
+func Load(path string) (*Config, error) {
+	config := Default()
+	if path != "" {
+		if err := config.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := config.loadEnv(); err != nil {
+		return nil, err
+	}
+	config.Normalize()
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "read config file")
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		err = decoder.Decode(c)
+	case ".toml":
+		decoder := toml.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(c)
+	default:
+		return errors.Errorf("unsupported config file %s, expect .yaml, .yml or .toml", path)
+	}
+	return errors.Wrapf(err, "parse config file %s", path)
+}
+
+// loadEnv 使用环境变量覆盖配置，便于容器化部署时注入密钥
+func (c *Config) loadEnv() error {
+	overrides := []struct {
+		name  string
+		apply func(string) error
+	}{
+		{"LISTEN_ADDRESS", setString(&c.Listen)},
+		{"PUBLIC_BASE_URL", setString(&c.PublicBaseUrl)},
+		{"TRUST_FORWARDED_HEADERS", setBool(&c.TrustForwardedHeaders)},
+		{"SERVER_NETWORK", setString(&c.Server.Network)},
+		{"SERVER_READ_HEADER_TIMEOUT", setDuration(&c.Server.ReadHeaderTimeout)},
+		{"SERVER_READ_TIMEOUT", setDuration(&c.Server.ReadTimeout)},
+		{"SERVER_WRITE_TIMEOUT", setDuration(&c.Server.WriteTimeout)},
+		{"SERVER_IDLE_TIMEOUT", setDuration(&c.Server.IdleTimeout)},
+		{"SERVER_KEEP_ALIVE", setDuration(&c.Server.KeepAlive)},
+		{"TLS_CERT_FILE", setString(&c.Server.TLS.CertFile)},
+		{"TLS_KEY_FILE", setString(&c.Server.TLS.KeyFile)},
+		{"TLS_RELOAD_INTERVAL", setDuration(&c.Server.TLS.ReloadInterval)},
+		{"SHUTDOWN_TIMEOUT", setDuration(&c.ShutdownTimeout)},
+		{"LOG_LEVEL", setString(&c.LogLevel)},
+		{"DOUYIN_CLIENT_KEY", setString(&c.DouYin.ClientKey)},
+		{"DOUYIN_CLIENT_SECRET", setString(&c.DouYin.ClientSecret)},
+		{"DOUYIN_REDIRECT_URIS", setList(&c.DouYin.RedirectUris)},
+		{"DOUYIN_OPEN_API_BASE_URL", setString(&c.DouYin.OpenApiBaseUrl)},
+		{"DOUYIN_TIMEOUT", setDuration(&c.DouYin.Timeout)},
+		{"DOUYIN_RETRY_MAX_ATTEMPTS", setInt(&c.DouYin.Retry.MaxAttempts)},
+		{"DOUYIN_CIRCUIT_BREAKER_FAILURE_THRESHOLD", setInt(&c.DouYin.CircuitBreaker.FailureThreshold)},
+		{"DOUYIN_CIRCUIT_BREAKER_OPEN_DURATION", setDuration(&c.DouYin.CircuitBreaker.OpenDuration)},
+		{"METRICS_ENABLED", setBool(&c.Metrics.Enabled)},
+		{"METRICS_PATH", setString(&c.Metrics.Path)},
+		{"TRACING_ENABLED", setBool(&c.Tracing.Enabled)},
+		{"TRACING_ENDPOINT", setString(&c.Tracing.Endpoint)},
+		{"TRACING_INSECURE", setBool(&c.Tracing.Insecure)},
+		{"TRACING_SAMPLE_RATIO", setFloat(&c.Tracing.SampleRatio)},
+		{"RATE_LIMIT_ENABLED", setBool(&c.RateLimit.Enabled)},
+		{"CACHE_ENABLED", setBool(&c.Cache.Enabled)},
+		{"CACHE_BACKEND", setString(&c.Cache.Backend)},
+		{"STORAGE_BACKEND", setString(&c.Storage.Backend)},
+		{"STORAGE_PATH", setString(&c.Storage.Path)},
+		{"STORAGE_JANITOR_INTERVAL", setDuration(&c.Storage.JanitorInterval)},
+		{"STORAGE_ENCRYPTION_KEYS", setEncryptionKeys(&c.Storage.Encryption.Keys)},
+		{"STATE_KEYS", setList(&c.State.Keys)},
+		{"ACCOUNTS_USER_HEADER", setString(&c.Accounts.UserHeader)},
+		{"ACCOUNTS_TRUSTED_PROXIES", setList(&c.Accounts.TrustedProxies)},
+		{"ACCOUNTS_GATEWAY_SECRET", setString(&c.Accounts.GatewaySecret)},
+		{"ADMIN_TOKEN", setString(&c.Admin.Token)},
+		{"AUDIT_ENABLED", setBool(&c.Audit.Enabled)},
+		{"AUDIT_SINK", setString(&c.Audit.Sink)},
+		{"AUDIT_PATH", setString(&c.Audit.Path)},
+	}
+	for _, o := range overrides {
+		value, ok := os.LookupEnv(o.name)
+		if !ok {
+			continue
+		}
+		if err := o.apply(value); err != nil {
+			return errors.Wrapf(err, "invalid environment variable %s", o.name)
+		}
+	}
+	// 兼容仅通过 DEBUG 环境变量开启调试模式的部署方式
+	if isEnvEnabled("DEBUG") {
+		c.LogLevel = "debug"
+	}
+	return nil
+}
+
+// Normalize 规范化配置：去掉地址末尾的 /，未指定命名空间的应用以应用标识作为命名空间
+func (c *Config) Normalize() {
+	c.PublicBaseUrl = strings.TrimRight(c.PublicBaseUrl, "/")
+	c.DouYin.OpenApiBaseUrl = strings.TrimRight(c.DouYin.OpenApiBaseUrl, "/")
+	for i := range c.Apps {
+		if c.Apps[i].Namespace == "" {
+			c.Apps[i].Namespace = c.Apps[i].ID
+		}
+	}
+}
+
+// Validate 校验配置的合法性，不修改配置，需在 Normalize 之后调用
+func (c *Config) Validate() error {
+	if c.Listen == "" {
+		return errors.New("listen must not be empty")
+	}
+	if c.PublicBaseUrl != "" {
+		if err := validateBaseUrl(c.PublicBaseUrl); err != nil {
+			return errors.Wrap(err, "invalid public_base_url")
+		}
+	}
+	if err := c.Server.validate(); err != nil {
+		return err
+	}
+	if c.ShutdownTimeout <= 0 {
+		return errors.New("shutdown_timeout must be positive")
+	}
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		return errors.Errorf("invalid log_level %q, expect debug, info, warn or error", c.LogLevel)
+	}
+	if err := validateBaseUrl(c.DouYin.OpenApiBaseUrl); err != nil {
+		return errors.Wrap(err, "invalid douyin.open_api_base_url")
+	}
+	if err := validateRedirectUris("douyin.redirect_uris", c.DouYin.RedirectUris); err != nil {
+		return err
+	}
+	if c.DouYin.ClientSecret != "" && c.DouYin.ClientKey == "" {
+		return errors.New("douyin.client_secret is set but douyin.client_key is empty")
+	}
+	if c.DouYin.Timeout <= 0 {
+		return errors.New("douyin.timeout must be positive")
+	}
+	for endpoint, timeout := range c.DouYin.EndpointTimeouts {
+		if !strings.HasPrefix(endpoint, "/") || timeout <= 0 {
+			return errors.Errorf("invalid douyin.endpoint_timeouts entry %q: %s, expect a path and a positive timeout",
+				endpoint, time.Duration(timeout))
+		}
+	}
+	if c.DouYin.Retry.MaxAttempts < 1 || c.DouYin.Retry.BaseDelay <= 0 || c.DouYin.Retry.MaxDelay < c.DouYin.Retry.BaseDelay {
+		return errors.New("douyin.retry requires max_attempts >= 1 and 0 < base_delay <= max_delay")
+	}
+	if c.DouYin.CircuitBreaker.FailureThreshold < 1 || c.DouYin.CircuitBreaker.OpenDuration <= 0 {
+		return errors.New("douyin.circuit_breaker requires failure_threshold >= 1 and a positive open_duration")
+	}
+	if err := c.validateApps(); err != nil {
+		return err
+	}
+	if c.Metrics.Enabled && !strings.HasPrefix(c.Metrics.Path, "/") {
+		return errors.Errorf("invalid metrics.path %q, must start with /", c.Metrics.Path)
+	}
+	if c.Tracing.Enabled && (c.Tracing.Endpoint == "" || c.Tracing.ServiceName == "") {
+		return errors.New("tracing.endpoint and tracing.service_name are required when tracing is enabled")
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		return errors.Errorf("invalid tracing.sample_ratio %v, expect a value in [0, 1]", c.Tracing.SampleRatio)
+	}
+	if err := c.RateLimit.validate(); err != nil {
+		return err
+	}
+	if c.Cache.Enabled {
+		if c.Cache.Backend == "" || c.Cache.Capacity < 1 || c.Cache.DefaultTTL <= 0 {
+			return errors.New("cache requires a backend, capacity >= 1 and a positive default_ttl")
+		}
+		for endpoint, ttl := range c.Cache.TTLs {
+			if ttl <= 0 {
+				return errors.Errorf("invalid cache.ttls[%s], ttl must be positive", endpoint)
+			}
+		}
+	}
+	switch c.Storage.Backend {
+	case "memory":
+	case "file":
+		if c.Storage.Path == "" {
+			return errors.New("storage.path is required for file backend")
+		}
+	default:
+		return errors.Errorf("invalid storage.backend %q, expect memory or file", c.Storage.Backend)
+	}
+	if c.Storage.JanitorInterval <= 0 {
+		return errors.New("storage.janitor_interval must be positive")
+	}
+	if accessLog := c.Storage.AccessLog; accessLog.MaxRecords < 1 || accessLog.MaxSizeMB < 1 || accessLog.MaxBackups < 0 {
+		return errors.New("storage.access_log requires max_records >= 1, max_size_mb >= 1 and max_backups >= 0")
+	}
+	if _, err := c.Storage.Encryption.DecodedKeys(); err != nil {
+		return err
+	}
+	if _, err := c.State.DecodedKeys(); err != nil {
+		return err
+	}
+	if err := c.Accounts.validate(); err != nil {
+		return err
+	}
+	if c.Audit.Enabled {
+		if c.Audit.Sink == "" || c.Audit.MaxSizeMB < 1 || c.Audit.MaxBackups < 0 {
+			return errors.New("audit requires a sink, max_size_mb >= 1 and max_backups >= 0")
+		}
+		if c.Audit.Sink == "file" && c.Audit.Path == "" {
+			return errors.New("audit.path is required for file sink")
+		}
+	}
+	if c.Admin.Token != "" && len(c.Admin.Token) < minAdminTokenLength {
+		return errors.Errorf("admin.token must be at least %d characters", minAdminTokenLength)
+	}
+	return nil
+}
+
+func (r *RateLimitConfig) validate() error {
+	if !r.Enabled {
+		return nil
+	}
+	if err := r.PerUser.validate("rate_limit.per_user"); err != nil {
+		return err
+	}
+	if err := r.PerEndpoint.validate("rate_limit.per_endpoint"); err != nil {
+		return err
+	}
+	for endpoint, quota := range r.Endpoints {
+		if err := quota.validate(fmt.Sprintf("rate_limit.endpoints[%s]", endpoint)); err != nil {
+			return err
+		}
+	}
+	if r.QuotaBackoff <= 0 {
+		return errors.New("rate_limit.quota_backoff must be positive")
+	}
+	return nil
+}
+
+func (s *ServerConfig) validate() error {
+	switch s.Network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return errors.Errorf("invalid server.network %q, expect tcp, tcp4 or tcp6", s.Network)
+	}
+	if s.ReadHeaderTimeout < 0 || s.ReadTimeout < 0 || s.WriteTimeout < 0 || s.IdleTimeout < 0 {
+		return errors.New("server timeouts must not be negative")
+	}
+	if (s.TLS.CertFile == "") != (s.TLS.KeyFile == "") {
+		return errors.New("server.tls.cert_file and server.tls.key_file must be set together")
+	}
+	if s.TLS.Enabled() && s.TLS.ReloadInterval <= 0 {
+		return errors.New("server.tls.reload_interval must be positive")
+	}
+	return nil
+}
+
+// encryptionKeyIDPattern 限制主密钥 ID 的字符，ID 会与密文一同存储
+var encryptionKeyIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// DecodedKeys 返回解码后的主密钥，顺序与配置一致
+func (e *EncryptionConfig) DecodedKeys() ([][]byte, error) {
+	ids := make(map[string]bool, len(e.Keys))
+	keys := make([][]byte, 0, len(e.Keys))
+	for i, k := range e.Keys {
+		if !encryptionKeyIDPattern.MatchString(k.ID) {
+			return nil, errors.Errorf("invalid storage.encryption.keys[%d].id %q, expect letters, digits, ., - or _", i, k.ID)
+		}
+		if ids[k.ID] {
+			return nil, errors.Errorf("duplicate storage.encryption.keys id %q", k.ID)
+		}
+		ids[k.ID] = true
+		key, err := base64.StdEncoding.DecodeString(k.Key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "storage.encryption.keys[%d].key is not valid base64", i)
+		}
+		if len(key) != 32 {
+			return nil, errors.Errorf("storage.encryption.keys[%d].key must be 32 bytes, got %d", i, len(key))
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// DecodedKeys 返回解码后的 state 加密密钥
+func (s *StateConfig) DecodedKeys() ([][]byte, error) {
+	keys := make([][]byte, 0, len(s.Keys))
+	for i, encoded := range s.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, errors.Wrapf(err, "state.keys[%d] is not valid base64", i)
+		}
+		if len(key) != 16 && len(key) != 24 && len(key) != 32 {
+			return nil, errors.Errorf("state.keys[%d] must be 16, 24 or 32 bytes, got %d", i, len(key))
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Redacted 返回隐藏了密钥等敏感信息的配置副本，用于打印
+func (c *Config) Redacted() *Config {
+	copied := *c
+	if copied.DouYin.ClientSecret != "" {
+		copied.DouYin.ClientSecret = redacted
+	}
+	copied.Apps = make([]DouYinAppConfig, len(c.Apps))
+	for i, app := range c.Apps {
+		if app.ClientSecret != "" {
+			app.ClientSecret = redacted
+		}
+		if app.DouYinClientSecret != "" {
+			app.DouYinClientSecret = redacted
+		}
+		copied.Apps[i] = app
+	}
+	if copied.Admin.Token != "" {
+		copied.Admin.Token = redacted
+	}
+	if copied.Accounts.GatewaySecret != "" {
+		copied.Accounts.GatewaySecret = redacted
+	}
+	copied.Storage.Encryption.Keys = make([]EncryptionKeyConfig, len(c.Storage.Encryption.Keys))
+	for i, k := range c.Storage.Encryption.Keys {
+		copied.Storage.Encryption.Keys[i] = EncryptionKeyConfig{ID: k.ID, Key: redacted}
+	}
+	copied.State.Keys = make([]string, len(c.State.Keys))
+	for i := range c.State.Keys {
+		copied.State.Keys[i] = redacted
+	}
+	return &copied
+}
+
+// String 以 YAML 格式输出隐藏了敏感信息的配置
+func (c *Config) String() string {
+	b, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return fmt.Sprintf("<invalid config: %s>", err.Error())
+	}
+	return string(b)
+}
+
+// namespacePattern 限制命名空间的字符，命名空间会用于存储文件名
+var namespacePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+func (c *Config) validateApps() error {
+	ids := make(map[string]bool, len(c.Apps))
+	clientIds := make(map[string]bool, len(c.Apps))
+	namespaces := make(map[string]bool, len(c.Apps))
+	for i, app := range c.Apps {
+		switch {
+		case !namespacePattern.MatchString(app.ID):
+			return errors.Errorf("invalid apps[%d].id %q, expect lower case letters, digits, - or _", i, app.ID)
+		case !namespacePattern.MatchString(app.Namespace):
+			return errors.Errorf("invalid apps[%d].namespace %q, expect lower case letters, digits, - or _", i, app.Namespace)
+		case app.ClientID == "":
+			return errors.Errorf("apps[%d].client_id must not be empty", i)
+		case app.DouYinClientKey == "":
+			return errors.Errorf("apps[%d].douyin_client_key must not be empty", i)
+		case ids[app.ID]:
+			return errors.Errorf("duplicate apps id %q", app.ID)
+		case clientIds[app.ClientID]:
+			return errors.Errorf("duplicate apps client_id %q", app.ClientID)
+		case namespaces[app.Namespace]:
+			return errors.Errorf("duplicate apps namespace %q", app.Namespace)
+		case len(app.RedirectUris) == 0:
+			return errors.Errorf("apps[%d].redirect_uris must not be empty", i)
+		}
+		if err := validateRedirectUris(fmt.Sprintf("apps[%d].redirect_uris", i), app.RedirectUris); err != nil {
+			return err
+		}
+		ids[app.ID] = true
+		clientIds[app.ClientID] = true
+		namespaces[app.Namespace] = true
+	}
+	return nil
+}
+
+// validateRedirectUris 校验客户端回调地址为不含 fragment 的绝对地址，见 RFC 6749 3.1.2
+func validateRedirectUris(name string, redirectUris []string) error {
+	for i, redirectUri := range redirectUris {
+		u, err := url.Parse(redirectUri)
+		if err != nil || u.Scheme == "" || u.Host == "" || u.Fragment != "" {
+			return errors.Errorf("invalid %s[%d] %q, expect an absolute URL without fragment", name, i, redirectUri)
+		}
+	}
+	return nil
+}
+
+func validateBaseUrl(baseUrl string) error {
+	u, err := url.Parse(baseUrl)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.Errorf("%q, expect http(s)://host[:port][/path]", baseUrl)
+	}
+	return nil
+}
+
+func setString(p *string) func(string) error {
+	return func(v string) error {
+		*p = v
+		return nil
+	}
+}
+
+func setBool(p *bool) func(string) error {
+	return func(v string) error {
+		*p = isEnabled(v)
+		return nil
+	}
+}
+
+func setInt(p *int) func(string) error {
+	return func(v string) error {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		*p = i
+		return nil
+	}
+}
+
+func setFloat(p *float64) func(string) error {
+	return func(v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		*p = f
+		return nil
+	}
+}
+
+func setDuration(p *Duration) func(string) error {
+	return func(v string) error {
+		return p.UnmarshalText([]byte(v))
+	}
+}
+
+func setList(p *[]string) func(string) error {
+	return func(v string) error {
+		*p = nil
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*p = append(*p, item)
+			}
+		}
+		return nil
+	}
+}
+
+// setEncryptionKeys 解析以逗号分隔的 id:key 列表，如 k2:BASE64,k1:BASE64
+func setEncryptionKeys(p *[]EncryptionKeyConfig) func(string) error {
+	return func(v string) error {
+		*p = nil
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			id, key, ok := strings.Cut(item, ":")
+			if !ok {
+				return errors.New("invalid entry, expect id:base64")
+			}
+			*p = append(*p, EncryptionKeyConfig{ID: id, Key: key})
+		}
+		return nil
+	}
+}
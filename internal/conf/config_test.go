@@ -0,0 +1,111 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestValidateDoesNotModifyConfig(t *testing.T) {
+	config := Default()
+	config.PublicBaseUrl = "https://bridge.example.com/"
+	config.Apps = []DouYinAppConfig{{ID: "brand", ClientID: "brand-client", DouYinClientKey: "brand-key",
+		RedirectUris: []string{"https://client.example.com/cb"}}}
+	before := *config
+	before.Apps = append([]DouYinAppConfig(nil), config.Apps...)
+
+	// 未规范化的应用没有命名空间，校验失败但不应补全配置
+	if err := config.Validate(); err == nil {
+		t.Fatal("expect an error for an app without namespace before Normalize")
+	}
+	if !reflect.DeepEqual(&before, config) {
+		t.Fatalf("Validate modified the config:\n%+v\n%+v", before, *config)
+	}
+
+	config.Normalize()
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if config.PublicBaseUrl != "https://bridge.example.com" || config.Apps[0].Namespace != "brand" {
+		t.Fatalf("unexpected normalized config: public_base_url=%q namespace=%q", config.PublicBaseUrl, config.Apps[0].Namespace)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := []byte(`
+listen: ":8080"
+douyin:
+  open_api_base_url: https://open.example.com/
+apps:
+  - id: brand
+    client_id: brand-client
+    douyin_client_key: brand-key
+    redirect_uris: ["https://client.example.com/cb"]
+`)
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LISTEN_ADDRESS", ":9090")
+
+	config, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Listen != ":9090" {
+		t.Fatalf("listen = %q, want the environment override", config.Listen)
+	}
+	if config.DouYin.OpenApiBaseUrl != "https://open.example.com" || config.Apps[0].Namespace != "brand" {
+		t.Fatalf("config is not normalized: %+v", config)
+	}
+
+	if err := os.WriteFile(path, []byte("unknown_field: 1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Fatal("expect an error for an unknown field")
+	}
+}
+
+func TestValidateRedirectUris(t *testing.T) {
+	for _, redirectUris := range [][]string{nil, {"/cb"}, {"https://client.example.com/cb#fragment"}} {
+		config := Default()
+		config.Apps = []DouYinAppConfig{{ID: "brand", ClientID: "brand-client", DouYinClientKey: "brand-key", RedirectUris: redirectUris}}
+		config.Normalize()
+		if err := config.Validate(); err == nil {
+			t.Errorf("expect an error for apps[0].redirect_uris %q", redirectUris)
+		}
+	}
+	config := Default()
+	config.DouYin.RedirectUris = []string{"client.example.com/cb"}
+	if err := config.Validate(); err == nil {
+		t.Error("expect an error for a relative douyin.redirect_uris entry")
+	}
+}
+
+func TestValidateAccountsGateway(t *testing.T) {
+	for _, accounts := range []AccountsConfig{
+		{UserHeader: "X-Dingtalk-User"},
+		{UserHeader: "X-Dingtalk-User", TrustedProxies: []string{"10.0.0.0/33"}},
+		{UserHeader: "X-Dingtalk-User", GatewaySecret: "short"},
+	} {
+		config := Default()
+		config.Accounts = accounts
+		if err := config.Validate(); err == nil {
+			t.Errorf("expect an error for accounts %+v", accounts)
+		}
+	}
+	config := Default()
+	config.Accounts = AccountsConfig{UserHeader: "X-Dingtalk-User", TrustedProxies: []string{"10.0.0.0/8", "192.0.2.1", "::1"}}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	networks, err := config.Accounts.TrustedNetworks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(networks) != 3 || networks[1].String() != "192.0.2.1/32" || networks[2].String() != "::1/128" {
+		t.Errorf("unexpected trusted networks %v", networks)
+	}
+}
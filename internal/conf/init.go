@@ -2,6 +2,8 @@ package conf
 
 import (
 	"github.com/chzealot/gobase/logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"os"
 	"strings"
 )
@@ -10,15 +12,54 @@ var AppConfig logger.Config
 
 var IsDebugMode = false
 
+// App 为当前生效的配置，启动时通过 Use 替换为加载的配置
+var App = Default()
+
 func init() {
 	AppConfig = logger.Config{
 		AppName:   "douyin-action-example",
 		DebugMode: logger.DebugModeFromEnv,
 	}
 
-	debug := strings.ToLower(os.Getenv("DEBUG"))
-	if debug == "true" || debug == "on" || debug == "enable" || debug == "1" {
-		IsDebugMode = true
+	IsDebugMode = isEnvEnabled("DEBUG")
+}
+
+// Use 将 config 设为当前生效的配置
+func Use(config *Config) {
+	App = config
+	IsDebugMode = config.LogLevel == "debug"
+}
+
+// InitLogger 按当前配置的日志级别初始化日志
+func InitLogger() error {
+	// gobase 的日志仅通过 DEBUG 环境变量开启调试级别
+	if IsDebugMode {
+		if err := os.Setenv("DEBUG", "1"); err != nil {
+			return err
+		}
 	}
+	if err := logger.InitWithConfig(AppConfig); err != nil {
+		return err
+	}
+	var level zapcore.Level
+	switch App.LogLevel {
+	case "warn":
+		level = zapcore.WarnLevel
+	case "error":
+		level = zapcore.ErrorLevel
+	default:
+		return nil
+	}
+	logger.DefaultLogger = logger.DefaultLogger.WithOptions(zap.IncreaseLevel(level))
+	logger.DefaultSugarLogger = logger.DefaultLogger.Sugar()
+	return nil
+}
+
+func isEnvEnabled(name string) bool {
+	return isEnabled(os.Getenv(name))
+}
 
+func isEnabled(value string) bool {
+	value = strings.ToLower(value)
+	return value == "true" || value == "on" || value == "enable" || value == "1"
 }
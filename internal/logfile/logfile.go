@@ -0,0 +1,124 @@
+// Package logfile 提供按大小轮转的只追加文件，审计日志及账号访问记录以 JSON Lines 写入
+package logfile
+
+import (
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatedTimeLayout 为轮转文件名中的时间后缀，按字典序排序即为时间顺序
+const rotatedTimeLayout = "20060102T150405.000000000Z"
+
+// Writer 追加写入 path，文件超过 maxSize 后重命名为 path.<轮转时间> 并写入新文件，实现并发安全
+type Writer struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// New 创建写入 path 的 Writer，文件在第一次写入时打开，maxBackups 为 0 时保留全部轮转文件
+func New(path string, maxSize int64, maxBackups int) *Writer {
+	return &Writer{path: path, maxSize: maxSize, maxBackups: maxBackups}
+}
+
+// Write 追加一行，line 不需要包含换行符
+func (w *Writer) Write(line []byte) error {
+	line = append(line[:len(line):len(line)], '\n')
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+	if w.size > 0 && w.size+int64(len(line)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := w.file.Write(line)
+	w.size += int64(n)
+	return errors.WithStack(err)
+}
+
+func (w *Writer) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.path), 0o700); err != nil {
+		return errors.WithStack(err)
+	}
+	file, err := os.OpenFile(w.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return errors.WithStack(err)
+	}
+	w.file, w.size = file, stat.Size()
+	return nil
+}
+
+// rotate 将当前文件重命名为轮转文件，打开新文件，并删除超出数量的最早的轮转文件
+func (w *Writer) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	w.file = nil
+	rotated := w.path + "." + time.Now().UTC().Format(rotatedTimeLayout)
+	if err := os.Rename(w.path, rotated); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	if w.maxBackups == 0 {
+		return nil
+	}
+	backups, err := Rotated(w.path)
+	if err != nil {
+		return err
+	}
+	for len(backups) > w.maxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return errors.WithStack(err)
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return errors.WithStack(err)
+}
+
+// Rotated 返回 path 的轮转文件，最早的在前
+func Rotated(path string) ([]string, error) {
+	candidates, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var files []string
+	for _, candidate := range candidates {
+		if _, err := time.Parse(rotatedTimeLayout, strings.TrimPrefix(candidate, path+".")); err == nil {
+			files = append(files, candidate)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
@@ -0,0 +1,65 @@
+package logfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriterRotates(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+	w := New(path, 64, 2)
+	t.Cleanup(func() { _ = w.Close() })
+	line := []byte(strings.Repeat("x", 39))
+	// 每个文件只能容纳一行，写入 5 行后轮转 4 次，只保留最近的 2 个轮转文件
+	for i := 0; i < 5; i++ {
+		if err := w.Write(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rotated, err := Rotated(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotated) != 2 {
+		t.Fatalf("rotated files = %v, want 2", rotated)
+	}
+	for _, file := range append(rotated, path) {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != string(line)+"\n" {
+			t.Errorf("%s = %q, want a single line", file, content)
+		}
+	}
+	// 与轮转时间格式不符的文件不视为轮转文件
+	if err := os.WriteFile(path+".bak", nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if rotated, _ := Rotated(path); len(rotated) != 2 {
+		t.Errorf("rotated files = %v, want unrelated files ignored", rotated)
+	}
+}
+
+func TestWriterAppendsToExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "access.jsonl")
+	for _, line := range []string{"a", "b"} {
+		w := New(path, 1<<20, 0)
+		if err := w.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "a\nb\n" {
+		t.Errorf("content = %q, want lines appended", content)
+	}
+}
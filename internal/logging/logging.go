@@ -0,0 +1,187 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/chzealot/gobase/logger"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RequestIDHeader 为请求 ID 的 HTTP 头，调用方传入时沿用，否则生成新的 ID
+const RequestIDHeader = "X-Request-Id"
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	fieldsKey
+)
+
+// requestFields 为请求处理过程中补充到访问日志的字段
+type requestFields struct {
+	openIdHash      string
+	upstreamErrCode string
+}
+
+// Install 为全局日志加上敏感信息隐藏，需在日志初始化之后调用
+func Install() {
+	logger.DefaultLogger = logger.DefaultLogger.WithOptions(zap.WrapCore(RedactingCore))
+	logger.DefaultSugarLogger = logger.DefaultLogger.Sugar()
+	// gobase 的日志为包装函数预留了一层调用栈，直接调用时需要去掉
+	directLogger = logger.DefaultLogger.WithOptions(zap.AddCallerSkip(-1)).Sugar()
+}
+
+var directLogger *zap.SugaredLogger
+
+// Middleware 为每个请求分配请求 ID，写入请求上下文及响应头，并在请求结束后输出结构化的访问日志
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" || len(requestID) > 128 {
+			requestID = newRequestID()
+		}
+		fields := &requestFields{}
+		ctx := context.WithValue(c.Request.Context(), requestIDKey, requestID)
+		ctx = context.WithValue(ctx, fieldsKey, fields)
+		c.Request = c.Request.WithContext(ctx)
+		c.Header(RequestIDHeader, requestID)
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		keysAndValues := []interface{}{
+			"method", c.Request.Method,
+			"route", route,
+			"status", c.Writer.Status(),
+			"latency_ms", time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+		}
+		if fields.openIdHash != "" {
+			keysAndValues = append(keysAndValues, "open_id_hash", fields.openIdHash)
+		}
+		if fields.upstreamErrCode != "" {
+			keysAndValues = append(keysAndValues, "upstream_error_code", fields.upstreamErrCode)
+		}
+		if len(c.Errors) > 0 {
+			keysAndValues = append(keysAndValues, "errors", c.Errors.String())
+		}
+		FromContext(ctx).Infow("http request", keysAndValues...)
+	}
+}
+
+// FromContext 返回带有请求 ID 的日志
+func FromContext(ctx context.Context) *zap.SugaredLogger {
+	l := directLogger
+	if l == nil {
+		l = logger.DefaultSugarLogger
+	}
+	if requestID := RequestID(ctx); requestID != "" {
+		l = l.With("request_id", requestID)
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		l = l.With("trace_id", spanContext.TraceID().String())
+	}
+	return l
+}
+
+// RequestID 返回上下文中的请求 ID，没有时返回空字符串
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// SetOpenID 记录当前请求对应的抖音用户，访问日志中仅输出其摘要
+func SetOpenID(ctx context.Context, openId string) {
+	if fields, ok := ctx.Value(fieldsKey).(*requestFields); ok {
+		fields.openIdHash = HashOpenID(openId)
+	}
+}
+
+// SetUpstreamErrorCode 记录当前请求调用抖音开放平台返回的业务错误码
+func SetUpstreamErrorCode(ctx context.Context, errorCode float64) {
+	if fields, ok := ctx.Value(fieldsKey).(*requestFields); ok {
+		fields.upstreamErrCode = fmt.Sprintf("%v", errorCode)
+	}
+}
+
+// HashOpenID 返回 open_id 的摘要，用于在日志中关联同一用户而不暴露其标识
+func HashOpenID(openId string) string {
+	if openId == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(openId))
+	return hex.EncodeToString(sum[:6])
+}
+
+// PropagateRequestID 将上下文中的请求 ID 透传给抖音开放平台，便于双方排查问题
+func PropagateRequestID(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if requestID := RequestID(r.Context()); requestID != "" && r.Header.Get(RequestIDHeader) == "" {
+			r = r.Clone(r.Context())
+			r.Header.Set(RequestIDHeader, requestID)
+		}
+		return next.RoundTrip(r)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// DumpRequest 以调试级别输出请求内容，隐藏认证头及敏感参数，并保留请求体供后续读取
+func DumpRequest(r *http.Request) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = io.ReadAll(r.Body)
+		if err != nil {
+			FromContext(r.Context()).Warnf("read request body failed, err=%s", err.Error())
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	headers := make([]string, 0, len(r.Header))
+	for name, values := range r.Header {
+		for _, value := range values {
+			if isSensitiveHeader(name) {
+				value = redacted
+			}
+			headers = append(headers, fmt.Sprintf("%s: %s", name, value))
+		}
+	}
+	sort.Strings(headers)
+	FromContext(r.Context()).Debugf("%s %s\nHost: %s\n%s\n\n%s",
+		r.Method, r.URL.String(), r.Host, strings.Join(headers, "\n"), string(body))
+}
+
+func isSensitiveHeader(name string) bool {
+	switch strings.ToLower(name) {
+	case "authorization", "proxy-authorization", "cookie", "access-token":
+		return true
+	}
+	return false
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
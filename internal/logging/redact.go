@@ -0,0 +1,106 @@
+package logging
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"regexp"
+)
+
+const redacted = "******"
+
+var (
+	// 匹配 key=value、"key":"value"、Key:value 等形式的敏感字段，如 access_token、client_secret、授权码 code
+	secretPairPattern = regexp.MustCompile(
+		`(?i)(\b(?:access[_-]?token|refresh[_-]?token|client[_-]?token|client[_-]?secret|code[_-]?verifier|code|token|authorization)\b["']?\s*[:=]\s*["']?)([^"'&\s,}\]]+)`)
+	bearerPattern = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9\-._~+/=:]+`)
+	// 匹配需要整体隐藏取值的参数名及日志字段名
+	sensitiveParamPattern = regexp.MustCompile(`(?i)(token|secret|password|verifier|authorization|^code$)`)
+)
+
+// Redact 隐藏字符串中的 Token、密钥及授权码
+func Redact(s string) string {
+	s = bearerPattern.ReplaceAllString(s, "$1 "+redacted)
+	return secretPairPattern.ReplaceAllString(s, "${1}"+redacted)
+}
+
+// RedactParams 返回隐藏了敏感参数的副本：参数名为 Token、密钥、授权码等时隐藏整个取值，其余字符串取值按 Redact 处理
+func RedactParams(params map[string]interface{}) map[string]interface{} {
+	redactedParams := make(map[string]interface{}, len(params))
+	for name, value := range params {
+		if sensitiveParamPattern.MatchString(name) {
+			redactedParams[name] = redacted
+			continue
+		}
+		redactedParams[name] = redactValue(value)
+	}
+	return redactedParams
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return Redact(v)
+	case map[string]interface{}:
+		return RedactParams(v)
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, item := range v {
+			values[i] = redactValue(item)
+		}
+		return values
+	}
+	return value
+}
+
+// RedactingCore 在日志写出前隐藏消息及字段中的敏感信息，保证任何日志调用都不会泄露凭证
+func RedactingCore(core zapcore.Core) zapcore.Core {
+	return &redactingCore{Core: core}
+}
+
+type redactingCore struct {
+	zapcore.Core
+}
+
+func (c *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactingCore{Core: c.Core.With(redactFields(fields))}
+}
+
+func (c *redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = Redact(entry.Message)
+	return c.Core.Write(entry, redactFields(fields))
+}
+
+// redactFields 返回隐藏了敏感信息的字段：字段名为 Token、密钥、授权码等时隐藏整个取值，如 zap.String("client_secret", s)，
+// 其余字符串、Stringer、error 及反射编码的字段按 Redact 处理
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	redactedFields := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		if field.Type != zapcore.NamespaceType && field.Type != zapcore.SkipType && sensitiveParamPattern.MatchString(field.Key) {
+			redactedFields[i] = zap.String(field.Key, redacted)
+			continue
+		}
+		switch field.Type {
+		case zapcore.StringType:
+			field.String = Redact(field.String)
+		case zapcore.StringerType, zapcore.ReflectType, zapcore.ErrorType:
+			field = zap.String(field.Key, Redact(fieldString(field)))
+		}
+		redactedFields[i] = field
+	}
+	return redactedFields
+}
+
+// fieldString 将非字符串字段编码为字符串，以便统一处理
+func fieldString(field zapcore.Field) string {
+	enc := zapcore.NewMapObjectEncoder()
+	field.AddTo(enc)
+	return fmt.Sprintf("%v", enc.Fields[field.Key])
+}
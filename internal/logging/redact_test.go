@@ -0,0 +1,117 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	for input, want := range map[string]string{
+		"token Bearer abc.def-123 is rejected":              "token Bearer ****** is rejected",
+		"GET /oauth/token?code=abc123&state=s1":             "GET /oauth/token?code=******&state=s1",
+		`{"access_token":"act.1","expires_in":7200}`:        `{"access_token":"******","expires_in":7200}`,
+		"refresh_token=rft.2 client_secret=sec open_id=o-1": "refresh_token=****** client_secret=****** open_id=o-1",
+		"code_verifier: v3rifier":                           "code_verifier: ******",
+		"request failed with status 500":                    "request failed with status 500",
+	} {
+		if got := Redact(input); got != want {
+			t.Errorf("Redact(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestRedactParams(t *testing.T) {
+	params := map[string]interface{}{
+		"access_token": "act.1",
+		"code":         "abc123",
+		"keyword":      "douyin",
+		"count":        10.0,
+		"body": map[string]interface{}{
+			"ClientSecret": "sec",
+			"note":         "sent with Bearer act.1",
+			"items":        []interface{}{"token=t1", map[string]interface{}{"password": "p"}},
+		},
+	}
+	want := map[string]interface{}{
+		"access_token": redacted,
+		"code":         redacted,
+		"keyword":      "douyin",
+		"count":        10.0,
+		"body": map[string]interface{}{
+			"ClientSecret": redacted,
+			"note":         "sent with Bearer " + redacted,
+			"items":        []interface{}{"token=" + redacted, map[string]interface{}{"password": redacted}},
+		},
+	}
+	if got := RedactParams(params); !reflect.DeepEqual(got, want) {
+		t.Errorf("RedactParams() = %v, want %v", got, want)
+	}
+	if params["access_token"] != "act.1" {
+		t.Error("RedactParams must not modify its input")
+	}
+}
+
+func TestRedactingCore(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	log := zap.New(RedactingCore(core)).With(zap.String("authorization", "Bearer act.1"))
+	log.Sugar().Infof("exchange code=%s", "abc123")
+	log.Error("refresh failed",
+		zap.Error(errors.New("douyin rejected refresh_token=rft.2")),
+		zap.Any("response", map[string]string{"access_token": "act.3"}))
+
+	for _, entry := range logs.All() {
+		line := entry.Message
+		for key, value := range entry.ContextMap() {
+			line += fmt.Sprintf(" %s=%v", key, value)
+		}
+		for _, secret := range []string{"act.1", "abc123", "rft.2", "act.3"} {
+			if strings.Contains(line, secret) {
+				t.Errorf("log entry leaks %q: %s", secret, line)
+			}
+		}
+	}
+	if logs.Len() != 2 {
+		t.Errorf("expect 2 log entries, got %d", logs.Len())
+	}
+}
+
+func TestRedactingCoreByFieldKey(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	log := zap.New(RedactingCore(core)).With(zap.String("client_secret", "s3cr3t"))
+	// 字段名为敏感名称时，无论取值是否形如 key=value 都整体隐藏
+	log.Info("token exchanged",
+		zap.String("access_token", "act.1"),
+		zap.ByteString("refreshToken", []byte("rft.2")),
+		zap.Stringer("Authorization", stringer("Bearer-less act.3")),
+		zap.Any("db_password", map[string]string{"value": "p4ss"}),
+		zap.String("open_id", "o-1"),
+		zap.Int("expires_in", 7200))
+
+	if logs.Len() != 1 {
+		t.Fatalf("expect 1 log entry, got %d", logs.Len())
+	}
+	want := map[string]interface{}{
+		"client_secret": redacted,
+		"access_token":  redacted,
+		"refreshToken":  redacted,
+		"Authorization": redacted,
+		"db_password":   redacted,
+		"open_id":       "o-1",
+		"expires_in":    int64(7200),
+	}
+	if got := logs.All()[0].ContextMap(); !reflect.DeepEqual(got, want) {
+		t.Errorf("fields = %v, want %v", got, want)
+	}
+}
+
+type stringer string
+
+func (s stringer) String() string {
+	return string(s)
+}
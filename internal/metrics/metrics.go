@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const namespace = "douyin_action"
+
+// OAuth 授权流程的各个阶段，用于统计授权漏斗
+const (
+	StageAuthorize     = "authorize"
+	StageCallback      = "callback"
+	StageCallbackError = "callback_error"
+	StageTokenSuccess  = "token_success"
+	StageTokenFailure  = "token_failure"
+)
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests handled, partitioned by route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests handled, partitioned by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	douYinRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "douyin_requests_total",
+		Help:      "Number of requests sent to the Douyin open platform, partitioned by endpoint and HTTP status code.",
+	}, []string{"endpoint", "status"})
+
+	douYinRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "douyin_request_duration_seconds",
+		Help:      "Latency of requests sent to the Douyin open platform, partitioned by endpoint.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"endpoint"})
+
+	douYinErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "douyin_errors_total",
+		Help:      "Number of business errors returned by the Douyin open platform, partitioned by endpoint and error_code.",
+	}, []string{"endpoint", "error_code"})
+
+	tokenExchanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "token_exchanges_total",
+		Help:      "Number of token endpoint calls, partitioned by grant_type and result, including refresh outcomes.",
+	}, []string{"grant_type", "result"})
+
+	cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Number of response cache lookups for Douyin read endpoints, partitioned by endpoint and result: hit, miss or bypass.",
+	}, []string{"endpoint", "result"})
+
+	oauthFlow = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "oauth_flow_total",
+		Help:      "Number of OAuth flow events, partitioned by stage: authorize -> callback -> token_success.",
+	}, []string{"stage"})
+)
+
+func init() {
+	prometheus.MustRegister(httpRequests, httpRequestDuration, douYinRequests, douYinRequestDuration,
+		douYinErrors, tokenExchanges, cacheLookups, oauthFlow)
+}
+
+// Handler 返回 Prometheus 指标的 HTTP 处理器
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// Middleware 统计每个路由的请求数及耗时，以路由模板作为标签以避免标签基数膨胀
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpRequestDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// RegisterTokenStoreSize 注册 Token 存储中的 Token 数量指标，size 在每次采集时调用
+func RegisterTokenStoreSize(size func() float64) {
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "token_store_size",
+		Help:      "Number of access tokens held by the token store.",
+	}, size))
+}
+
+// InstrumentRoundTripper 统计经由 next 发往抖音开放平台的请求数及耗时，以请求路径作为 endpoint 标签
+func InstrumentRoundTripper(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := next.RoundTrip(r)
+		status := "error"
+		if err == nil {
+			status = strconv.Itoa(resp.StatusCode)
+		}
+		douYinRequests.WithLabelValues(r.URL.Path, status).Inc()
+		douYinRequestDuration.WithLabelValues(r.URL.Path).Observe(time.Since(start).Seconds())
+		return resp, err
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// ObserveDouYinError 记录抖音开放平台返回的业务错误码
+func ObserveDouYinError(endpoint string, errorCode float64) {
+	douYinErrors.WithLabelValues(endpoint, strconv.FormatFloat(errorCode, 'f', -1, 64)).Inc()
+}
+
+// ObserveTokenExchange 记录换取 Token 的结果，result 为 success 或 failure
+func ObserveTokenExchange(grantType, result string) {
+	// grant_type 由调用方传入，归并未知取值以限制标签基数
+	if grantType != "authorization_code" && grantType != "refresh_token" {
+		grantType = "other"
+	}
+	tokenExchanges.WithLabelValues(grantType, result).Inc()
+}
+
+// ObserveCacheLookup 记录响应缓存的查询结果，result 为 hit、miss 或 bypass
+func ObserveCacheLookup(endpoint, result string) {
+	cacheLookups.WithLabelValues(endpoint, result).Inc()
+}
+
+// ObserveOAuthFlow 记录 OAuth 授权流程进入了 stage 阶段
+func ObserveOAuthFlow(stage string) {
+	oauthFlow.WithLabelValues(stage).Inc()
+}
@@ -0,0 +1,274 @@
+package openapi
+
+import (
+	"douyin-action-example/internal/actions/models"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// SecuritySchemeName 为业务动作使用的 OAuth 安全方案名
+const SecuritySchemeName = "douyinOAuth"
+
+// Endpoint 描述了一个需要写入文档的业务动作
+type Endpoint struct {
+	Method      string
+	Path        string
+	OperationID string
+	Summary     string
+	Description string
+	// Scope 为调用该动作所需的授权范围，为空时只需要有效的 Token
+	Scope string
+	// Query 为描述查询参数的结构体，字段以 form 标签命名、description 标签说明、enum 标签列出可选值，binding:"required" 表示必填
+	Query interface{}
+	// Body 为 JSON 请求体的结构体，字段以 json 标签命名、description 标签说明，binding:"required" 表示必填
+	Body interface{}
+	// Response 为成功响应的结构体，字段以 json 标签命名、description 标签说明
+	Response interface{}
+}
+
+// Generate 由业务动作及授权范围生成 OpenAPI 文档，serverUrl 为文档中的服务地址
+func Generate(info Info, serverUrl string, endpoints []*Endpoint) *Document {
+	g := &generator{schemas: make(map[string]*Schema)}
+	doc := &Document{
+		OpenAPI: "3.0.1",
+		Info:    info,
+		Servers: []Server{{URL: serverUrl}},
+		Paths:   NewMap[*PathItem](),
+	}
+	for _, endpoint := range endpoints {
+		item, ok := doc.Paths.Get(endpoint.Path)
+		if !ok {
+			item = &PathItem{}
+			doc.Paths.Set(endpoint.Path, item)
+		}
+		operation := g.operation(endpoint)
+		switch endpoint.Method {
+		case http.MethodGet:
+			item.Get = operation
+		case http.MethodPost:
+			item.Post = operation
+		default:
+			panic(fmt.Sprintf("openapi: unsupported method %s of %s", endpoint.Method, endpoint.Path))
+		}
+	}
+
+	scopes := NewMap[string]()
+	for _, scope := range models.ScopeCatalog {
+		scopes.Set(scope.Name, scope.Description)
+	}
+	doc.Components = Components{
+		SecuritySchemes: map[string]*SecurityScheme{
+			SecuritySchemeName: {
+				Type:        "oauth2",
+				Description: "通过抖音开放平台授权，授权服务器元数据见 /.well-known/oauth-authorization-server",
+				Flows: &OAuthFlows{AuthorizationCode: &OAuthFlow{
+					AuthorizationURL: serverUrl + "/auth/authorize",
+					TokenURL:         serverUrl + "/auth/token",
+					Scopes:           scopes,
+				}},
+			},
+		},
+		Schemas: g.schemas,
+	}
+	return doc
+}
+
+type generator struct {
+	schemas map[string]*Schema
+}
+
+func (g *generator) operation(endpoint *Endpoint) *Operation {
+	operation := &Operation{
+		Summary:     endpoint.Summary,
+		Description: endpoint.Description,
+		OperationID: endpoint.OperationID,
+		Parameters:  g.queryParameters(endpoint.Query),
+		Responses:   NewMap[*Response](),
+	}
+	if endpoint.Body != nil {
+		operation.RequestBody = &RequestBody{
+			Required: true,
+			Content: map[string]*MediaType{
+				"application/json": {Schema: g.schema(reflect.TypeOf(endpoint.Body))},
+			},
+		}
+	}
+	// 不需要授权范围的动作同样需要 Bearer Token
+	scopes := []string{}
+	if endpoint.Scope != "" {
+		scopes = append(scopes, endpoint.Scope)
+	}
+	operation.Security = []map[string][]string{{SecuritySchemeName: scopes}}
+	response := &Response{Description: "OK"}
+	if endpoint.Response != nil {
+		response.Content = map[string]*MediaType{
+			"application/json": {Schema: g.schema(reflect.TypeOf(endpoint.Response))},
+		}
+	}
+	operation.Responses.Set("200", response)
+	operation.Responses.Set("default", &Response{
+		Description: "错误，error_description 为可以转述给用户的说明",
+		Content: map[string]*MediaType{
+			"application/json": {Schema: g.schema(reflect.TypeOf(models.ServiceError{}))},
+		},
+	})
+	return operation
+}
+
+func (g *generator) queryParameters(query interface{}) []*Parameter {
+	if query == nil {
+		return nil
+	}
+	return g.typeParameters(reflect.TypeOf(query))
+}
+
+func (g *generator) typeParameters(t reflect.Type) []*Parameter {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var parameters []*Parameter
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		// 与 gin 的绑定一致，嵌入的结构体展开为同级参数
+		if field.Anonymous && field.Tag.Get("form") == "" {
+			parameters = append(parameters, g.typeParameters(field.Type)...)
+			continue
+		}
+		name := tagName(field.Tag.Get("form"))
+		if !field.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		parameters = append(parameters, &Parameter{
+			Name:        name,
+			In:          "query",
+			Description: field.Tag.Get("description"),
+			Required:    strings.Contains(field.Tag.Get("binding"), "required"),
+			Schema:      withEnum(g.schema(field.Type), field),
+		})
+	}
+	return parameters
+}
+
+// schema 返回类型的 JSON Schema，具名结构体写入 components.schemas 并以 $ref 引用
+func (g *generator) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object"}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		if _, ok := g.schemas[t.Name()]; !ok {
+			// 先占位，避免自引用的结构体无限递归
+			g.schemas[t.Name()] = &Schema{}
+			*g.schemas[t.Name()] = *g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + t.Name()}
+	default:
+		panic(fmt.Sprintf("openapi: unsupported type %s", t))
+	}
+}
+
+func (g *generator) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: NewMap[*Schema]()}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := tagName(field.Tag.Get("json"))
+		// 与 encoding/json 一致，嵌入的结构体展开为同级属性
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			embedded := g.structSchema(field.Type)
+			schema.Required = append(schema.Required, embedded.Required...)
+			for _, key := range embedded.Properties.Keys() {
+				property, _ := embedded.Properties.Get(key)
+				schema.Properties.Set(key, property)
+			}
+			continue
+		}
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if strings.Contains(field.Tag.Get("binding"), "required") {
+			schema.Required = append(schema.Required, name)
+		}
+		property := withEnum(g.schema(field.Type), field)
+		// $ref 的兄弟字段会被忽略，只为非引用的属性写入说明
+		if property.Ref == "" {
+			property.Description = field.Tag.Get("description")
+		}
+		schema.Properties.Set(name, property)
+	}
+	return schema
+}
+
+// withEnum 按 enum 标签写入以逗号分隔的可选值
+func withEnum(schema *Schema, field reflect.StructField) *Schema {
+	if enum := field.Tag.Get("enum"); enum != "" && schema.Ref == "" {
+		schema.Enum = strings.Split(enum, ",")
+	}
+	return schema
+}
+
+func tagName(tag string) string {
+	name, _, _ := strings.Cut(tag, ",")
+	return name
+}
+
+// RestrictScopes 删除需要未被 allowed 允许的授权范围的操作，并从安全方案中删除这些授权范围，用于按应用提供文档
+func (d *Document) RestrictScopes(allowed func(scope string) bool) {
+	permitted := func(operation *Operation) bool {
+		if operation == nil {
+			return false
+		}
+		for _, requirement := range operation.Security {
+			for _, scope := range requirement[SecuritySchemeName] {
+				if !allowed(scope) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	if d.Paths != nil {
+		for _, path := range append([]string(nil), d.Paths.Keys()...) {
+			item, _ := d.Paths.Get(path)
+			if !permitted(item.Get) {
+				item.Get = nil
+			}
+			if !permitted(item.Post) {
+				item.Post = nil
+			}
+			if item.Get == nil && item.Post == nil {
+				d.Paths.Delete(path)
+			}
+		}
+	}
+	scheme, ok := d.Components.SecuritySchemes[SecuritySchemeName]
+	if !ok || scheme.Flows == nil || scheme.Flows.AuthorizationCode == nil || scheme.Flows.AuthorizationCode.Scopes == nil {
+		return
+	}
+	scopes := scheme.Flows.AuthorizationCode.Scopes
+	for _, scope := range append([]string(nil), scopes.Keys()...) {
+		if !allowed(scope) {
+			scopes.Delete(scope)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package openapi
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Document 为 OpenAPI 3.0 文档中本服务用到的部分
+// 规范详见: https://spec.openapis.org/oas/v3.0.3
+type Document struct {
+	OpenAPI    string          `yaml:"openapi"`
+	Info       Info            `yaml:"info"`
+	Servers    []Server        `yaml:"servers"`
+	Paths      *Map[*PathItem] `yaml:"paths"`
+	Components Components      `yaml:"components"`
+}
+
+type Info struct {
+	Title       string `yaml:"title"`
+	Description string `yaml:"description"`
+	Version     string `yaml:"version"`
+}
+
+type Server struct {
+	URL string `yaml:"url"`
+}
+
+// PathItem 以小写的 HTTP 方法为键
+type PathItem struct {
+	Get  *Operation `yaml:"get,omitempty"`
+	Post *Operation `yaml:"post,omitempty"`
+}
+
+type Operation struct {
+	Summary     string                `yaml:"summary"`
+	Description string                `yaml:"description"`
+	OperationID string                `yaml:"operationId"`
+	Security    []map[string][]string `yaml:"security,omitempty"`
+	Parameters  []*Parameter          `yaml:"parameters,omitempty"`
+	RequestBody *RequestBody          `yaml:"requestBody,omitempty"`
+	Responses   *Map[*Response]       `yaml:"responses"`
+}
+
+type Parameter struct {
+	Name        string  `yaml:"name"`
+	In          string  `yaml:"in"`
+	Description string  `yaml:"description,omitempty"`
+	Required    bool    `yaml:"required"`
+	Schema      *Schema `yaml:"schema"`
+}
+
+type RequestBody struct {
+	Description string                `yaml:"description,omitempty"`
+	Required    bool                  `yaml:"required"`
+	Content     map[string]*MediaType `yaml:"content"`
+}
+
+type Response struct {
+	Description string                `yaml:"description"`
+	Content     map[string]*MediaType `yaml:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `yaml:"schema"`
+}
+
+type Components struct {
+	SecuritySchemes map[string]*SecurityScheme `yaml:"securitySchemes,omitempty"`
+	Schemas         map[string]*Schema         `yaml:"schemas,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type        string      `yaml:"type"`
+	Description string      `yaml:"description,omitempty"`
+	Flows       *OAuthFlows `yaml:"flows,omitempty"`
+}
+
+type OAuthFlows struct {
+	AuthorizationCode *OAuthFlow `yaml:"authorizationCode,omitempty"`
+}
+
+type OAuthFlow struct {
+	AuthorizationURL string       `yaml:"authorizationUrl"`
+	TokenURL         string       `yaml:"tokenUrl"`
+	Scopes           *Map[string] `yaml:"scopes"`
+}
+
+// Schema 为 JSON Schema 中本服务用到的部分
+type Schema struct {
+	Ref         string        `yaml:"$ref,omitempty"`
+	Type        string        `yaml:"type,omitempty"`
+	Description string        `yaml:"description,omitempty"`
+	Enum        []string      `yaml:"enum,omitempty"`
+	Required    []string      `yaml:"required,omitempty"`
+	Properties  *Map[*Schema] `yaml:"properties,omitempty"`
+	Items       *Schema       `yaml:"items,omitempty"`
+}
+
+// Map 为保持插入顺序的映射，使生成的文档与代码中的定义顺序一致
+type Map[V any] struct {
+	keys   []string
+	values map[string]V
+}
+
+func NewMap[V any]() *Map[V] {
+	return &Map[V]{values: make(map[string]V)}
+}
+
+func (m *Map[V]) Set(key string, value V) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+func (m *Map[V]) Get(key string) (V, bool) {
+	value, ok := m.values[key]
+	return value, ok
+}
+
+func (m *Map[V]) Delete(key string) {
+	if _, ok := m.values[key]; !ok {
+		return
+	}
+	delete(m.values, key)
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i:i], m.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+func (m *Map[V]) Keys() []string {
+	return m.keys
+}
+
+func (m *Map[V]) Len() int {
+	return len(m.keys)
+}
+
+func (m *Map[V]) IsZero() bool {
+	return m == nil || len(m.keys) == 0
+}
+
+func (m *Map[V]) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, key := range m.keys {
+		value := &yaml.Node{}
+		if err := value.Encode(m.values[key]); err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+	}
+	return node, nil
+}
+
+func (m *Map[V]) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return errors.Errorf("line %d: expect a mapping", node.Line)
+	}
+	m.keys = nil
+	m.values = make(map[string]V, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		var value V
+		if err := node.Content[i+1].Decode(&value); err != nil {
+			return err
+		}
+		m.Set(node.Content[i].Value, value)
+	}
+	return nil
+}
+
+// Marshal 将文档序列化为 YAML，header 为写在文档开头的注释
+func Marshal(doc *Document, header string) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Parse 解析 YAML 格式的 OpenAPI 文档
+func Parse(content []byte) (*Document, error) {
+	doc := &Document{}
+	if err := yaml.Unmarshal(content, doc); err != nil {
+		return nil, errors.Wrap(err, "parse openapi document")
+	}
+	return doc, nil
+}
@@ -0,0 +1,254 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValidationError 描述了不符合文档的参数或字段，Field 为参数名或 JSON 中的字段路径
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// Operation 返回文档中路径及方法对应的操作
+func (d *Document) Operation(method, path string) (*Operation, bool) {
+	if d.Paths == nil {
+		return nil, false
+	}
+	item, ok := d.Paths.Get(path)
+	if !ok {
+		return nil, false
+	}
+	var operation *Operation
+	switch method {
+	case http.MethodGet:
+		operation = item.Get
+	case http.MethodPost:
+		operation = item.Post
+	}
+	return operation, operation != nil
+}
+
+// ValidateQuery 校验查询参数是否符合操作的参数定义：参数的每个取值都需符合定义，未在文档中定义的参数视为错误
+func (d *Document) ValidateQuery(operation *Operation, query url.Values) error {
+	defined := make(map[string]bool, len(operation.Parameters))
+	for _, parameter := range operation.Parameters {
+		if parameter.In != "query" {
+			continue
+		}
+		defined[parameter.Name] = true
+		var values []string
+		for _, value := range query[parameter.Name] {
+			if value != "" {
+				values = append(values, value)
+			}
+		}
+		if len(values) == 0 {
+			if parameter.Required {
+				return &ValidationError{Field: parameter.Name, Message: "is required"}
+			}
+			continue
+		}
+		schema := d.resolve(parameter.Schema)
+		if schema == nil {
+			continue
+		}
+		if schema.Type == "array" {
+			schema = schema.Items
+		}
+		for _, value := range values {
+			if err := d.validateQueryValue(parameter.Name, schema, value); err != nil {
+				return err
+			}
+		}
+	}
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !defined[name] {
+			return &ValidationError{Field: name, Message: "is not a documented parameter"}
+		}
+	}
+	return nil
+}
+
+func (d *Document) validateQueryValue(name string, schema *Schema, value string) error {
+	schema = d.resolve(schema)
+	if schema == nil {
+		return nil
+	}
+	var err error
+	switch schema.Type {
+	case "integer":
+		_, err = strconv.ParseInt(value, 10, 64)
+	case "number":
+		_, err = strconv.ParseFloat(value, 64)
+	case "boolean":
+		_, err = strconv.ParseBool(value)
+	}
+	if err != nil {
+		return &ValidationError{Field: name, Message: fmt.Sprintf("must be a valid %s, got %q", schema.Type, value)}
+	}
+	return validateEnum(name, schema, value)
+}
+
+// ValidateRequestBody 校验 JSON 请求体是否符合操作的 requestBody 定义，操作未定义请求体时不做校验
+func (d *Document) ValidateRequestBody(operation *Operation, body []byte) error {
+	if operation.RequestBody == nil {
+		return nil
+	}
+	mediaType, ok := operation.RequestBody.Content["application/json"]
+	if !ok {
+		return nil
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		if operation.RequestBody.Required {
+			return &ValidationError{Field: "body", Message: "is required"}
+		}
+		return nil
+	}
+	return d.ValidateJSON(mediaType.Schema, body)
+}
+
+// ValidateResponse 校验 JSON 响应体是否符合操作在该状态码下的响应定义，未定义的状态码使用 default
+func (d *Document) ValidateResponse(operation *Operation, status int, body []byte) error {
+	if operation.Responses == nil {
+		return nil
+	}
+	response, ok := operation.Responses.Get(strconv.Itoa(status))
+	if !ok {
+		if response, ok = operation.Responses.Get("default"); !ok {
+			return &ValidationError{Field: "status", Message: fmt.Sprintf("status %d is not documented", status)}
+		}
+	}
+	mediaType, ok := response.Content["application/json"]
+	if !ok {
+		return nil
+	}
+	return d.ValidateJSON(mediaType.Schema, body)
+}
+
+// ValidateJSON 校验 JSON 文本是否符合 schema
+func (d *Document) ValidateJSON(schema *Schema, body []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return &ValidationError{Field: "body", Message: "must be valid JSON: " + err.Error()}
+	}
+	return d.validateValue("body", schema, value)
+}
+
+func (d *Document) validateValue(field string, schema *Schema, value interface{}) error {
+	schema = d.resolve(schema)
+	if schema == nil || value == nil {
+		return nil
+	}
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return typeMismatch(field, schema.Type, value)
+		}
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				return &ValidationError{Field: field + "." + name, Message: "is required"}
+			}
+		}
+		if schema.Properties == nil {
+			return nil
+		}
+		for _, name := range schema.Properties.Keys() {
+			property, _ := schema.Properties.Get(name)
+			if err := d.validateValue(field+"."+name, property, object[name]); err != nil {
+				return err
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return typeMismatch(field, schema.Type, value)
+		}
+		for i, item := range array {
+			if err := d.validateValue(fmt.Sprintf("%s[%d]", field, i), schema.Items, item); err != nil {
+				return err
+			}
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return typeMismatch(field, schema.Type, value)
+		}
+		return validateEnum(field, schema, s)
+	case "integer":
+		number, ok := value.(json.Number)
+		if !ok {
+			return typeMismatch(field, schema.Type, value)
+		}
+		if f, err := number.Float64(); err != nil || f != math.Trunc(f) {
+			return typeMismatch(field, schema.Type, value)
+		}
+	case "number":
+		if _, ok := value.(json.Number); !ok {
+			return typeMismatch(field, schema.Type, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return typeMismatch(field, schema.Type, value)
+		}
+	}
+	return nil
+}
+
+// resolve 解析 #/components/schemas/ 下的引用
+func (d *Document) resolve(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		schema = d.Components.Schemas[name]
+	}
+	return schema
+}
+
+func validateEnum(field string, schema *Schema, value string) error {
+	if len(schema.Enum) == 0 {
+		return nil
+	}
+	for _, e := range schema.Enum {
+		if e == value {
+			return nil
+		}
+	}
+	return &ValidationError{Field: field, Message: fmt.Sprintf("must be one of %s, got %q", strings.Join(schema.Enum, ", "), value)}
+}
+
+func typeMismatch(field, expected string, value interface{}) error {
+	actual := "null"
+	switch value.(type) {
+	case map[string]interface{}:
+		actual = "object"
+	case []interface{}:
+		actual = "array"
+	case string:
+		actual = "string"
+	case json.Number:
+		actual = "number"
+	case bool:
+		actual = "boolean"
+	}
+	return &ValidationError{Field: field, Message: fmt.Sprintf("must be %s, got %s", expected, actual)}
+}
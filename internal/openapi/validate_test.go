@@ -0,0 +1,55 @@
+package openapi
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+type testQuery struct {
+	Account string   `form:"account" description:"账号"`
+	Count   int      `form:"count" description:"数量"`
+	Sort    string   `form:"sort" description:"排序" enum:"asc,desc"`
+	Ids     []int    `form:"ids" description:"标识"`
+	Tags    []string `form:"tags" description:"标签" binding:"required"`
+}
+
+func TestValidateQuery(t *testing.T) {
+	doc := Generate(Info{Title: "test", Version: "1.0.0"}, "https://example.com", []*Endpoint{{
+		Method: http.MethodGet, Path: "/items", OperationID: "ListItems", Query: testQuery{},
+	}})
+	operation, ok := doc.Operation(http.MethodGet, "/items")
+	if !ok {
+		t.Fatal("operation is not generated")
+	}
+
+	cases := []struct {
+		query string
+		field string
+	}{
+		{"tags=a&count=5&sort=asc&ids=1&ids=2&account=", ""},
+		{"tags=a&count=5&count=abc", "count"},
+		{"tags=a&sort=asc&sort=random", "sort"},
+		{"tags=a&ids=1&ids=x", "ids"},
+		{"count=5", "tags"},
+		{"tags=", "tags"},
+		{"tags=a&openId=123", "openId"},
+	}
+	for _, tc := range cases {
+		query, err := url.ParseQuery(tc.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = doc.ValidateQuery(operation, query)
+		if tc.field == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tc.query, err)
+			}
+			continue
+		}
+		validationError, ok := err.(*ValidationError)
+		if !ok || validationError.Field != tc.field {
+			t.Errorf("%s: error = %v, want a violation of %s", tc.query, err, tc.field)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Quota 为一个限流维度的配额，Rate 与 Burst 构成令牌桶，Daily 为每日请求上限，为 0 时不限制
+type Quota struct {
+	Rate  float64
+	Burst int
+	Daily int
+}
+
+// Status 为某个限流键当前的状态，用于运维查询
+type Status struct {
+	Key          string    `json:"key"`
+	Tokens       float64   `json:"tokens"`
+	DailyUsed    int       `json:"dailyUsed"`
+	BlockedUntil time.Time `json:"blockedUntil,omitempty"`
+}
+
+type bucket struct {
+	tokens       float64
+	last         time.Time
+	day          string
+	dailyUsed    int
+	blockedUntil time.Time
+}
+
+// Limiter 为按键隔离的令牌桶限流器，并支持在上游返回配额错误时临时封禁某个键
+type Limiter struct {
+	quota     Quota
+	overrides map[string]Quota
+	now       func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	calls   int
+}
+
+// idleBucketTTL 为空闲令牌桶的保留时间，超过后回收以避免键的数量无限增长
+const idleBucketTTL = 24 * time.Hour
+
+func New(quota Quota, overrides map[string]Quota) *Limiter {
+	return &Limiter{
+		quota:     quota,
+		overrides: overrides,
+		now:       time.Now,
+		buckets:   make(map[string]*bucket),
+	}
+}
+
+// Allow 消耗 key 的一个令牌，被拒绝时返回建议的重试等待时间
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.collect(now)
+
+	quota := l.quotaOf(key)
+	b := l.bucketOf(key, quota, now)
+	if now.Before(b.blockedUntil) {
+		return false, b.blockedUntil.Sub(now)
+	}
+	if quota.Daily > 0 && b.dailyUsed >= quota.Daily {
+		return false, nextDay(now).Sub(now)
+	}
+	if b.tokens < 1 {
+		return false, time.Duration(math.Ceil((1 - b.tokens) / quota.Rate * float64(time.Second)))
+	}
+	b.tokens--
+	b.dailyUsed++
+	return true, 0
+}
+
+// Block 在 duration 内拒绝 key 的所有请求，用于上游返回配额耗尽时主动退避
+func (l *Limiter) Block(key string, duration time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	b := l.bucketOf(key, l.quotaOf(key), now)
+	if until := now.Add(duration); until.After(b.blockedUntil) {
+		b.blockedUntil = until
+	}
+}
+
+// Statuses 返回所有限流键的当前状态
+func (l *Limiter) Statuses() []*Status {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	statuses := make([]*Status, 0, len(l.buckets))
+	for key, b := range l.buckets {
+		l.refill(b, l.quotaOf(key), now)
+		status := &Status{Key: key, Tokens: b.tokens, DailyUsed: b.dailyUsed}
+		if now.Before(b.blockedUntil) {
+			status.BlockedUntil = b.blockedUntil
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+func (l *Limiter) quotaOf(key string) Quota {
+	if quota, ok := l.overrides[key]; ok {
+		return quota
+	}
+	return l.quota
+}
+
+func (l *Limiter) bucketOf(key string, quota Quota, now time.Time) *bucket {
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(quota.Burst), last: now, day: now.Format("2006-01-02")}
+		l.buckets[key] = b
+	}
+	l.refill(b, quota, now)
+	return b
+}
+
+func (l *Limiter) refill(b *bucket, quota Quota, now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(quota.Burst), b.tokens+elapsed*quota.Rate)
+		b.last = now
+	}
+	// 抖音的每日配额按自然日重置
+	if day := now.Format("2006-01-02"); day != b.day {
+		b.day = day
+		b.dailyUsed = 0
+	}
+}
+
+// collect 每隔一定调用次数回收长时间空闲的令牌桶
+func (l *Limiter) collect(now time.Time) {
+	l.calls++
+	if l.calls%1024 != 0 {
+		return
+	}
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > idleBucketTTL && !now.Before(b.blockedUntil) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func nextDay(now time.Time) time.Time {
+	year, month, day := now.Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, now.Location())
+}
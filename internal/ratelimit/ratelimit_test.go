@@ -0,0 +1,109 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock 为可手动推进的时钟
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestLimiter(quota Quota, overrides map[string]Quota) (*Limiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	l := New(quota, overrides)
+	l.now = clock.Now
+	return l, clock
+}
+
+func assertAllowed(t *testing.T, l *Limiter, key string) {
+	t.Helper()
+	if allowed, retryAfter := l.Allow(key); !allowed {
+		t.Fatalf("%s is rejected, retry after %s", key, retryAfter)
+	}
+}
+
+func assertRejected(t *testing.T, l *Limiter, key string, wantRetryAfter time.Duration) {
+	t.Helper()
+	allowed, retryAfter := l.Allow(key)
+	if allowed {
+		t.Fatalf("%s is allowed", key)
+	}
+	if retryAfter != wantRetryAfter {
+		t.Fatalf("retry after %s, want %s", retryAfter, wantRetryAfter)
+	}
+}
+
+func TestLimiterBurst(t *testing.T) {
+	l, _ := newTestLimiter(Quota{Rate: 1, Burst: 3}, nil)
+	for i := 0; i < 3; i++ {
+		assertAllowed(t, l, "user")
+	}
+	assertRejected(t, l, "user", time.Second)
+	// 各键的令牌桶相互独立
+	assertAllowed(t, l, "other")
+}
+
+func TestLimiterRefill(t *testing.T) {
+	l, clock := newTestLimiter(Quota{Rate: 2, Burst: 2}, nil)
+	assertAllowed(t, l, "user")
+	assertAllowed(t, l, "user")
+	assertRejected(t, l, "user", 500*time.Millisecond)
+
+	clock.Advance(250 * time.Millisecond)
+	assertRejected(t, l, "user", 250*time.Millisecond)
+	clock.Advance(250 * time.Millisecond)
+	assertAllowed(t, l, "user")
+
+	// 长时间空闲后令牌数不超过 Burst
+	clock.Advance(time.Hour)
+	assertAllowed(t, l, "user")
+	assertAllowed(t, l, "user")
+	assertRejected(t, l, "user", 500*time.Millisecond)
+}
+
+func TestLimiterOverrides(t *testing.T) {
+	l, _ := newTestLimiter(Quota{Rate: 1, Burst: 1}, map[string]Quota{"/slow/": {Rate: 0.1, Burst: 1}})
+	assertAllowed(t, l, "/slow/")
+	assertRejected(t, l, "/slow/", 10*time.Second)
+	assertAllowed(t, l, "/fast/")
+	assertRejected(t, l, "/fast/", time.Second)
+}
+
+func TestLimiterBlockExpires(t *testing.T) {
+	l, clock := newTestLimiter(Quota{Rate: 1, Burst: 5}, nil)
+	l.Block("/api/", time.Minute)
+	assertRejected(t, l, "/api/", time.Minute)
+	// 较短的封禁不会缩短已有的封禁
+	l.Block("/api/", time.Second)
+	clock.Advance(30 * time.Second)
+	assertRejected(t, l, "/api/", 30*time.Second)
+	if statuses := l.Statuses(); len(statuses) != 1 || !statuses[0].BlockedUntil.Equal(clock.now.Add(30*time.Second)) {
+		t.Fatalf("statuses = %+v", statuses)
+	}
+
+	clock.Advance(30 * time.Second)
+	assertAllowed(t, l, "/api/")
+	if statuses := l.Statuses(); len(statuses) != 1 || !statuses[0].BlockedUntil.IsZero() {
+		t.Fatalf("block is still reported: %+v", statuses)
+	}
+}
+
+func TestLimiterDailyQuota(t *testing.T) {
+	l, clock := newTestLimiter(Quota{Rate: 100, Burst: 100, Daily: 2}, nil)
+	assertAllowed(t, l, "user")
+	assertAllowed(t, l, "user")
+	// 每日配额在次日零点重置
+	assertRejected(t, l, "user", 12*time.Hour)
+	clock.Advance(12 * time.Hour)
+	assertAllowed(t, l, "user")
+}
@@ -0,0 +1,68 @@
+package tracing
+
+import (
+	"context"
+	"douyin-action-example/internal/conf"
+	"github.com/chzealot/gobase/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"net/http"
+)
+
+// Init 按配置初始化链路追踪，返回的 shutdown 用于停止服务时导出剩余的 span
+// 未启用时仍然设置 W3C traceparent 传播，以便透传上游的追踪上下文
+func Init(ctx context.Context, config conf.TracingConfig) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.Warnf("opentelemetry error, err=%s", err.Error())
+	}))
+	if !config.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(config.Endpoint)}
+	if config.Insecure {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, options...)
+	if err != nil {
+		return nil, errors.Wrap(err, "create otlp trace exporter")
+	}
+	return Install(exporter, config.ServiceName, config.SampleRatio)
+}
+
+// Install 使用 exporter 设置全局的 TracerProvider，测试中可传入内存 exporter
+func Install(exporter sdktrace.SpanExporter, serviceName string, sampleRatio float64) (func(context.Context) error, error) {
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Middleware 为每个请求创建 span，并从 traceparent 头恢复调用方的追踪上下文
+func Middleware(serviceName string) gin.HandlerFunc {
+	return otelgin.Middleware(serviceName)
+}
+
+// Transport 为发往抖音开放平台的请求创建 span，并注入 traceparent 头
+func Transport(next http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(next, otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		return "douyin " + r.Method + " " + r.URL.Path
+	}))
+}
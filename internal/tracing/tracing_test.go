@@ -0,0 +1,123 @@
+package tracing_test
+
+import (
+	"context"
+	"douyin-action-example/internal/actions/controllers"
+	"douyin-action-example/internal/conf"
+	"douyin-action-example/internal/tracing"
+	"github.com/chzealot/gobase/logger"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func attributeValue(attributes []attribute.KeyValue, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range attributes {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestRequestAndDouYinCallSpans(t *testing.T) {
+	logger.DefaultLogger = zap.NewNop()
+	logger.DefaultSugarLogger = logger.DefaultLogger.Sugar()
+	gin.SetMode(gin.TestMode)
+
+	if _, err := tracing.Init(context.Background(), conf.TracingConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown, err := tracing.Install(exporter, "tracing-test", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shutdown(context.Background())
+
+	// 模拟的抖音开放平台记录收到的 traceparent 头
+	var traceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":{"error_code":0}}`))
+	}))
+	defer upstream.Close()
+
+	engine := gin.New()
+	engine.Use(tracing.Middleware("tracing-test"))
+	engine.GET("/videos/:id", func(c *gin.Context) {
+		request, err := http.NewRequestWithContext(c.Request.Context(), http.MethodGet, upstream.URL+"/oauth/userinfo/", nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		client, _ := controllers.NewDouYinClient()
+		response, err := client.Do(request)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		response.Body.Close()
+		c.Status(http.StatusNoContent)
+	})
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/videos/42", nil))
+	if recorder.Code != http.StatusNoContent {
+		t.Fatalf("status = %d", recorder.Code)
+	}
+	// 内存 exporter 在关闭时会清空 span，因此先导出再读取
+	provider := otel.GetTracerProvider().(*sdktrace.TracerProvider)
+	if err := provider.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var server, client *tracetest.SpanStub
+	spans := exporter.GetSpans()
+	for i := range spans {
+		switch spans[i].SpanKind {
+		case trace.SpanKindServer:
+			server = &spans[i]
+		case trace.SpanKindClient:
+			client = &spans[i]
+		}
+	}
+	if server == nil || client == nil {
+		t.Fatalf("want a server and a client span, got %d spans: %+v", len(spans), spans)
+	}
+
+	if server.Name != "/videos/:id" {
+		t.Errorf("server span name = %q, want the route", server.Name)
+	}
+	if v, ok := attributeValue(server.Attributes, "http.route"); !ok || v.AsString() != "/videos/:id" {
+		t.Errorf("server span http.route = %v", v.AsString())
+	}
+	if v, ok := attributeValue(server.Attributes, "http.status_code"); !ok || v.AsInt64() != http.StatusNoContent {
+		t.Errorf("server span http.status_code = %v", v.AsInt64())
+	}
+
+	if client.Name != "douyin GET /oauth/userinfo/" {
+		t.Errorf("client span name = %q", client.Name)
+	}
+	if client.Parent.SpanID() != server.SpanContext.SpanID() || client.SpanContext.TraceID() != server.SpanContext.TraceID() {
+		t.Errorf("client span is not a child of the server span")
+	}
+	if v, ok := attributeValue(client.Attributes, "http.method"); !ok || v.AsString() != http.MethodGet {
+		t.Errorf("client span http.method = %v", v.AsString())
+	}
+	if v, ok := attributeValue(client.Attributes, "http.status_code"); !ok || v.AsInt64() != http.StatusOK {
+		t.Errorf("client span http.status_code = %v", v.AsInt64())
+	}
+	if !strings.Contains(traceparent, client.SpanContext.SpanID().String()) ||
+		!strings.Contains(traceparent, client.SpanContext.TraceID().String()) {
+		t.Errorf("traceparent %q was not propagated from the client span", traceparent)
+	}
+}